@@ -0,0 +1,36 @@
+// Access log built on event stream.
+//
+// httransform reports a start, a finish, an error and traffic of the
+// request as separate events. Processor joins them by request ID into
+// a single line per request. Lines can be JSON, Common Log Format,
+// Combined Log Format or a custom text/template.
+//
+//     file, err := accesslog.NewRotatingFile(accesslog.RotatingFileOpts{
+//         Path:    "/var/log/httransform/access.log",
+//         MaxSize: 100 * 1024 * 1024,
+//     })
+//     if err != nil {
+//         panic(err)
+//     }
+//
+//     defer file.Close()
+//
+//     logger, err := accesslog.NewLogger(accesslog.Opts{
+//         Writer: file,
+//         Format: accesslog.FormatCombined,
+//     })
+//     if err != nil {
+//         panic(err)
+//     }
+//
+//     opts := httransform.ServerOpts{
+//         EventProcessorFactory: accesslog.NewProcessorFactory(logger),
+//     }
+//
+// Custom templates get *Entry:
+//
+//     logger, err := accesslog.NewLogger(accesslog.Opts{
+//         Format:   accesslog.FormatTemplate,
+//         Template: `{{ .RequestID }} {{ .Method }} {{ .URL }} {{ .StatusCode }} {{ .Duration }}`,
+//     })
+package accesslog
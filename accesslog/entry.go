@@ -0,0 +1,80 @@
+package accesslog
+
+import (
+	"net"
+	"time"
+
+	"github.com/9seconds/httransform/v2/events"
+)
+
+// Entry is a single request in access log. It joins
+// events.EventTypeStartRequest, events.EventTypeFailedRequest,
+// events.EventTypeFinishRequest and events.EventTypeTraffic of the
+// same request.
+type Entry struct {
+	// RequestID is unique identifier of the request.
+	RequestID string
+
+	// Time is a time when request was started.
+	Time time.Time
+
+	// Addr is an address of the client.
+	Addr net.Addr
+
+	// User is a name of the user populated by authenticator.
+	User string
+
+	// Method is HTTP verb of the request.
+	Method string
+
+	// URL is a full URL of the request.
+	URL string
+
+	// Protocol is a protocol of the request like HTTP/1.1.
+	Protocol string
+
+	// Referer is a value of Referer header.
+	Referer string
+
+	// UserAgent is a value of User-Agent header.
+	UserAgent string
+
+	// RequestType defines a set of characteristics of the request.
+	RequestType events.RequestType
+
+	// StatusCode is HTTP status code of the response.
+	StatusCode int
+
+	// Duration is a time between start and finish of the request.
+	Duration time.Duration
+
+	// Timings is a breakdown of request processing time.
+	Timings events.Timings
+
+	// Error is a message of the error if request has failed.
+	Error string
+
+	// ReadBytes is how many bytes were read from the netloc.
+	ReadBytes uint64
+
+	// WrittenBytes is how many bytes were written to the netloc.
+	WrittenBytes uint64
+
+	// HasTraffic is true if traffic of the request was reported.
+	HasTraffic bool
+}
+
+// Host returns an IP address of the client without a port.
+func (e *Entry) Host() string {
+	if e.Addr == nil {
+		return ""
+	}
+
+	addr := e.Addr.String()
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
@@ -0,0 +1,17 @@
+package accesslog
+
+import "github.com/9seconds/httransform/v2/errors"
+
+var (
+	// ErrUnknownFormat is returned if format of the lines is not
+	// supported.
+	ErrUnknownFormat = &errors.Error{
+		Message: "unknown access log format",
+	}
+
+	// ErrFileClosed is returned if RotatingFile is used after it was
+	// closed.
+	ErrFileClosed = &errors.Error{
+		Message: "file is closed",
+	}
+)
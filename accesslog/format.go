@@ -0,0 +1,185 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+type formatter func(*bytes.Buffer, *Entry) error
+
+type jsonEntry struct {
+	RequestID    string             `json:"request_id"`
+	Time         time.Time          `json:"time"`
+	Addr         string             `json:"addr"`
+	User         string             `json:"user,omitempty"`
+	Method       string             `json:"method"`
+	URL          string             `json:"url"`
+	Protocol     string             `json:"protocol"`
+	Referer      string             `json:"referer,omitempty"`
+	UserAgent    string             `json:"user_agent,omitempty"`
+	Tunneled     bool               `json:"tunneled"`
+	TLS          bool               `json:"tls"`
+	StatusCode   int                `json:"status_code"`
+	Duration     float64            `json:"duration_ms"`
+	Timings      map[string]float64 `json:"timings_ms"`
+	Error        string             `json:"error,omitempty"`
+	ReadBytes    *uint64            `json:"read_bytes,omitempty"`
+	WrittenBytes *uint64            `json:"written_bytes,omitempty"`
+}
+
+func formatJSON(buf *bytes.Buffer, entry *Entry) error {
+	value := jsonEntry{
+		RequestID:  entry.RequestID,
+		Time:       entry.Time,
+		User:       entry.User,
+		Method:     entry.Method,
+		URL:        entry.URL,
+		Protocol:   entry.Protocol,
+		Referer:    entry.Referer,
+		UserAgent:  entry.UserAgent,
+		Tunneled:   entry.RequestType.IsTunneled(),
+		TLS:        entry.RequestType.IsTLS(),
+		StatusCode: entry.StatusCode,
+		Duration:   milliseconds(entry.Duration),
+		Timings: map[string]float64{
+			"dns":                milliseconds(entry.Timings.DNS),
+			"dial":               milliseconds(entry.Timings.Dial),
+			"tls_handshake":      milliseconds(entry.Timings.TLSHandshake),
+			"send":               milliseconds(entry.Timings.Send),
+			"time_to_first_byte": milliseconds(entry.Timings.TimeToFirstByte),
+			"layers":             milliseconds(entry.Timings.Layers),
+			"executor":           milliseconds(entry.Timings.Executor),
+		},
+		Error: entry.Error,
+	}
+
+	if entry.Addr != nil {
+		value.Addr = entry.Addr.String()
+	}
+
+	if entry.HasTraffic {
+		value.ReadBytes = &entry.ReadBytes
+		value.WrittenBytes = &entry.WrittenBytes
+	}
+
+	// Encode appends a newline.
+	if err := json.NewEncoder(buf).Encode(&value); err != nil {
+		return fmt.Errorf("cannot encode an entry: %w", err)
+	}
+
+	return nil
+}
+
+func formatCommon(buf *bytes.Buffer, entry *Entry) error {
+	writeCommon(buf, entry)
+	buf.WriteByte('\n')
+
+	return nil
+}
+
+func formatCombined(buf *bytes.Buffer, entry *Entry) error {
+	writeCommon(buf, entry)
+	buf.WriteByte(' ')
+	writeQuoted(buf, entry.Referer)
+	buf.WriteByte(' ')
+	writeQuoted(buf, entry.UserAgent)
+	buf.WriteByte('\n')
+
+	return nil
+}
+
+// writeCommon writes a line of Common Log Format:
+// host ident authuser [date] "request" status bytes. Netloc does
+// not send response to the client directly so bytes are the ones
+// which were read from the netloc including headers.
+func writeCommon(buf *bytes.Buffer, entry *Entry) {
+	writeField(buf, entry.Host())
+	buf.WriteString(" - ")
+	writeField(buf, entry.User)
+	buf.WriteString(" [")
+	buf.WriteString(entry.Time.Format(clfTimeFormat))
+	buf.WriteString("] ")
+	buf.WriteByte('"')
+	writeEscaped(buf, entry.Method+" "+entry.URL+" "+entry.Protocol)
+	buf.WriteByte('"')
+	buf.WriteByte(' ')
+
+	if entry.StatusCode > 0 {
+		buf.WriteString(strconv.Itoa(entry.StatusCode))
+	} else {
+		buf.WriteByte('-')
+	}
+
+	buf.WriteByte(' ')
+
+	if entry.HasTraffic {
+		buf.WriteString(strconv.FormatUint(entry.ReadBytes, 10)) // nolint: gomnd
+	} else {
+		buf.WriteByte('-')
+	}
+}
+
+func writeField(buf *bytes.Buffer, value string) {
+	if value == "" {
+		buf.WriteByte('-')
+	} else {
+		writeEscaped(buf, value)
+	}
+}
+
+func writeQuoted(buf *bytes.Buffer, value string) {
+	buf.WriteByte('"')
+	writeField(buf, value)
+	buf.WriteByte('"')
+}
+
+// writeEscaped escapes values in the same way as Apache HTTP server
+// does: quotes and backslashes are prefixed with backslash, control
+// characters and invalid UTF-8 are written as \xhh.
+func writeEscaped(buf *bytes.Buffer, value string) {
+	for len(value) > 0 {
+		char, size := utf8.DecodeRuneInString(value)
+
+		switch {
+		case char == '"' || char == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(char)
+		case char == utf8.RuneError && size == 1, char < 0x20, char == 0x7f: // nolint: gomnd
+			fmt.Fprintf(buf, `\x%02x`, value[0])
+		default:
+			buf.WriteString(value[:size])
+		}
+
+		value = value[size:]
+	}
+}
+
+func makeTemplateFormatter(text string) (formatter, error) {
+	tpl, err := template.New("accesslog").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse a template: %w", err)
+	}
+
+	return func(buf *bytes.Buffer, entry *Entry) error {
+		if err := tpl.Execute(buf, entry); err != nil {
+			return fmt.Errorf("cannot execute a template: %w", err)
+		}
+
+		if data := buf.Bytes(); len(data) == 0 || data[len(data)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+
+		return nil
+	}, nil
+}
+
+func milliseconds(value time.Duration) float64 {
+	return float64(value) / float64(time.Millisecond)
+}
@@ -0,0 +1,66 @@
+package accesslog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Logger formats entries and writes them to the writer. It is safe
+// for concurrent use.
+type Logger struct {
+	format      formatter
+	trafficWait time.Duration
+	stateTTL    time.Duration
+	mutex       sync.Mutex
+	writer      io.Writer
+}
+
+// Log writes a line for a given entry.
+func (l *Logger) Log(entry *Entry) error {
+	buf := bytes.Buffer{}
+
+	if err := l.format(&buf, entry); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, err := l.writer.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot write a line: %w", err)
+	}
+
+	return nil
+}
+
+// NewLogger returns a new logger based on given options.
+func NewLogger(opts Opts) (*Logger, error) {
+	rv := &Logger{
+		writer:      opts.GetWriter(),
+		trafficWait: opts.GetTrafficWait(),
+		stateTTL:    opts.GetRequestStateTTL(),
+	}
+
+	switch opts.GetFormat() {
+	case FormatJSON:
+		rv.format = formatJSON
+	case FormatCommon:
+		rv.format = formatCommon
+	case FormatCombined:
+		rv.format = formatCombined
+	case FormatTemplate:
+		format, err := makeTemplateFormatter(opts.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		rv.format = format
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	return rv, nil
+}
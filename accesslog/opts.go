@@ -0,0 +1,113 @@
+package accesslog
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultTrafficWait defines how long a finished request waits for
+	// events.EventTypeTraffic if user provides no value.
+	DefaultTrafficWait = time.Second
+
+	// RequestStateTTL defines for how long a request is waited to be
+	// finished if user provides no value.
+	RequestStateTTL = time.Hour
+
+	// requestStateSweepInterval defines how often processor looks for
+	// expired requests. It is shortened to request state TTL if that
+	// is less.
+	requestStateSweepInterval = time.Minute
+)
+
+// Format defines a format of access log lines.
+type Format byte
+
+const (
+	// FormatJSON writes each request as a JSON object on its own line.
+	FormatJSON Format = iota + 1
+
+	// FormatCommon writes lines in Common Log Format.
+	FormatCommon
+
+	// FormatCombined writes lines in Combined Log Format: Common Log
+	// Format with referer and user agent.
+	FormatCombined
+
+	// FormatTemplate executes Opts.Template for each request. Template
+	// gets *Entry.
+	FormatTemplate
+)
+
+// Opts defines a set of options for Logger.
+type Opts struct {
+	// Writer is a destination of access log lines. Each line is
+	// written with a single Write call. Default is os.Stdout. If you
+	// need rotation, please use RotatingFile.
+	Writer io.Writer
+
+	// Format defines a format of lines. Default is FormatJSON.
+	Format Format
+
+	// Template is a text/template which is used if Format is
+	// FormatTemplate. A newline is appended to each line if template
+	// does not end with it.
+	Template string
+
+	// TrafficWait defines how long a finished request waits for
+	// events.EventTypeTraffic. Traffic is usually reported when
+	// response body is sent to the client, after request is finished.
+	// Some requests never report traffic (like HTTP/2 ones) so they
+	// are logged without it once this time has passed. Negative value
+	// means that requests are logged as soon as they are finished.
+	TrafficWait time.Duration
+
+	// RequestStateTTL defines for how long a request is waited to be
+	// finished. If its finish event is lost (for example, it is
+	// dropped by overloaded event stream), request is forgotten after
+	// this time and never logged.
+	RequestStateTTL time.Duration
+}
+
+// GetWriter returns a writer for access log or fallbacks to default
+// one.
+func (o *Opts) GetWriter() io.Writer {
+	if o.Writer == nil {
+		return os.Stdout
+	}
+
+	return o.Writer
+}
+
+// GetFormat returns a format of lines or fallbacks to default one.
+func (o *Opts) GetFormat() Format {
+	if o.Format == 0 {
+		return FormatJSON
+	}
+
+	return o.Format
+}
+
+// GetTrafficWait returns a time to wait for traffic or fallbacks to
+// default one.
+func (o *Opts) GetTrafficWait() time.Duration {
+	switch {
+	case o.TrafficWait == 0:
+		return DefaultTrafficWait
+	case o.TrafficWait < 0:
+		return 0
+	}
+
+	return o.TrafficWait
+}
+
+// GetRequestStateTTL returns a time to wait for request finish or
+// fallbacks to default one.
+func (o *Opts) GetRequestStateTTL() time.Duration {
+	if o.RequestStateTTL <= 0 {
+		return RequestStateTTL
+	}
+
+	return o.RequestStateTTL
+}
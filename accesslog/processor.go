@@ -0,0 +1,208 @@
+package accesslog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/9seconds/httransform/v2/events"
+)
+
+type requestState struct {
+	entry    Entry
+	finished bool
+	timer    *time.Timer
+}
+
+type processor struct {
+	logger   *Logger
+	mutex    sync.Mutex
+	requests map[string]*requestState
+	closed   bool
+	done     chan struct{}
+}
+
+func (p *processor) Process(evt events.Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch value := evt.Value.(type) {
+	case *events.RequestMeta:
+		if evt.Type == events.EventTypeStartRequest {
+			p.startRequest(value, evt.Time)
+		}
+	case *events.ErrorMeta:
+		if state, ok := p.requests[value.RequestID]; ok && evt.Type == events.EventTypeFailedRequest {
+			state.entry.Error = value.Err.Error()
+		}
+	case *events.ResponseMeta:
+		if evt.Type == events.EventTypeFinishRequest {
+			p.finishRequest(value, evt.Time)
+		}
+	case *events.TrafficMeta:
+		if evt.Type == events.EventTypeTraffic {
+			p.addTraffic(value)
+		}
+	}
+}
+
+func (p *processor) startRequest(meta *events.RequestMeta, startedAt time.Time) {
+	p.requests[meta.RequestID] = &requestState{
+		entry: Entry{
+			RequestID:   meta.RequestID,
+			Time:        startedAt,
+			Addr:        meta.Addr,
+			User:        meta.User,
+			Method:      meta.Method,
+			URL:         meta.URI.String(),
+			Protocol:    meta.Protocol,
+			Referer:     meta.Referer,
+			UserAgent:   meta.UserAgent,
+			RequestType: meta.RequestType,
+		},
+	}
+}
+
+func (p *processor) finishRequest(meta *events.ResponseMeta, finishedAt time.Time) {
+	state, ok := p.requests[meta.RequestID]
+	if !ok {
+		return
+	}
+
+	state.finished = true
+	state.entry.StatusCode = meta.StatusCode
+	state.entry.Duration = finishedAt.Sub(state.entry.Time)
+	state.entry.Timings = meta.Timings
+
+	// there are no timers after shutdown: nobody is going to wait
+	// for them.
+	if state.entry.HasTraffic || p.logger.trafficWait == 0 || p.closed {
+		p.log(meta.RequestID, state)
+
+		return
+	}
+
+	requestID := meta.RequestID
+	state.timer = time.AfterFunc(p.logger.trafficWait, func() {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		// a timer could fire concurrently with traffic event or
+		// shutdown which have already logged the request.
+		if current, ok := p.requests[requestID]; ok && current == state {
+			p.log(requestID, state)
+		}
+	})
+}
+
+func (p *processor) sweep() {
+	interval := requestStateSweepInterval
+	if p.logger.stateTTL < interval {
+		interval = p.logger.stateTTL
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-ticker.C:
+			p.expireRequests(now)
+		}
+	}
+}
+
+// expireRequests drops requests which are not finished for request
+// state TTL. Finished requests are not touched: they are logged by
+// timers.
+func (p *processor) expireRequests(now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	deadline := now.Add(-p.logger.stateTTL)
+
+	for requestID, state := range p.requests {
+		if !state.finished && state.entry.Time.Before(deadline) {
+			delete(p.requests, requestID)
+		}
+	}
+}
+
+func (p *processor) addTraffic(meta *events.TrafficMeta) {
+	state, ok := p.requests[meta.ID]
+	if !ok {
+		return
+	}
+
+	state.entry.HasTraffic = true
+	state.entry.ReadBytes += meta.ReadBytes
+	state.entry.WrittenBytes += meta.WrittenBytes
+
+	if state.finished {
+		p.log(meta.ID, state)
+	}
+}
+
+func (p *processor) log(requestID string, state *requestState) {
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+
+	delete(p.requests, requestID)
+
+	// there is nobody to report an error to.
+	p.logger.Log(&state.entry) // nolint: errcheck
+}
+
+func (p *processor) Shutdown() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.closed = true
+	close(p.done)
+
+	// finished requests are logged right now, they do not wait for
+	// their timers. Requests which are not finished are never going
+	// to be.
+	for requestID, state := range p.requests {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+
+		if state.finished {
+			p.log(requestID, state)
+		}
+	}
+
+	p.requests = map[string]*requestState{}
+}
+
+// NewProcessorFactory returns a factory of processors which join
+// events of each request into a single access log line written with
+// a given logger.
+//
+// Events are sharded by request ID so a single processor sees all
+// events of the request. A request is logged once it is finished and
+// its traffic is reported (or Opts.TrafficWait has passed).
+// Requests which are finished but still waiting for traffic are
+// logged on shutdown. Requests which are not finished for
+// Opts.RequestStateTTL are forgotten: each processor looks for them
+// in background until shutdown.
+func NewProcessorFactory(logger *Logger) events.ProcessorFactory {
+	return func() events.Processor {
+		rv := &processor{
+			logger:   logger,
+			requests: map[string]*requestState{},
+			done:     make(chan struct{}),
+		}
+
+		go rv.sweep()
+
+		return rv
+	}
+}
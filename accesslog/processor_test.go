@@ -0,0 +1,263 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/accesslog"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/stretchr/testify/suite"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.buf.String()
+}
+
+type ProcessorTestSuite struct {
+	suite.Suite
+
+	buf  *syncBuffer
+	now  time.Time
+	proc events.Processor
+}
+
+func (suite *ProcessorTestSuite) SetupTest() {
+	suite.buf = &syncBuffer{}
+	suite.now = time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		Format:      accesslog.FormatCombined,
+		TrafficWait: time.Hour,
+	})
+}
+
+func (suite *ProcessorTestSuite) TearDownTest() {
+	suite.proc.Shutdown()
+}
+
+func (suite *ProcessorTestSuite) makeProcessor(opts accesslog.Opts) events.Processor {
+	opts.Writer = suite.buf
+
+	logger, err := accesslog.NewLogger(opts)
+	suite.Require().NoError(err)
+
+	return accesslog.NewProcessorFactory(logger)()
+}
+
+func (suite *ProcessorTestSuite) event(eventType events.EventType, value interface{}, offset time.Duration) events.Event {
+	return events.Event{
+		Type:  eventType,
+		Time:  suite.now.Add(offset),
+		Value: value,
+	}
+}
+
+func (suite *ProcessorTestSuite) start(id string) {
+	meta := &events.RequestMeta{
+		RequestID: id,
+		Method:    "GET",
+		User:      "user",
+		Addr:      &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000},
+		Protocol:  "HTTP/1.1",
+		Referer:   "http://example.com/",
+		UserAgent: `curl "7.0"`,
+	}
+
+	meta.URI.Parse(nil, []byte("http://example.com/path")) // nolint: errcheck
+
+	suite.proc.Process(suite.event(events.EventTypeStartRequest, meta, 0))
+}
+
+func (suite *ProcessorTestSuite) finish(id string) {
+	suite.proc.Process(suite.event(events.EventTypeFinishRequest, &events.ResponseMeta{
+		RequestID:  id,
+		StatusCode: 200,
+	}, 250*time.Millisecond))
+}
+
+func (suite *ProcessorTestSuite) traffic(id string) {
+	suite.proc.Process(suite.event(events.EventTypeTraffic, &events.TrafficMeta{
+		ID:           id,
+		ReadBytes:    1024,
+		WrittenBytes: 100,
+	}, 300*time.Millisecond))
+}
+
+func (suite *ProcessorTestSuite) TestCombined() {
+	suite.start("1")
+	suite.finish("1")
+	suite.Empty(suite.buf.String())
+
+	suite.traffic("1")
+	suite.Equal(`127.0.0.1 - user [03/Feb/2021:04:05:06 +0000] "GET http://example.com/path HTTP/1.1" 200 1024 "http://example.com/" "curl \"7.0\""`+"\n",
+		suite.buf.String())
+}
+
+func (suite *ProcessorTestSuite) TestTrafficBeforeFinish() {
+	suite.start("1")
+	suite.traffic("1")
+	suite.Empty(suite.buf.String())
+
+	suite.finish("1")
+	suite.Contains(suite.buf.String(), " 200 1024 ")
+}
+
+func (suite *ProcessorTestSuite) TestLostFinish() {
+	suite.proc.Shutdown()
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		TrafficWait:     -1,
+		RequestStateTTL: 50 * time.Millisecond,
+	})
+	suite.now = time.Now()
+
+	// requests are expired in background, not by events.
+	suite.start("1")
+	time.Sleep(200 * time.Millisecond)
+	suite.finish("1")
+	suite.Empty(suite.buf.String())
+
+	suite.now = time.Now()
+
+	suite.start("2")
+	suite.finish("2")
+	suite.Contains(suite.buf.String(), `"request_id":"2"`)
+}
+
+func (suite *ProcessorTestSuite) TestCommon() {
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		Format:      accesslog.FormatCommon,
+		TrafficWait: -1,
+	})
+
+	suite.start("1")
+	suite.finish("1")
+	suite.Equal(`127.0.0.1 - user [03/Feb/2021:04:05:06 +0000] "GET http://example.com/path HTTP/1.1" 200 -`+"\n",
+		suite.buf.String())
+}
+
+func (suite *ProcessorTestSuite) TestJSON() {
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		TrafficWait: time.Hour,
+	})
+
+	suite.start("1")
+	suite.proc.Process(suite.event(events.EventTypeFailedRequest, &events.ErrorMeta{
+		RequestID: "1",
+		Err:       errors.New("unexpected"),
+	}, 0))
+	suite.finish("1")
+	suite.traffic("1")
+
+	value := map[string]interface{}{}
+
+	suite.NoError(json.Unmarshal([]byte(suite.buf.String()), &value))
+	suite.Equal("1", value["request_id"])
+	suite.Equal("127.0.0.1:5000", value["addr"])
+	suite.Equal("http://example.com/path", value["url"])
+	suite.Equal("unexpected", value["error"])
+	suite.EqualValues(200, value["status_code"])
+	suite.EqualValues(250, value["duration_ms"])
+	suite.EqualValues(1024, value["read_bytes"])
+	suite.EqualValues(100, value["written_bytes"])
+}
+
+func (suite *ProcessorTestSuite) TestTemplate() {
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		Format:      accesslog.FormatTemplate,
+		Template:    "{{ .RequestID }} {{ .Host }} {{ .StatusCode }} {{ .Duration }}",
+		TrafficWait: -1,
+	})
+
+	suite.start("1")
+	suite.finish("1")
+	suite.Equal("1 127.0.0.1 200 250ms\n", suite.buf.String())
+}
+
+func (suite *ProcessorTestSuite) TestIncorrectTemplate() {
+	_, err := accesslog.NewLogger(accesslog.Opts{
+		Format:   accesslog.FormatTemplate,
+		Template: "{{ .RequestID",
+	})
+
+	suite.Error(err)
+}
+
+func (suite *ProcessorTestSuite) TestTrafficWait() {
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		Format:      accesslog.FormatCommon,
+		TrafficWait: 10 * time.Millisecond,
+	})
+
+	suite.start("1")
+	suite.finish("1")
+	suite.Empty(suite.buf.String())
+
+	suite.Eventually(func() bool {
+		return suite.buf.String() != ""
+	}, time.Second, 10*time.Millisecond)
+	suite.Contains(suite.buf.String(), " 200 -\n")
+
+	suite.traffic("1")
+	suite.Equal(1, bytes.Count([]byte(suite.buf.String()), []byte("\n")))
+}
+
+func (suite *ProcessorTestSuite) TestShutdown() {
+	suite.start("1")
+	suite.start("2")
+	suite.finish("2")
+	suite.proc.Shutdown()
+
+	suite.Contains(suite.buf.String(), " 200 - ")
+	suite.Equal(1, bytes.Count([]byte(suite.buf.String()), []byte("\n")))
+}
+
+func (suite *ProcessorTestSuite) TestShutdownStopsTimers() {
+	suite.proc.Shutdown()
+	suite.proc = suite.makeProcessor(accesslog.Opts{
+		Format:      accesslog.FormatCommon,
+		TrafficWait: 50 * time.Millisecond,
+	})
+
+	suite.start("1")
+	suite.finish("1")
+	suite.proc.Shutdown()
+
+	suite.Contains(suite.buf.String(), " 200 -\n")
+
+	time.Sleep(100 * time.Millisecond)
+
+	suite.Equal(1, bytes.Count([]byte(suite.buf.String()), []byte("\n")))
+}
+
+func (suite *ProcessorTestSuite) TestFinishAfterShutdown() {
+	suite.start("1")
+	suite.proc.Shutdown()
+	suite.start("2")
+	suite.finish("2")
+
+	suite.Contains(suite.buf.String(), " 200 - ")
+	suite.Equal(1, bytes.Count([]byte(suite.buf.String()), []byte("\n")))
+}
+
+func TestProcessor(t *testing.T) {
+	suite.Run(t, &ProcessorTestSuite{})
+}
@@ -0,0 +1,148 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// DefaultMaxFiles defines how many rotated files are kept if user
+// provides no value.
+const DefaultMaxFiles = 5
+
+// RotatingFileOpts defines a set of options for RotatingFile.
+type RotatingFileOpts struct {
+	// Path is a path to the log file.
+	Path string
+
+	// MaxSize defines a max size of the file in bytes. Once it is
+	// reached, file is rotated. 0 means that file is never rotated.
+	MaxSize uint
+
+	// MaxFiles defines how many rotated files are kept. Rotated files
+	// are named like <path>.1, <path>.2 and so on where <path>.1 is
+	// the most recent one.
+	MaxFiles uint
+}
+
+// GetMaxFiles returns a number of rotated files to keep or fallbacks
+// to default one.
+func (r *RotatingFileOpts) GetMaxFiles() int {
+	if r.MaxFiles == 0 {
+		return DefaultMaxFiles
+	}
+
+	return int(r.MaxFiles)
+}
+
+// RotatingFile is an io.WriteCloser which appends to the file and
+// rotates it by size. Each Write goes into a single file so lines
+// are never split between files.
+//
+// RotatingFile is safe for concurrent use.
+type RotatingFile struct {
+	opts   RotatingFileOpts
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// Write conforms io.Writer interface.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, ErrFileClosed
+	}
+
+	if r.opts.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > int64(r.opts.MaxSize) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err // nolint: wrapcheck
+}
+
+// Close conforms io.Closer interface. It is safe to call it several
+// times.
+func (r *RotatingFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	return r.file.Close() // nolint: wrapcheck
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("cannot close a file: %w", err)
+	}
+
+	maxFiles := r.opts.GetMaxFiles()
+
+	os.Remove(r.rotatedPath(maxFiles)) // nolint: errcheck
+
+	for i := maxFiles - 1; i > 0; i-- {
+		os.Rename(r.rotatedPath(i), r.rotatedPath(i+1)) // nolint: errcheck
+	}
+
+	if err := os.Rename(r.opts.Path, r.rotatedPath(1)); err != nil {
+		return fmt.Errorf("cannot rotate a file: %w", err)
+	}
+
+	return r.open()
+}
+
+func (r *RotatingFile) rotatedPath(index int) string {
+	return r.opts.Path + "." + strconv.Itoa(index)
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644) // nolint: gomnd
+	if err != nil {
+		// we cannot write anymore.
+		r.closed = true
+
+		return fmt.Errorf("cannot open a file: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		r.closed = true
+
+		return fmt.Errorf("cannot stat a file: %w", err)
+	}
+
+	r.file = file
+	r.size = stat.Size()
+
+	return nil
+}
+
+// NewRotatingFile opens a file for appending. It creates the file if
+// necessary.
+func NewRotatingFile(opts RotatingFileOpts) (*RotatingFile, error) {
+	rv := &RotatingFile{
+		opts: opts,
+	}
+
+	if err := rv.open(); err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
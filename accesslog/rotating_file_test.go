@@ -0,0 +1,100 @@
+package accesslog_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/accesslog"
+	"github.com/stretchr/testify/suite"
+)
+
+type RotatingFileTestSuite struct {
+	suite.Suite
+
+	dir  string
+	path string
+}
+
+func (suite *RotatingFileTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "httransform-accesslog")
+	suite.Require().NoError(err)
+
+	suite.dir = dir
+	suite.path = filepath.Join(dir, "access.log")
+}
+
+func (suite *RotatingFileTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *RotatingFileTestSuite) read(path string) string {
+	data, err := ioutil.ReadFile(path)
+	suite.NoError(err)
+
+	return string(data)
+}
+
+func (suite *RotatingFileTestSuite) TestNoRotation() {
+	file, err := accesslog.NewRotatingFile(accesslog.RotatingFileOpts{
+		Path: suite.path,
+	})
+	suite.Require().NoError(err)
+
+	file.Write([]byte("line1\n"))
+	file.Write([]byte("line2\n"))
+
+	suite.NoError(file.Close())
+	suite.NoError(file.Close())
+	suite.Equal("line1\nline2\n", suite.read(suite.path))
+
+	_, err = file.Write([]byte("line3\n"))
+
+	suite.Error(err)
+}
+
+func (suite *RotatingFileTestSuite) TestAppend() {
+	suite.NoError(ioutil.WriteFile(suite.path, []byte("line0\n"), 0644))
+
+	file, err := accesslog.NewRotatingFile(accesslog.RotatingFileOpts{
+		Path:    suite.path,
+		MaxSize: 10,
+	})
+	suite.Require().NoError(err)
+
+	defer file.Close()
+
+	file.Write([]byte("line1\n"))
+
+	suite.Equal("line1\n", suite.read(suite.path))
+	suite.Equal("line0\n", suite.read(suite.path+".1"))
+}
+
+func (suite *RotatingFileTestSuite) TestRotation() {
+	file, err := accesslog.NewRotatingFile(accesslog.RotatingFileOpts{
+		Path:     suite.path,
+		MaxSize:  12,
+		MaxFiles: 2,
+	})
+	suite.Require().NoError(err)
+
+	defer file.Close()
+
+	for _, v := range []string{"line1\n", "line2\n", "line3\n", "line4\n", "line5\n", "line6\n", "line7\n"} {
+		_, err := file.Write([]byte(v))
+		suite.NoError(err)
+	}
+
+	suite.Equal("line7\n", suite.read(suite.path))
+	suite.Equal("line5\nline6\n", suite.read(suite.path+".1"))
+	suite.Equal("line3\nline4\n", suite.read(suite.path+".2"))
+
+	_, err = os.Stat(suite.path + ".3")
+
+	suite.True(os.IsNotExist(err))
+}
+
+func TestRotatingFile(t *testing.T) {
+	suite.Run(t, &RotatingFileTestSuite{})
+}
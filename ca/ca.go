@@ -36,11 +36,21 @@ type CA struct {
 	lenWorkers uint64
 }
 
-// Get returns tls.Config instance for the given hostname.
+// Get returns tls.Config instance for the given hostname. If
+// certificate of the real netloc is required (see
+// Opts.UpstreamCertificate), it is fetched from
+// UpstreamCertificatePort.
 func (c *CA) Get(host string) (*tls.Config, error) {
+	return c.GetWithPort(host, UpstreamCertificatePort)
+}
+
+// GetWithPort returns tls.Config instance for the given hostname.
+// port is a port of the real netloc: it is used to fetch its
+// certificate if required.
+func (c *CA) GetWithPort(host, port string) (*tls.Config, error) {
 	chosenWorker := xxhash.ChecksumString64(host) % c.lenWorkers
 
-	conf, err := c.workers[int(chosenWorker)].Get(host)
+	conf, err := c.workers[int(chosenWorker)].Get(host, port)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get tls config for host: %w", err)
 	}
@@ -52,7 +62,8 @@ func (c *CA) Get(host string) (*tls.Config, error) {
 func NewCA(ctx context.Context,
 	eventStream events.Stream,
 	certCA []byte,
-	privateKey []byte) (*CA, error) {
+	privateKey []byte,
+	opts Opts) (*CA, error) {
 	ca, err := tls.X509KeyPair(certCA, privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot make a x509 keypair: %w", err)
@@ -62,6 +73,10 @@ func NewCA(ctx context.Context,
 		return nil, fmt.Errorf("invalid certificates: %w", err)
 	}
 
+	if opts.KeyType > KeyTypeEd25519 {
+		return nil, fmt.Errorf("unsupported key type %v", opts.KeyType)
+	}
+
 	obj := &CA{
 		workers:    make([]worker, 0, runtime.NumCPU()),
 		lenWorkers: uint64(runtime.NumCPU()),
@@ -69,6 +84,13 @@ func NewCA(ctx context.Context,
 	cacheIf := cache.New(CACacheSize, CACacheTTL, func(key string, _ interface{}) {
 		eventStream.Send(ctx, events.EventTypeDropCertificate, key, key)
 	})
+	store := opts.GetCertStore()
+
+	var upstreams *upstreamFetcher
+
+	if opts.UpstreamCertificate != nil {
+		upstreams = newUpstreamFetcher(opts.UpstreamCertificate)
+	}
 
 	for i := 0; i < runtime.NumCPU(); i++ {
 		wrk := worker{
@@ -76,6 +98,9 @@ func NewCA(ctx context.Context,
 			ctx:             ctx,
 			eventStream:     eventStream,
 			cache:           cacheIf,
+			store:           store,
+			opts:            &opts,
+			upstreams:       upstreams,
 			channelRequests: make(chan workerRequest),
 		}
 
@@ -2,11 +2,17 @@ package ca_test
 
 import (
 	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/9seconds/httransform/v2/ca"
 	"github.com/9seconds/httransform/v2/events"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -64,7 +70,7 @@ func (suite *CATestSuite) SetupTest() {
 	ctx, cancel := context.WithCancel(context.Background())
 	suite.cancel = cancel
 	suite.mockedEventChannel = &EventChannelMock{}
-	suite.ca, _ = ca.NewCA(ctx, suite.mockedEventChannel, CACert, PrivateKey)
+	suite.ca, _ = ca.NewCA(ctx, suite.mockedEventChannel, CACert, PrivateKey, ca.Opts{})
 }
 
 func (suite *CATestSuite) TearDownTest() {
@@ -99,6 +105,277 @@ func (suite *CATestSuite) TestDoubleGet() {
 	suite.Equal("hostname.com", conf1.ServerName)
 }
 
+type CAOptsTestSuite struct {
+	suite.Suite
+
+	ctx                context.Context
+	cancel             context.CancelFunc
+	mockedEventChannel *EventChannelMock
+}
+
+func (suite *CAOptsTestSuite) SetupTest() {
+	suite.ctx, suite.cancel = context.WithCancel(context.Background())
+	suite.mockedEventChannel = &EventChannelMock{}
+	suite.mockedEventChannel.On("Send",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything).Maybe()
+}
+
+func (suite *CAOptsTestSuite) TearDownTest() {
+	suite.cancel()
+}
+
+func (suite *CAOptsTestSuite) get(host string, opts ca.Opts) *x509.Certificate {
+	certAuth, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, opts)
+
+	suite.Require().NoError(err)
+
+	conf, err := certAuth.Get(host)
+
+	suite.Require().NoError(err)
+
+	leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+
+	suite.Require().NoError(err)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(CACert)
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName: host,
+		Roots:   roots,
+	})
+
+	suite.NoError(err)
+
+	return leaf
+}
+
+func (suite *CAOptsTestSuite) TestKeyTypes() {
+	testData := map[ca.KeyType]x509.PublicKeyAlgorithm{
+		ca.KeyTypeRSA:       x509.RSA,
+		ca.KeyTypeECDSAP256: x509.ECDSA,
+		ca.KeyTypeECDSAP384: x509.ECDSA,
+		ca.KeyTypeEd25519:   x509.Ed25519,
+	}
+
+	for k, v := range testData {
+		keyType := k
+		algorithm := v
+
+		suite.T().Run(keyType.String(), func(t *testing.T) {
+			leaf := suite.get("hostname.com", ca.Opts{KeyType: keyType})
+
+			assert.Equal(t, algorithm, leaf.PublicKeyAlgorithm)
+		})
+	}
+}
+
+func (suite *CAOptsTestSuite) TestUnknownKeyType() {
+	_, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, ca.Opts{
+		KeyType: ca.KeyTypeEd25519 + 1,
+	})
+
+	suite.Error(err)
+}
+
+func (suite *CAOptsTestSuite) TestExtraFields() {
+	leaf := suite.get("hostname.com", ca.Opts{
+		KeyType:     ca.KeyTypeECDSAP256,
+		Validity:    48 * time.Hour,
+		Subject:     pkix.Name{Organization: []string{"httransform"}},
+		DNSNames:    []string{"extra.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	})
+
+	suite.Equal("hostname.com", leaf.Subject.CommonName)
+	suite.Equal([]string{"httransform"}, leaf.Subject.Organization)
+	suite.ElementsMatch([]string{"extra.com", "hostname.com"}, leaf.DNSNames)
+	suite.Len(leaf.IPAddresses, 1)
+	suite.True(leaf.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")))
+	suite.WithinDuration(time.Now().Add(48*time.Hour), leaf.NotAfter, time.Minute)
+}
+
+func (suite *CAOptsTestSuite) TestTemplate() {
+	upstream := &x509.Certificate{
+		Subject: pkix.Name{Organization: []string{"Upstream Inc"}},
+	}
+
+	leaf := suite.get("hostname.com", ca.Opts{
+		KeyType: ca.KeyTypeEd25519,
+		UpstreamCertificate: func(host, port string) (*x509.Certificate, error) {
+			suite.Equal("hostname.com", host)
+			suite.Equal(ca.UpstreamCertificatePort, port)
+
+			return upstream, nil
+		},
+		Template: func(host string, template, upstreamCert *x509.Certificate) {
+			suite.Equal("hostname.com", host)
+			suite.Equal(upstream, upstreamCert)
+
+			template.Subject.Organization = upstreamCert.Subject.Organization
+		},
+	})
+
+	suite.Equal([]string{"Upstream Inc"}, leaf.Subject.Organization)
+}
+
+func (suite *CAOptsTestSuite) TestUpstreamPort() {
+	certAuth, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, ca.Opts{
+		UpstreamCertificate: func(host, port string) (*x509.Certificate, error) {
+			suite.Equal("hostname.com", host)
+			suite.Equal("8443", port)
+
+			return &x509.Certificate{}, nil
+		},
+		Template: func(_ string, _, _ *x509.Certificate) {},
+	})
+
+	suite.Require().NoError(err)
+
+	_, err = certAuth.GetWithPort("hostname.com", "8443")
+
+	suite.NoError(err)
+}
+
+func (suite *CAOptsTestSuite) TestUpstreamConcurrentFetch() {
+	calls := int32(0)
+	called := make(chan struct{})
+	release := make(chan struct{})
+
+	certAuth, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, ca.Opts{
+		KeyType: ca.KeyTypeEd25519,
+		UpstreamCertificate: func(_, _ string) (*x509.Certificate, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(called)
+			}
+
+			<-release
+
+			return &x509.Certificate{}, nil
+		},
+		Template: func(_ string, _, _ *x509.Certificate) {},
+	})
+
+	suite.Require().NoError(err)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := certAuth.Get("hostname.com")
+
+			suite.NoError(err)
+		}()
+	}
+
+	<-called
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	suite.EqualValues(1, atomic.LoadInt32(&calls))
+}
+
+func (suite *CAOptsTestSuite) TestUpstreamFailureIsCachedShortly() {
+	calls := int32(0)
+	upstream := &x509.Certificate{
+		Subject: pkix.Name{Organization: []string{"Upstream Inc"}},
+	}
+	opts := ca.Opts{
+		KeyType: ca.KeyTypeEd25519,
+		UpstreamCertificate: func(_, _ string) (*x509.Certificate, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return nil, ca.ErrNoUpstreamCertificate
+			}
+
+			return upstream, nil
+		},
+		Template: func(_ string, template, upstreamCert *x509.Certificate) {
+			if upstreamCert != nil {
+				template.Subject.Organization = upstreamCert.Subject.Organization
+			}
+		},
+	}
+
+	opts.FallbackCacheTTL = 300 * time.Millisecond
+
+	certAuth, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, opts)
+
+	suite.Require().NoError(err)
+
+	conf, err := certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Empty(conf.Certificates[0].Leaf.Subject.Organization)
+
+	conf, err = certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Empty(conf.Certificates[0].Leaf.Subject.Organization)
+	suite.EqualValues(1, atomic.LoadInt32(&calls))
+
+	time.Sleep(500 * time.Millisecond)
+
+	conf, err = certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Equal([]string{"Upstream Inc"}, conf.Certificates[0].Leaf.Subject.Organization)
+	suite.EqualValues(2, atomic.LoadInt32(&calls))
+}
+
+func (suite *CAOptsTestSuite) TestCacheTTLIsCappedByNotAfter() {
+	certAuth, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, ca.Opts{
+		KeyType: ca.KeyTypeEd25519,
+		Template: func(_ string, template, _ *x509.Certificate) {
+			template.NotAfter = time.Now().Add(2 * time.Second)
+		},
+	})
+
+	suite.Require().NoError(err)
+
+	conf1, err := certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+
+	time.Sleep(2500 * time.Millisecond)
+
+	conf2, err := certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+	suite.NotEqual(conf1.Certificates[0].Certificate, conf2.Certificates[0].Certificate)
+}
+
+func (suite *CAOptsTestSuite) TestExpiredCertificateIsCached() {
+	certAuth, err := ca.NewCA(suite.ctx, suite.mockedEventChannel, CACert, PrivateKey, ca.Opts{
+		KeyType: ca.KeyTypeEd25519,
+		Template: func(_ string, template, _ *x509.Certificate) {
+			template.NotAfter = time.Now().Add(-time.Hour)
+		},
+	})
+
+	suite.Require().NoError(err)
+
+	conf1, err := certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+
+	conf2, err := certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Equal(conf1.Certificates[0].Certificate, conf2.Certificates[0].Certificate)
+}
+
 func TestCA(t *testing.T) {
 	suite.Run(t, &CATestSuite{})
 }
+
+func TestCAOpts(t *testing.T) {
+	suite.Run(t, &CAOptsTestSuite{})
+}
@@ -0,0 +1,74 @@
+package ca
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/9seconds/httransform/v2/cache"
+)
+
+// CertStore defines a storage for generated TLS certificates. Each
+// certificate is stored for a certain key which is made of a hostname
+// and a fingerprint of CA options (see Opts.StoreKey). So certificates
+// which were generated with other options are never reused.
+//
+// CA does not trust a store blindly: it verifies that returned
+// certificate is signed by its own CA certificate and not expired. So
+// it is safe to share the same store between instances with different
+// CA certificates.
+//
+// Implementations have to be safe for concurrent use.
+type CertStore interface {
+	// Get returns a certificate for the given key. If there is no
+	// certificate, it returns nil and no error.
+	Get(key string) (*tls.Certificate, error)
+
+	// Put stores a certificate for the given key. Failures are not
+	// fatal: CA reports them with events.EventTypeCertificateStoreError
+	// and uses a certificate anyway.
+	Put(key string, cert *tls.Certificate) error
+}
+
+// NoopCertStore is a certificate store which keeps nothing. CA has its
+// own in-memory cache of certificates so this is a default store.
+type NoopCertStore struct{}
+
+// Get conforms CertStore interface.
+func (n NoopCertStore) Get(key string) (*tls.Certificate, error) {
+	return nil, nil
+}
+
+// Put conforms CertStore interface.
+func (n NoopCertStore) Put(key string, cert *tls.Certificate) error {
+	return nil
+}
+
+type memoryCertStore struct {
+	cache cache.Interface
+}
+
+func (m memoryCertStore) Get(key string) (*tls.Certificate, error) {
+	if cert := m.cache.Get(key); cert != nil {
+		return cert.(*tls.Certificate), nil
+	}
+
+	return nil, nil
+}
+
+func (m memoryCertStore) Put(key string, cert *tls.Certificate) error {
+	m.cache.Add(key, cert)
+
+	return nil
+}
+
+// NewMemoryCertStore returns a certificate store which keeps
+// certificates in memory. This store is backed by LFU cache of the
+// given size and each certificate is kept there for ttl.
+//
+// CA caches certificates in memory anyway so this store makes sense
+// only if it is shared between several CA instances.
+func NewMemoryCertStore(size int, ttl time.Duration) CertStore {
+	return memoryCertStore{
+		cache: cache.New(size, ttl, cache.NoopEvictCallback),
+	}
+}
@@ -0,0 +1,142 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	dirCertStoreFileMode = 0600
+	dirCertStoreDirMode  = 0700
+
+	// dirCertStoreKeyHeader is a PEM header of the certificate which
+	// keeps its store key.
+	dirCertStoreKeyHeader = "Store-Key"
+)
+
+type dirCertStore struct {
+	dir string
+}
+
+func (d dirCertStore) Get(key string) (*tls.Certificate, error) {
+	data, err := ioutil.ReadFile(d.path(key))
+
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("cannot read certificate file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("certificate file has no PEM data") // nolint: goerr113
+	}
+
+	// file name is a hash of the key so it has to be verified.
+	if block.Headers[dirCertStoreKeyHeader] != key {
+		return nil, nil
+	}
+
+	// file contains both certificate chain and private key. Both
+	// functions skip PEM blocks of other types.
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse certificate file: %w", err)
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	return &cert, nil
+}
+
+func (d dirCertStore) Put(key string, cert *tls.Certificate) error {
+	buf := bytes.Buffer{}
+
+	for i, v := range cert.Certificate {
+		block := &pem.Block{Type: "CERTIFICATE", Bytes: v}
+
+		if i == 0 {
+			block.Headers = map[string]string{
+				dirCertStoreKeyHeader: key,
+			}
+		}
+
+		if err := pem.Encode(&buf, block); err != nil {
+			return fmt.Errorf("cannot encode certificate: %w", err)
+		}
+	}
+
+	privateKey, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("cannot marshal private key: %w", err)
+	}
+
+	pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: privateKey}) // nolint: errcheck
+
+	// a file is written in 2 steps so other replicas which share the
+	// same directory never read partially written certificate.
+	tmpFile, err := ioutil.TempFile(d.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file: %w", err)
+	}
+
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(buf.Bytes()); err != nil {
+		tmpFile.Close()
+
+		return fmt.Errorf("cannot write certificate: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tmpFile.Name(), dirCertStoreFileMode); err != nil {
+		return fmt.Errorf("cannot set file permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), d.path(key)); err != nil {
+		return fmt.Errorf("cannot move certificate file: %w", err)
+	}
+
+	return nil
+}
+
+// path returns a path to the certificate file. Keys can be long and
+// contain any characters so file name is a hash of the key. Key itself
+// is stored in the file.
+func (d dirCertStore) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return filepath.Join(d.dir, hex.EncodeToString(hash[:])+".pem")
+}
+
+// NewDirCertStore returns a certificate store which keeps certificates
+// in a given directory. Each certificate is stored in its own PEM file
+// together with its chain and private key so certificates survive
+// restarts and can be shared between several proxy instances. File
+// is named after a SHA-256 hash of the key.
+//
+// Please pay attention that private keys are stored unencrypted, so
+// this directory has to be protected.
+func NewDirCertStore(dir string) (CertStore, error) {
+	if err := os.MkdirAll(dir, dirCertStoreDirMode); err != nil {
+		return nil, fmt.Errorf("cannot create a directory: %w", err)
+	}
+
+	return dirCertStore{
+		dir: dir,
+	}, nil
+}
@@ -0,0 +1,225 @@
+package ca_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/ca"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DirCertStoreTestSuite struct {
+	suite.Suite
+
+	dir                string
+	store              ca.CertStore
+	mockedEventChannel *EventChannelMock
+	ctx                context.Context
+	cancel             context.CancelFunc
+}
+
+func (suite *DirCertStoreTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "httransform-certs-")
+
+	suite.NoError(err)
+
+	suite.dir = dir
+	suite.store, err = ca.NewDirCertStore(dir)
+
+	suite.NoError(err)
+
+	suite.ctx, suite.cancel = context.WithCancel(context.Background())
+	suite.mockedEventChannel = &EventChannelMock{}
+	suite.mockedEventChannel.On("Send",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything).Maybe()
+}
+
+func (suite *DirCertStoreTestSuite) TearDownTest() {
+	suite.cancel()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *DirCertStoreTestSuite) makeCA() *ca.CA {
+	return suite.makeCAWithOpts(ca.Opts{})
+}
+
+func (suite *DirCertStoreTestSuite) makeCAWithOpts(opts ca.Opts) *ca.CA {
+	opts.CertStore = suite.store
+
+	certAuth, err := ca.NewCA(suite.ctx,
+		suite.mockedEventChannel,
+		CACert,
+		PrivateKey,
+		opts)
+
+	suite.NoError(err)
+
+	return certAuth
+}
+
+func (suite *DirCertStoreTestSuite) key(host string) string {
+	return (&ca.Opts{}).StoreKey(host)
+}
+
+func (suite *DirCertStoreTestSuite) TestMissing() {
+	cert, err := suite.store.Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Nil(cert)
+}
+
+func (suite *DirCertStoreTestSuite) TestSurviveRestart() {
+	conf1, err := suite.makeCA().Get("hostname.com")
+
+	suite.NoError(err)
+
+	conf2, err := suite.makeCA().Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Equal(conf1.Certificates[0].Certificate, conf2.Certificates[0].Certificate)
+	suite.Equal(conf1.Certificates[0].PrivateKey, conf2.Certificates[0].PrivateKey)
+
+	stored, err := suite.store.Get(suite.key("hostname.com"))
+
+	suite.NoError(err)
+	suite.Equal(conf1.Certificates[0].Certificate, stored.Certificate)
+	suite.NotNil(stored.Leaf)
+}
+
+func (suite *DirCertStoreTestSuite) TestCorrupted() {
+	hash := sha256.Sum256([]byte(suite.key("hostname.com")))
+
+	suite.NoError(ioutil.WriteFile(filepath.Join(suite.dir, hex.EncodeToString(hash[:])+".pem"),
+		[]byte("garbage"), 0600))
+
+	_, err := suite.store.Get(suite.key("hostname.com"))
+
+	suite.Error(err)
+
+	conf, err := suite.makeCA().Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Equal("hostname.com", conf.Certificates[0].Leaf.Subject.CommonName)
+
+	stored, err := suite.store.Get(suite.key("hostname.com"))
+
+	suite.NoError(err)
+	suite.Equal(conf.Certificates[0].Certificate, stored.Certificate)
+}
+
+func (suite *DirCertStoreTestSuite) TestIPAddress() {
+	conf, err := suite.makeCA().Get("127.0.0.1")
+
+	suite.NoError(err)
+
+	stored, err := suite.store.Get(suite.key("127.0.0.1"))
+
+	suite.NoError(err)
+	suite.Equal(conf.Certificates[0].Certificate, stored.Certificate)
+}
+
+func (suite *DirCertStoreTestSuite) TestOptionsChanged() {
+	conf1, err := suite.makeCA().Get("hostname.com")
+
+	suite.NoError(err)
+
+	conf2, err := suite.makeCAWithOpts(ca.Opts{
+		Subject: pkix.Name{
+			Organization: []string{"httransform"},
+		},
+	}).Get("hostname.com")
+
+	suite.NoError(err)
+	suite.NotEqual(conf1.Certificates[0].Certificate, conf2.Certificates[0].Certificate)
+	suite.Equal([]string{"httransform"}, conf2.Certificates[0].Leaf.Subject.Organization)
+
+	conf3, err := suite.makeCAWithOpts(ca.Opts{
+		Subject: pkix.Name{
+			Organization: []string{"httransform"},
+		},
+		Template:        func(_ string, _, _ *x509.Certificate) {},
+		TemplateVersion: "1",
+	}).Get("hostname.com")
+
+	suite.NoError(err)
+	suite.NotEqual(conf2.Certificates[0].Certificate, conf3.Certificates[0].Certificate)
+
+	conf4, err := suite.makeCA().Get("hostname.com")
+
+	suite.NoError(err)
+	suite.Equal(conf1.Certificates[0].Certificate, conf4.Certificates[0].Certificate)
+}
+
+func (suite *DirCertStoreTestSuite) TestLongKey() {
+	conf, err := suite.makeCA().Get("hostname.com")
+
+	suite.NoError(err)
+
+	key := strings.Repeat("a", 1024)
+
+	suite.NoError(suite.store.Put(key, &conf.Certificates[0]))
+
+	stored, err := suite.store.Get(key)
+
+	suite.NoError(err)
+	suite.Equal(conf.Certificates[0].Certificate, stored.Certificate)
+
+	stored, err = suite.store.Get(strings.Repeat("b", 1024))
+
+	suite.NoError(err)
+	suite.Nil(stored)
+}
+
+type failingCertStore struct{}
+
+func (failingCertStore) Get(_ string) (*tls.Certificate, error) {
+	return nil, nil
+}
+
+func (failingCertStore) Put(_ string, _ *tls.Certificate) error {
+	return errors.New("failure")
+}
+
+func (suite *DirCertStoreTestSuite) TestPutFailure() {
+	eventChannel := &EventChannelMock{}
+	eventChannel.On("Send",
+		mock.Anything,
+		events.EventTypeNewCertificate,
+		"hostname.com",
+		"hostname.com").Once()
+	eventChannel.On("Send",
+		mock.Anything,
+		events.EventTypeCertificateStoreError,
+		mock.AnythingOfType("*events.CertificateStoreErrorMeta"),
+		"hostname.com").Once()
+
+	certAuth, err := ca.NewCA(suite.ctx, eventChannel, CACert, PrivateKey, ca.Opts{
+		CertStore: failingCertStore{},
+	})
+
+	suite.Require().NoError(err)
+
+	_, err = certAuth.Get("hostname.com")
+
+	suite.NoError(err)
+	eventChannel.AssertExpectations(suite.T())
+}
+
+func TestDirCertStore(t *testing.T) {
+	suite.Run(t, &DirCertStoreTestSuite{})
+}
@@ -8,6 +8,14 @@
 // The certificates are generated in determenistic way derived from your
 // CA private key so please keep it is secret.
 //
+// Certificate storage
+//
+// Generated certificates are cached in memory and kept in CertStore.
+// By default this store keeps nothing so each restart means that
+// certificates are regenerated. If you want to have stable certificates between
+// restarts or share them between several proxy instances, please use
+// NewDirCertStore or your own implementation of CertStore.
+//
 // How to generate your own pair
 //
 // To generate your own set of CA certificate and private key, please
@@ -8,3 +8,9 @@ import "github.com/9seconds/httransform/v2/errors"
 var ErrContextClosed = &errors.Error{
 	Message: "context is closed",
 }
+
+// ErrNoUpstreamCertificate is returned if netloc has not presented any
+// certificate.
+var ErrNoUpstreamCertificate = &errors.Error{
+	Message: "netloc has not presented any certificate",
+}
@@ -0,0 +1,82 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// KeyType defines an algorithm of private keys for generated
+// certificates.
+type KeyType uint8
+
+// A list of supported key algorithms.
+const (
+	// KeyTypeRSA generates RSA keys of RSAKeyLength bits. This is the
+	// most compatible but the slowest option.
+	KeyTypeRSA KeyType = iota
+
+	// KeyTypeECDSAP256 generates ECDSA keys on P-256 curve.
+	KeyTypeECDSAP256
+
+	// KeyTypeECDSAP384 generates ECDSA keys on P-384 curve.
+	KeyTypeECDSAP384
+
+	// KeyTypeEd25519 generates Ed25519 keys. Please pay attention
+	// that some old clients do not support them.
+	KeyTypeEd25519
+)
+
+func (k KeyType) String() string {
+	switch k {
+	case KeyTypeRSA:
+		return "rsa"
+	case KeyTypeECDSAP256:
+		return "ecdsa-p256"
+	case KeyTypeECDSAP384:
+		return "ecdsa-p384"
+	case KeyTypeEd25519:
+		return "ed25519"
+	}
+
+	return fmt.Sprintf("unknown(%d)", uint8(k))
+}
+
+func (k KeyType) publicKeyAlgorithm() x509.PublicKeyAlgorithm {
+	switch k {
+	case KeyTypeECDSAP256, KeyTypeECDSAP384:
+		return x509.ECDSA
+	case KeyTypeEd25519:
+		return x509.Ed25519
+	}
+
+	return x509.RSA
+}
+
+func (k KeyType) keyUsage() x509.KeyUsage {
+	if k == KeyTypeRSA {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+
+	return x509.KeyUsageDigitalSignature
+}
+
+func (k KeyType) generate() (crypto.Signer, error) {
+	switch k {
+	case KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) // nolint: wrapcheck
+	case KeyTypeECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) // nolint: wrapcheck
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+
+		return key, err // nolint: wrapcheck
+	}
+
+	return rsa.GenerateKey(rand.Reader, RSAKeyLength) // nolint: wrapcheck
+}
@@ -0,0 +1,91 @@
+package ca
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dialers"
+)
+
+const (
+	// UpstreamCertificateTimeout defines a timeout for fetching a
+	// certificate of the real netloc.
+	UpstreamCertificateTimeout = 10 * time.Second
+
+	// UpstreamCertificatePort defines a port which is used to fetch a
+	// certificate of the real netloc if port is unknown (see CA.Get).
+	UpstreamCertificatePort = "443"
+)
+
+// MakeUpstreamCertificate returns a function which fetches a
+// certificate of the real netloc using a given dialer. It establishes
+// a new connection, makes TLS handshake and returns a leaf certificate
+// netloc has presented.
+//
+// This function is intended to be used as Opts.UpstreamCertificate.
+func MakeUpstreamCertificate(ctx context.Context, dialer dialers.Dialer) func(string, string) (*x509.Certificate, error) {
+	return func(host, port string) (*x509.Certificate, error) {
+		ctx, cancel := context.WithTimeout(ctx, UpstreamCertificateTimeout)
+		defer cancel()
+
+		conn, err := dialer.Dial(ctx, host, port)
+		if err != nil {
+			return nil, fmt.Errorf("cannot dial to netloc: %w", err)
+		}
+
+		defer conn.Close()
+
+		tlsConn, err := dialer.UpgradeToTLS(ctx, conn, host, port)
+		if err != nil {
+			return nil, fmt.Errorf("cannot upgrade connection to tls: %w", err)
+		}
+
+		defer tlsConn.Close()
+
+		state, ok := tlsConn.(interface{ ConnectionState() tls.ConnectionState })
+		if !ok {
+			return nil, ErrNoUpstreamCertificate
+		}
+
+		certs := state.ConnectionState().PeerCertificates
+		if len(certs) == 0 {
+			return nil, ErrNoUpstreamCertificate
+		}
+
+		return certs[0], nil
+	}
+}
+
+// MimicTemplate is a TemplateFunc which copies subject, SANs and
+// validity of the upstream certificate. If upstream certificate is
+// unknown, template is not modified.
+//
+// If upstream certificate does not cover a hostname, it is added to
+// SAN so clients still can connect.
+func MimicTemplate(host string, template, upstream *x509.Certificate) {
+	if upstream == nil {
+		return
+	}
+
+	template.Subject = upstream.Subject
+	template.DNSNames = append([]string{}, upstream.DNSNames...)
+	template.IPAddresses = append([]net.IP{}, upstream.IPAddresses...)
+	template.URIs = upstream.URIs
+	template.EmailAddresses = upstream.EmailAddresses
+	template.NotBefore = upstream.NotBefore
+	template.NotAfter = upstream.NotAfter
+
+	if template.VerifyHostname(host) == nil {
+		return
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+}
@@ -0,0 +1,97 @@
+package ca_test
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/ca"
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/stretchr/testify/suite"
+)
+
+type MimicTestSuite struct {
+	suite.Suite
+
+	srv    *httptest.Server
+	port   string
+	dialer dialers.Dialer
+}
+
+func (suite *MimicTestSuite) SetupSuite() {
+	suite.srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	addr, _ := url.Parse(suite.srv.URL)
+	suite.port = addr.Port()
+	suite.dialer = dialers.NewBase(dialers.Opts{TLSSkipVerify: true})
+}
+
+func (suite *MimicTestSuite) TearDownSuite() {
+	suite.srv.Close()
+}
+
+func (suite *MimicTestSuite) TestUpstreamCertificate() {
+	fetch := ca.MakeUpstreamCertificate(context.Background(), suite.dialer)
+
+	cert, err := fetch("127.0.0.1", suite.port)
+
+	suite.NoError(err)
+	suite.Equal(suite.srv.Certificate().Raw, cert.Raw)
+}
+
+func (suite *MimicTestSuite) TestMimicTemplate() {
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	upstream := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   "*.example.com",
+			Organization: []string{"Example"},
+		},
+		DNSNames:  []string{"*.example.com", "example.com"},
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+	}
+	template := &x509.Certificate{
+		DNSNames: []string{"www.example.com"},
+	}
+
+	ca.MimicTemplate("www.example.com", template, upstream)
+
+	suite.Equal(upstream.Subject, template.Subject)
+	suite.Equal(upstream.DNSNames, template.DNSNames)
+	suite.Equal(notBefore, template.NotBefore)
+	suite.Equal(notAfter, template.NotAfter)
+}
+
+func (suite *MimicTestSuite) TestMimicTemplateAddHostname() {
+	upstream := &x509.Certificate{
+		DNSNames: []string{"example.com"},
+	}
+	template := &x509.Certificate{}
+
+	ca.MimicTemplate("10.0.0.1", template, upstream)
+
+	suite.Equal([]string{"example.com"}, template.DNSNames)
+	suite.Len(template.IPAddresses, 1)
+	suite.True(template.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")))
+}
+
+func (suite *MimicTestSuite) TestMimicTemplateNoUpstream() {
+	template := &x509.Certificate{
+		DNSNames: []string{"example.com"},
+	}
+
+	ca.MimicTemplate("example.com", template, nil)
+
+	suite.Equal([]string{"example.com"}, template.DNSNames)
+}
+
+func TestMimic(t *testing.T) {
+	suite.Run(t, &MimicTestSuite{})
+}
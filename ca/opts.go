@@ -0,0 +1,155 @@
+package ca
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultValidity defines a default time period generated certificates
+// are valid for.
+const DefaultValidity = 90 * 24 * time.Hour
+
+// DefaultFallbackCacheTTL defines a default time period certificates
+// which were generated without upstream one are cached for.
+const DefaultFallbackCacheTTL = time.Minute
+
+const storeKeyFingerprintLength = 8
+
+// TemplateFunc is a callback which can modify a template of the
+// certificate before it is signed. host is a hostname certificate is
+// generated for. upstream is a certificate of the real netloc if it is
+// known (see Opts.UpstreamCertificate), nil otherwise.
+//
+// Callback may change any field of the template but should not touch
+// a public key: it is generated by CA.
+type TemplateFunc func(host string, template, upstream *x509.Certificate)
+
+// Opts defines a set of options for CA.
+//
+// Each field is optional, we provide sane defaults.
+type Opts struct {
+	// CertStore defines a storage for generated certificates. CA
+	// looks up a certificate there before generating a new one and
+	// puts each generated certificate there.
+	//
+	// By default certificates are not stored anywhere: they live
+	// only in a cache of CA so they are regenerated on each restart.
+	CertStore CertStore
+
+	// KeyType defines an algorithm of private keys. RSA is used by
+	// default but ECDSA and Ed25519 keys are generated much faster.
+	KeyType KeyType
+
+	// Validity defines a time period generated certificates are valid
+	// for.
+	Validity time.Duration
+
+	// Subject defines a subject of generated certificates.
+	// CommonName is always set to a hostname.
+	Subject pkix.Name
+
+	// DNSNames defines a list of extra DNS names which are added to
+	// SAN of each generated certificate.
+	DNSNames []string
+
+	// IPAddresses defines a list of extra IP addresses which are
+	// added to SAN of each generated certificate.
+	IPAddresses []net.IP
+
+	// UpstreamCertificate is a function which returns a certificate
+	// of the real netloc for the given hostname and port. Its result
+	// is passed to Template. If it returns an error, Template gets nil
+	// and generated certificate is cached only for FallbackCacheTTL.
+	//
+	// This function is called outside of certificate generation and
+	// concurrent calls for the same address are merged.
+	UpstreamCertificate func(host, port string) (*x509.Certificate, error)
+
+	// FallbackCacheTTL defines for how long certificates which were
+	// generated without upstream one are cached. It is also used for
+	// certificates which are already expired: Template may copy
+	// NotAfter of upstream certificate. So CA does not fetch upstream
+	// certificate and sign a new one on each TLS handshake.
+	FallbackCacheTTL time.Duration
+
+	// Template is a callback which can modify a certificate before it
+	// is signed.
+	Template TemplateFunc
+
+	// TemplateVersion is a version of Template callback. Stored
+	// certificates are bound to options they were generated with but
+	// CA cannot detect if Template function behaves differently now.
+	// Please change this value if Template produces different
+	// certificates: otherwise stored ones are reused.
+	TemplateVersion string
+}
+
+// StoreKey returns a key of the certificate for the given hostname in
+// CertStore. It includes a fingerprint of options which affect
+// generated certificates so certificates are regenerated if options
+// are changed.
+func (o *Opts) StoreKey(host string) string {
+	hasher := sha256.New()
+
+	fmt.Fprintln(hasher, o.GetKeyType().String())
+	fmt.Fprintln(hasher, o.GetValidity().String())
+
+	if o != nil {
+		fmt.Fprintln(hasher, o.Subject.String())
+		fmt.Fprintln(hasher, strings.Join(o.DNSNames, ","))
+
+		for _, v := range o.IPAddresses {
+			fmt.Fprint(hasher, v.String(), ",")
+		}
+
+		fmt.Fprintln(hasher)
+		fmt.Fprintln(hasher, o.UpstreamCertificate != nil, o.Template != nil, o.TemplateVersion)
+	}
+
+	return host + "@" + hex.EncodeToString(hasher.Sum(nil)[:storeKeyFingerprintLength])
+}
+
+// GetCertStore returns a certificate store or fallbacks to
+// NoopCertStore.
+func (o *Opts) GetCertStore() CertStore {
+	if o == nil || o.CertStore == nil {
+		return NoopCertStore{}
+	}
+
+	return o.CertStore
+}
+
+// GetKeyType returns an algorithm of private keys.
+func (o *Opts) GetKeyType() KeyType {
+	if o == nil {
+		return KeyTypeRSA
+	}
+
+	return o.KeyType
+}
+
+// GetFallbackCacheTTL returns a time period fallback certificates are
+// cached for or fallbacks to default one.
+func (o *Opts) GetFallbackCacheTTL() time.Duration {
+	if o == nil || o.FallbackCacheTTL == 0 {
+		return DefaultFallbackCacheTTL
+	}
+
+	return o.FallbackCacheTTL
+}
+
+// GetValidity returns a validity period of certificates or fallbacks
+// to default one.
+func (o *Opts) GetValidity() time.Duration {
+	if o == nil || o.Validity == 0 {
+		return DefaultValidity
+	}
+
+	return o.Validity
+}
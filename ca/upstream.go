@@ -0,0 +1,65 @@
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"sync"
+)
+
+type upstreamCall struct {
+	done chan struct{}
+	cert *x509.Certificate
+	err  error
+}
+
+// upstreamFetcher fetches certificates of real netlocs. Concurrent
+// fetches of the same address are merged into a single one: a burst of
+// TLS handshakes to a new hostname makes only one connection to the
+// netloc.
+type upstreamFetcher struct {
+	fetch func(host, port string) (*x509.Certificate, error)
+	mutex sync.Mutex
+	calls map[string]*upstreamCall
+}
+
+func (u *upstreamFetcher) Fetch(ctx context.Context, host, port string) (*x509.Certificate, error) {
+	key := net.JoinHostPort(host, port)
+
+	u.mutex.Lock()
+
+	if call, ok := u.calls[key]; ok {
+		u.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrContextClosed
+		case <-call.done:
+			return call.cert, call.err
+		}
+	}
+
+	call := &upstreamCall{
+		done: make(chan struct{}),
+	}
+	u.calls[key] = call
+
+	u.mutex.Unlock()
+
+	call.cert, call.err = u.fetch(host, port)
+
+	u.mutex.Lock()
+	delete(u.calls, key)
+	u.mutex.Unlock()
+
+	close(call.done)
+
+	return call.cert, call.err
+}
+
+func newUpstreamFetcher(fetch func(host, port string) (*x509.Certificate, error)) *upstreamFetcher {
+	return &upstreamFetcher{
+		fetch: fetch,
+		calls: map[string]*upstreamCall{},
+	}
+}
@@ -3,10 +3,9 @@ package ca
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
-	"crypto/x509/pkix"
+	"fmt"
 	"math/big"
 	"net"
 	"time"
@@ -20,35 +19,62 @@ import (
 // more than that.
 const RSAKeyLength = 2048
 
+// NextProtos defines a list of protocols which are advertised with ALPN
+// in generated TLS configs. h2 goes first so clients which support it
+// would prefer HTTP/2.
+var NextProtos = []string{"h2", "http/1.1"}
+
+type workerResponse struct {
+	conf *tls.Config
+	err  error
+}
+
+// workerRequest asks worker to generate a certificate. Everything
+// which may block (a lookup in the store and fetching of upstream
+// certificate) is done before by a caller: worker serializes requests
+// so it should only sign certificates.
 type workerRequest struct {
 	host     string
-	response chan<- *tls.Config
+	stored   *tls.Certificate
+	upstream *x509.Certificate
+	fallback bool
+	response chan<- workerResponse
 }
 
 type worker struct {
 	ca              tls.Certificate
 	ctx             context.Context
-	cache           cache.Interface
+	cache           cache.TTLInterface
+	store           CertStore
+	opts            *Opts
+	upstreams       *upstreamFetcher
 	eventStream     events.Stream
 	channelRequests chan workerRequest
 }
 
-func (w *worker) Get(host string) (*tls.Config, error) {
+func (w *worker) Get(host, port string) (*tls.Config, error) {
 	if cert := w.cache.Get(host); cert != nil {
 		return cert.(*tls.Config), nil
 	}
 
-	response := make(chan *tls.Config)
+	response := make(chan workerResponse)
 	req := workerRequest{
 		host:     host,
+		stored:   w.getStored(host),
 		response: response,
 	}
 
+	if req.stored == nil && w.opts.Template != nil {
+		req.upstream, req.fallback = w.getUpstream(host, port)
+	}
+
 	select {
 	case <-w.ctx.Done():
 		return nil, ErrContextClosed
 	case w.channelRequests <- req:
-		return <-response, nil
+		resp := <-response
+
+		return resp.conf, resp.err
 	}
 }
 
@@ -58,37 +84,129 @@ func (w *worker) Run() {
 		case <-w.ctx.Done():
 			return
 		case req := <-w.channelRequests:
-			var conf *tls.Config
+			resp := workerResponse{}
 
 			if cert := w.cache.Get(req.host); cert != nil {
-				conf, _ = cert.(*tls.Config)
+				resp.conf, _ = cert.(*tls.Config)
 			} else {
-				conf = w.process(req.host)
-				w.cache.Add(req.host, conf)
-
-				w.eventStream.Send(w.ctx, events.EventTypeNewCertificate, req.host, req.host)
+				resp.conf, resp.err = w.process(req)
 			}
 
-			req.response <- conf
+			req.response <- resp
 			close(req.response)
 		}
 	}
 }
 
-func (w *worker) process(host string) *tls.Config {
+func (w *worker) process(req workerRequest) (*tls.Config, error) {
+	cert := req.stored
+	if cert == nil {
+		generated, err := w.generate(req.host, req.upstream)
+		if err != nil {
+			return nil, err
+		}
+
+		cert = generated
+
+		w.eventStream.Send(w.ctx, events.EventTypeNewCertificate, req.host, req.host)
+
+		// certificate which was generated without upstream one is
+		// temporary: we want to mimic upstream next time.
+		if !req.fallback {
+			// store is only a persistent backup so failures are not
+			// fatal: certificate is going to be regenerated next time.
+			if err := w.store.Put(w.opts.StoreKey(req.host), cert); err != nil {
+				w.eventStream.Send(w.ctx,
+					events.EventTypeCertificateStoreError,
+					&events.CertificateStoreErrorMeta{
+						Host: req.host,
+						Err:  err,
+					},
+					req.host)
+			}
+		}
+	}
+
+	conf := &tls.Config{
+		ServerName:         req.host,
+		InsecureSkipVerify: true, // nolint: gosec
+		Certificates:       []tls.Certificate{*cert},
+		NextProtos:         NextProtos,
+	}
+
+	// certificate can be copied from upstream one so it can expire
+	// before cache entry. Even expired certificate is cached for a
+	// short time: otherwise it is generated on each handshake.
+	ttl := time.Until(cert.Leaf.NotAfter)
+
+	switch {
+	case req.fallback, ttl <= 0:
+		w.cache.AddWithTTL(req.host, conf, w.opts.GetFallbackCacheTTL())
+	case ttl < CACacheTTL:
+		w.cache.AddWithTTL(req.host, conf, ttl)
+	default:
+		w.cache.Add(req.host, conf)
+	}
+
+	// requests for the same host are already queued so they have to
+	// see this entry.
+	w.cache.Wait()
+
+	return conf, nil
+}
+
+// getStored returns a certificate from the store if it is signed by
+// our CA, has an expected key type and is going to be valid for a
+// reasonable time.
+func (w *worker) getStored(host string) *tls.Certificate {
+	cert, err := w.store.Get(w.opts.StoreKey(host))
+	if err != nil || cert == nil || len(cert.Certificate) == 0 {
+		return nil
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil
+		}
+
+		cert.Leaf = leaf
+	}
+
 	now := time.Now()
+	renewBefore := CACacheTTL
+
+	if validity := w.opts.GetValidity(); validity < 2*renewBefore {
+		renewBefore = validity / 2 // nolint: gomnd
+	}
+
+	switch {
+	case leaf.CheckSignatureFrom(w.ca.Leaf) != nil,
+		leaf.PublicKeyAlgorithm != w.opts.GetKeyType().publicKeyAlgorithm(),
+		now.Before(leaf.NotBefore),
+		now.Add(renewBefore).After(leaf.NotAfter),
+		leaf.VerifyHostname(host) != nil:
+		return nil
+	}
+
+	return cert
+}
 
-	template := x509.Certificate{
+func (w *worker) generate(host string, upstream *x509.Certificate) (*tls.Certificate, error) {
+	now := time.Now()
+	keyType := w.opts.GetKeyType()
+	template := &x509.Certificate{
 		SerialNumber: &big.Int{},
 		Issuer:       w.ca.Leaf.Subject,
-		Subject:      pkix.Name{},
+		Subject:      w.opts.Subject,
 		// 1 day before
-		NotBefore: now.AddDate(0, 0, -1),
-		// 3 months after
-		NotAfter:              now.AddDate(0, 3, 0), // nolint: gomnd
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		NotBefore:             now.AddDate(0, 0, -1),
+		NotAfter:              now.Add(w.opts.GetValidity()),
+		KeyUsage:              keyType.keyUsage(),
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
+		DNSNames:              append([]string{}, w.opts.DNSNames...),
+		IPAddresses:           append([]net.IP{}, w.opts.IPAddresses...),
 	}
 
 	if ip := net.ParseIP(host); ip != nil {
@@ -102,25 +220,45 @@ func (w *worker) process(host string) *tls.Config {
 	rand.Read(randBytes)          // nolint: errcheck
 	template.SerialNumber.SetBytes(randBytes)
 
-	certPriv, err := rsa.GenerateKey(rand.Reader, RSAKeyLength)
+	if w.opts.Template != nil {
+		w.opts.Template(host, template, upstream)
+	}
+
+	certPriv, err := keyType.generate()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate a private key: %w", err)
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, w.ca.Leaf,
+		certPriv.Public(), w.ca.PrivateKey)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("cannot sign a certificate: %w", err)
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, w.ca.Leaf,
-		&certPriv.PublicKey, w.ca.PrivateKey)
+	leaf, err := x509.ParseCertificate(derBytes)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("cannot parse generated certificate: %w", err)
 	}
 
-	certificate := tls.Certificate{
+	return &tls.Certificate{
 		Certificate: [][]byte{derBytes, w.ca.Certificate[0]},
 		PrivateKey:  certPriv,
+		Leaf:        leaf,
+	}, nil
+}
+
+// getUpstream returns a certificate of the real netloc. If it is
+// failed to get it, it returns true: certificate is generated but
+// should not be kept.
+func (w *worker) getUpstream(host, port string) (*x509.Certificate, bool) {
+	if w.upstreams == nil {
+		return nil, false
 	}
 
-	return &tls.Config{
-		ServerName:         host,
-		InsecureSkipVerify: true, // nolint: gosec
-		Certificates:       []tls.Certificate{certificate},
+	cert, err := w.upstreams.Fetch(w.ctx, host, port)
+	if err != nil {
+		return nil, true
 	}
+
+	return cert, false
 }
@@ -17,12 +17,20 @@ type cache struct {
 }
 
 func (c *cache) Add(key string, value interface{}) {
+	c.AddWithTTL(key, value, c.ttl)
+}
+
+func (c *cache) AddWithTTL(key string, value interface{}, ttl time.Duration) {
 	item := &cacheItem{
 		key:   key,
 		value: value,
 	}
 
-	c.cache.SetWithTTL(key, item, 1, c.ttl)
+	c.cache.SetWithTTL(key, item, 1, ttl)
+}
+
+func (c *cache) Wait() {
+	c.cache.Wait()
 }
 
 func (c *cache) Get(key string) interface{} {
@@ -34,7 +42,7 @@ func (c *cache) Get(key string) interface{} {
 }
 
 // New returns a new LRU/LFU cache based on given parameters.
-func New(size int, ttl time.Duration, callback EvictCallback) Interface {
+func New(size int, ttl time.Duration, callback EvictCallback) TTLInterface {
 	config := &ristretto.Config{
 		// 10x is recommended in official documentation
 		NumCounters: int64(10 * size), // nolint: gomnd
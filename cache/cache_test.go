@@ -11,7 +11,7 @@ import (
 type CacheTestSuite struct {
 	suite.Suite
 
-	cache cache.Interface
+	cache cache.TTLInterface
 }
 
 func (suite *CacheTestSuite) SetupTest() {
@@ -28,6 +28,22 @@ func (suite *CacheTestSuite) TestAddGet() {
 	suite.EqualValues(1, suite.cache.Get("key"))
 }
 
+func (suite *CacheTestSuite) TestAddWithTTL() {
+	suite.cache.AddWithTTL("key", 1, 50*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	suite.EqualValues(1, suite.cache.Get("key"))
+
+	suite.Eventually(func() bool {
+		return suite.cache.Get("key") == nil
+	}, 2*time.Second, 50*time.Millisecond)
+}
+
+func (suite *CacheTestSuite) TestWait() {
+	suite.cache.AddWithTTL("key", 1, time.Minute)
+	suite.cache.Wait()
+	suite.EqualValues(1, suite.cache.Get("key"))
+}
+
 func (suite *CacheTestSuite) TestEvict() {
 	var foundKey string
 	var foundValue interface{}
@@ -1,5 +1,7 @@
 package cache
 
+import "time"
+
 // EvictCallback is going to be executed on an entry eviction.
 type EvictCallback func(string, interface{})
 
@@ -13,3 +15,17 @@ type Interface interface {
 	// a value, then it returns a nil.
 	Get(key string) interface{}
 }
+
+// TTLInterface is a cache which can keep values for custom TTLs.
+// Caches which are created with New conform it.
+type TTLInterface interface {
+	Interface
+
+	// AddWithTTL puts a value into the cache with a custom TTL.
+	AddWithTTL(key string, value interface{}, ttl time.Duration)
+
+	// Wait blocks until all values which were added before are
+	// visible for Get. Please pay attention that cache may decline to
+	// store a value.
+	Wait()
+}
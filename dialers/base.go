@@ -11,6 +11,8 @@ import (
 	"github.com/9seconds/httransform/v2/cache"
 	"github.com/9seconds/httransform/v2/dns"
 	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/tracing"
 	"github.com/libp2p/go-reuseport"
 	"github.com/valyala/fasthttp"
 )
@@ -25,17 +27,38 @@ const (
 )
 
 type base struct {
+	upstream       Dialer
+	dns            dns.Interface
 	netDialer      net.Dialer
+	attemptTimeout time.Duration
+	fallbackDelay  time.Duration
 	tlsConfigsLock sync.Mutex
 	tlsConfigs     cache.Interface
 	tlsSkipVerify  bool
 }
 
-func (b *base) Dial(ctx context.Context, host, port string) (net.Conn, error) {
+func (b *base) Dial(ctx context.Context, host, port string) (conn net.Conn, err error) {
+	ctx, span := tracing.StartSpan(ctx, "dial")
+
+	span.SetAttribute("net.peer.name", host)
+	span.SetAttribute("net.peer.port", port)
+
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if b.upstream != nil {
+		return b.dialUpstream(ctx, host, port)
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, b.netDialer.Timeout)
 	defer cancel()
 
-	ips, err := dns.Default.Lookup(ctx, host)
+	timings := events.GetTimings(ctx)
+	startTime := time.Now()
+
+	ips, err := b.dns.Lookup(ctx, host)
 	if err != nil {
 		return nil, errors.Annotate(err, "cannot resolve IPs", "dns_no_ips", 0)
 	}
@@ -44,29 +67,57 @@ func (b *base) Dial(ctx context.Context, host, port string) (net.Conn, error) {
 		return nil, ErrNoIPs
 	}
 
-	var conn net.Conn
+	if timings != nil {
+		timings.DNS += time.Since(startTime)
+	}
 
-	for _, ip := range ips {
-		conn, err = b.netDialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
-		if err == nil {
-			return conn, nil
-		}
+	startTime = time.Now()
+
+	conn, attempts, err := happyEyeballs(ctx, &b.netDialer, ips, port, b.attemptTimeout, b.fallbackDelay)
+	if err != nil {
+		return nil, errors.Annotate(err, "cannot dial to "+host, "cannot_dial", 0)
+	}
+
+	if timings != nil {
+		timings.Dial += time.Since(startTime)
+	}
+
+	sendEvent(ctx, events.EventTypeDial, &events.DialMeta{
+		ID:       eventID(ctx),
+		Host:     host,
+		Addr:     conn.RemoteAddr(),
+		Attempts: attempts,
+		Elapsed:  time.Since(startTime),
+	})
+
+	return conn, nil
+}
+
+func (b *base) dialUpstream(ctx context.Context, host, port string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.netDialer.Timeout)
+	defer cancel()
+
+	conn, err := dialTunnel(ctx, b.upstream, host, port)
+	if err != nil {
+		return nil, errors.Annotate(err, "cannot dial to "+host+" via upstream", "cannot_dial", 0)
 	}
 
-	return nil, errors.Annotate(err, "cannot dial to "+host, "cannot_dial", 0)
+	return conn, nil
 }
 
 func (b *base) UpgradeToTLS(ctx context.Context, conn net.Conn, host, _ string) (net.Conn, error) {
 	subCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	ctxDone := ctx.Done()
+
 	go func() {
 		timer := fasthttp.AcquireTimer(b.netDialer.Timeout)
 		defer fasthttp.ReleaseTimer(timer)
 
 		select {
 		case <-subCtx.Done():
-		case <-ctx.Done():
+		case <-ctxDone:
 			select {
 			case <-subCtx.Done():
 			default:
@@ -81,9 +132,33 @@ func (b *base) UpgradeToTLS(ctx context.Context, conn net.Conn, host, _ string)
 		}
 	}()
 
-	tlsConn := tls.Client(conn, b.getTLSConfig(host))
+	_, span := tracing.StartSpan(ctx, "tls_handshake")
+	defer span.End()
+
+	span.SetAttribute("net.peer.name", host)
+
+	conf := b.getTLSConfig(host)
+
+	if protos := NextProtos(ctx); len(protos) > 0 {
+		conf = conf.Clone()
+		conf.NextProtos = protos
+	}
+
+	startTime := time.Now()
+
+	tlsConn := tls.Client(conn, conf)
 	if err := tlsConn.Handshake(); err != nil {
-		return nil, errors.Annotate(err, "cannot perform TLS handshake", "tls_handshake", 0)
+		err = errors.Annotate(err, "cannot perform TLS handshake", "tls_handshake", 0)
+
+		span.RecordError(err)
+
+		return nil, err
+	}
+
+	span.SetAttribute("tls.protocol", tlsConn.ConnectionState().NegotiatedProtocol)
+
+	if timings := events.GetTimings(ctx); timings != nil {
+		timings.TLSHandshake += time.Since(startTime)
 	}
 
 	return tlsConn, nil
@@ -130,12 +205,29 @@ func (b *base) getTLSConfig(host string) *tls.Config {
 //
 // Apart from that, it sets timeouts, uses SO_REUSEADDR socket option,
 // uses DNS cache and reuses tls.Config instances when possible.
+//
+// If netloc has several IPs, Dial races them as RFC 8305 (Happy
+// Eyeballs) suggests: IPv6 and IPv4 addresses are interleaved and a
+// new attempt is started each Opts.FallbackDelay or as soon as the
+// previous one fails. If context has an event stream (see
+// WithEventStream), events.EventTypeDial is sent for established
+// connection. If context has timings (see events.WithTimings), DNS,
+// Dial and TLSHandshake phases are filled. If context has a span (see
+// tracing.ContextWithSpan), dial and TLS handshake are traced as its
+// children.
+//
+// If Opts.Upstream is set, TCP connections are established through
+// this dialer.
 func NewBase(opt Opts) Dialer {
 	rv := &base{
+		upstream: opt.GetUpstream(),
+		dns:      opt.GetDNS(),
 		netDialer: net.Dialer{
 			Timeout: opt.GetTimeout(),
 			Control: reuseport.Control,
 		},
+		attemptTimeout: opt.GetAttemptTimeout(),
+		fallbackDelay:  opt.GetFallbackDelay(),
 		tlsConfigs: cache.New(TLSConfigCacheSize,
 			TLSConfigTTL,
 			cache.NoopEvictCallback),
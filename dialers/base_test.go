@@ -10,12 +10,24 @@ import (
 	"testing"
 	"time"
 
+	"github.com/9seconds/httransform/v2/cache"
 	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/dns"
+	"github.com/9seconds/httransform/v2/events"
 	"github.com/mccutchen/go-httpbin/httpbin"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"github.com/valyala/fasthttp"
 )
 
+type EventStreamMock struct {
+	mock.Mock
+}
+
+func (e *EventStreamMock) Send(ctx context.Context, eventType events.EventType, value interface{}, shardKey string) {
+	e.Called(ctx, eventType, value, shardKey)
+}
+
 type BaseTestSuite struct {
 	suite.Suite
 
@@ -124,6 +136,83 @@ func (suite *BaseTestSuite) TestCannotUpgradeToTLS() {
 	suite.Error(err)
 }
 
+func (suite *BaseTestSuite) TestDialEvent() {
+	parsedURL, _ := url.Parse(suite.httpHttpbin.URL)
+	stream := &EventStreamMock{}
+
+	stream.On("Send", mock.Anything, events.EventTypeDial, mock.Anything, "reqid").Once()
+
+	ctx := dialers.WithEventStream(context.Background(), stream, "reqid")
+	conn, err := suite.dialer.Dial(ctx, "localhost", parsedURL.Port())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	stream.AssertExpectations(suite.T())
+
+	meta := stream.Calls[0].Arguments.Get(2).(*events.DialMeta)
+
+	suite.Equal("reqid", meta.ID)
+	suite.Equal("localhost", meta.Host)
+	suite.Equal(conn.RemoteAddr(), meta.Addr)
+	suite.GreaterOrEqual(meta.Attempts, 1)
+}
+
+func (suite *BaseTestSuite) TestTimings() {
+	parsedURL, _ := url.Parse(suite.tlsHttpbin.URL)
+	timings := &events.Timings{}
+	ctx := events.WithTimings(context.Background(), timings)
+
+	conn, err := suite.dialer.Dial(ctx, "localhost", parsedURL.Port())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	conn, err = suite.dialer.UpgradeToTLS(ctx, conn, parsedURL.Hostname(), parsedURL.Port())
+
+	suite.NoError(err)
+	suite.Greater(int64(timings.DNS), int64(0))
+	suite.Greater(int64(timings.Dial), int64(0))
+	suite.Greater(int64(timings.TLSHandshake), int64(0))
+}
+
+func (suite *BaseTestSuite) TestCustomDNS() {
+	parsedURL, _ := url.Parse(suite.httpHttpbin.URL)
+	dialer := dialers.NewBase(dialers.Opts{
+		DNS: dns.NewWithResolver(dns.NewHostsResolver(map[string][]string{
+			"httpbin.test": {parsedURL.Hostname()},
+		}, nil), dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback),
+	})
+
+	conn, err := dialer.Dial(context.Background(), "httpbin.test", parsedURL.Port())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	suite.Equal(parsedURL.Host, conn.RemoteAddr().String())
+}
+
+func (suite *BaseTestSuite) TestLastAttemptIsNotLimited() {
+	parsedURL, _ := url.Parse(suite.httpHttpbin.URL)
+	dialer := dialers.NewBase(dialers.Opts{
+		AttemptTimeout: time.Nanosecond,
+		DNS: dns.NewWithResolver(dns.NewHostsResolver(map[string][]string{
+			"httpbin.test": {parsedURL.Hostname()},
+		}, nil), dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback),
+	})
+
+	conn, err := dialer.Dial(context.Background(), "httpbin.test", parsedURL.Port())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	suite.Equal(parsedURL.Host, conn.RemoteAddr().String())
+}
+
 func TestBase(t *testing.T) {
 	suite.Run(t, &BaseTestSuite{})
 }
@@ -27,4 +27,16 @@
 // 3. Patch HTTP request. Sometimes we want to process a request in a
 // special way for this socket. This method prepares an HTTP request so
 // it can be passed down to a socket.
+//
+// Dialers can be chained with Opts.Upstream: a dialer reaches its
+// netloc or proxy through an upstream one. DialerFromURLs builds such
+// chains from a list of proxy URLs.
+//
+// Pool spreads connections between many proxies with a chosen
+// strategy and takes care of failed ones.
+//
+// Direct connections to netlocs with several IPs race IPv6 and IPv4
+// addresses as RFC 8305 (Happy Eyeballs) suggests. Each attempt has
+// its own Opts.AttemptTimeout so a blackholed address does not consume
+// a whole Opts.Timeout.
 package dialers
@@ -7,3 +7,8 @@ var ErrNoIPs = &errors.Error{
 	Message: "no ips",
 	Code:    "dns_no_ips",
 }
+
+// ErrPoolNoProxies is returned if Pool is created without proxies.
+var ErrPoolNoProxies = &errors.Error{
+	Message: "pool has no proxies",
+}
@@ -0,0 +1,38 @@
+package dialers
+
+import (
+	"context"
+
+	"github.com/9seconds/httransform/v2/events"
+)
+
+type ctxKeyEventStream struct{}
+
+type ctxEventStream struct {
+	stream events.Stream
+	id     string
+}
+
+// WithEventStream returns a derived context which asks dialers to
+// report events.EventTypeDial to a given stream. id is a request ID
+// which is used as an ID of events.DialMeta and as a sharding key.
+//
+// If context has no event stream set, dialers send no events.
+func WithEventStream(ctx context.Context, stream events.Stream, id string) context.Context {
+	return context.WithValue(ctx, ctxKeyEventStream{}, ctxEventStream{
+		stream: stream,
+		id:     id,
+	})
+}
+
+func eventID(ctx context.Context) string {
+	stream, _ := ctx.Value(ctxKeyEventStream{}).(ctxEventStream)
+
+	return stream.id
+}
+
+func sendEvent(ctx context.Context, eventType events.EventType, value interface{}) {
+	if stream, ok := ctx.Value(ctxKeyEventStream{}).(ctxEventStream); ok && stream.stream != nil {
+		stream.stream.Send(ctx, eventType, value, stream.id)
+	}
+}
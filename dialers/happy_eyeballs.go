@@ -0,0 +1,140 @@
+package dialers
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type happyEyeballsResult struct {
+	conn net.Conn
+	err  error
+}
+
+// happyEyeballs races connection attempts to given IPs as RFC 8305
+// suggests: a new attempt is started if the previous one has failed or
+// has not succeeded within fallbackDelay. The first established
+// connection wins, other attempts are cancelled.
+//
+// attemptTimeout limits all attempts but the last one. The last
+// attempt has nobody to give way to so it is limited only by a
+// deadline of the given context.
+//
+// It returns a number of started attempts along with a connection.
+func happyEyeballs(ctx context.Context,
+	dialer *net.Dialer,
+	ips []string,
+	port string,
+	attemptTimeout, fallbackDelay time.Duration) (net.Conn, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ips = happyEyeballsSort(ips)
+	results := make(chan happyEyeballsResult, len(ips))
+	started := 0
+	pending := 0
+
+	startAttempt := func() {
+		addr := net.JoinHostPort(ips[started], port)
+
+		started++
+		pending++
+
+		attemptCtx, attemptCancel := ctx, context.CancelFunc(func() {})
+		if started < len(ips) {
+			attemptCtx, attemptCancel = context.WithTimeout(ctx, attemptTimeout)
+		}
+
+		go func() {
+			defer attemptCancel()
+
+			conn, err := dialer.DialContext(attemptCtx, "tcp", addr)
+			results <- happyEyeballsResult{
+				conn: conn,
+				err:  err,
+			}
+		}()
+	}
+
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+
+	startAttempt()
+
+	var lastErr error
+
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if started < len(ips) {
+				startAttempt()
+				timer.Reset(fallbackDelay)
+			}
+		case result := <-results:
+			pending--
+
+			if result.err == nil {
+				go happyEyeballsCloseLosers(results, pending)
+
+				return result.conn, started, nil
+			}
+
+			lastErr = result.err
+
+			if started < len(ips) {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+
+				startAttempt()
+				timer.Reset(fallbackDelay)
+			}
+		}
+	}
+
+	return nil, started, lastErr
+}
+
+// happyEyeballsSort interleaves IPv6 and IPv4 addresses starting from
+// IPv6 one. Order within the same family is preserved.
+func happyEyeballsSort(ips []string) []string {
+	ipv6 := make([]string, 0, len(ips))
+	ipv4 := make([]string, 0, len(ips))
+
+	for _, v := range ips {
+		if ip := net.ParseIP(v); ip != nil && ip.To4() == nil {
+			ipv6 = append(ipv6, v)
+		} else {
+			ipv4 = append(ipv4, v)
+		}
+	}
+
+	rv := make([]string, 0, len(ips))
+
+	for len(ipv6) > 0 || len(ipv4) > 0 {
+		if len(ipv6) > 0 {
+			rv = append(rv, ipv6[0])
+			ipv6 = ipv6[1:]
+		}
+
+		if len(ipv4) > 0 {
+			rv = append(rv, ipv4[0])
+			ipv4 = ipv4[1:]
+		}
+	}
+
+	return rv
+}
+
+// happyEyeballsCloseLosers closes connections of attempts which have
+// succeeded after the winner.
+func happyEyeballsCloseLosers(results <-chan happyEyeballsResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
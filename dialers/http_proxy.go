@@ -27,19 +27,46 @@ func (h *httpProxy) Dial(ctx context.Context, host, port string) (net.Conn, erro
 	return h.baseDialer.Dial(ctx, h.proxyHost, h.proxyPort)
 }
 
-func (h *httpProxy) UpgradeToTLS(ctx context.Context, // nolint: cyclop
+func (h *httpProxy) UpgradeToTLS(ctx context.Context, conn net.Conn, host, port string) (net.Conn, error) {
+	if err := h.connect(ctx, conn, host, port); err != nil {
+		return nil, err
+	}
+
+	return h.baseDialer.UpgradeToTLS(ctx, conn, host, port)
+}
+
+func (h *httpProxy) DialTunnel(ctx context.Context, host, port string) (net.Conn, error) {
+	conn, err := h.Dial(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.connect(ctx, conn, host, port); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// connect establishes a tunnel to the given address with CONNECT
+// method.
+func (h *httpProxy) connect(ctx context.Context, // nolint: cyclop
 	conn net.Conn,
-	host, port string) (net.Conn, error) {
+	host, port string) error {
 	subCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	ctxDone := ctx.Done()
+
 	go func() {
 		timer := fasthttp.AcquireTimer(h.baseDialer.netDialer.Timeout)
 		defer fasthttp.ReleaseTimer(timer)
 
 		select {
 		case <-subCtx.Done():
-		case <-ctx.Done():
+		case <-ctxDone:
 			select {
 			case <-subCtx.Done():
 			default:
@@ -63,7 +90,7 @@ func (h *httpProxy) UpgradeToTLS(ctx context.Context, // nolint: cyclop
 	buf.Write(h.connectRequestSuffix)
 
 	if _, err := conn.Write(buf.Bytes()); err != nil {
-		return nil, errors.Annotate(err, "cannot send a connect request", "http_proxy_dial", 0)
+		return errors.Annotate(err, "cannot send a connect request", "http_proxy_dial", 0)
 	}
 
 	response := fasthttp.AcquireResponse()
@@ -75,17 +102,25 @@ func (h *httpProxy) UpgradeToTLS(ctx context.Context, // nolint: cyclop
 	defer h.releaseBufioReader(bufReader)
 
 	if err := response.Read(bufReader); err != nil {
-		return nil, errors.Annotate(err, "cannot read http response", "http_proxy_dial", 0)
+		return errors.Annotate(err, "cannot read http response", "http_proxy_dial", 0)
 	}
 
-	if response.StatusCode() != fasthttp.StatusOK {
-		return nil, &errors.Error{
+	switch response.StatusCode() {
+	case fasthttp.StatusOK:
+	case fasthttp.StatusProxyAuthRequired:
+		return &errors.Error{
+			Message: "proxy has rejected credentials",
+			Code:    "http_proxy_dial",
+		}
+	default:
+		return &errors.Error{
 			Message: fmt.Sprintf("proxy has responded with %d status code", response.StatusCode()),
 			Code:    "http_proxy_dial",
+			Err:     errTunnelRefused,
 		}
 	}
 
-	return h.baseDialer.UpgradeToTLS(subCtx, conn, host, port)
+	return nil
 }
 
 func (h *httpProxy) PatchHTTPRequest(req *fasthttp.Request) {
@@ -19,6 +19,9 @@ type Dialer interface {
 	// UpgradeToTLS transforms a plain TCP connection to secured one.
 	// Hostname is a hostname we connect to. Sometimes we can reuse cached
 	// TLS sessions based on this parameter, for example.
+	//
+	// If context has protocols set by WithNextProtos, they have to be
+	// negotiated with ALPN.
 	UpgradeToTLS(ctx context.Context, tcpConn net.Conn, host, port string) (net.Conn, error)
 
 	// PatchHTTPRequest has to patch HTTP request so it can be passed
@@ -0,0 +1,22 @@
+package dialers
+
+import "context"
+
+type ctxKeyNextProtos struct{}
+
+// WithNextProtos returns a derived context which asks UpgradeToTLS to
+// negotiate given application protocols with ALPN. For example, if
+// you want to talk HTTP/2 with a netloc, please pass []string{"h2",
+// "http/1.1"} and check NegotiatedProtocol of the returned connection.
+//
+// If context has no protocols set, dialers do not use ALPN at all.
+func WithNextProtos(ctx context.Context, protos []string) context.Context {
+	return context.WithValue(ctx, ctxKeyNextProtos{}, protos)
+}
+
+// NextProtos returns a list of protocols set by WithNextProtos.
+func NextProtos(ctx context.Context) []string {
+	protos, _ := ctx.Value(ctxKeyNextProtos{}).([]string)
+
+	return protos
+}
@@ -2,6 +2,8 @@ package dialers
 
 import (
 	"time"
+
+	"github.com/9seconds/httransform/v2/dns"
 )
 
 const (
@@ -9,6 +11,16 @@ const (
 	// establish a TCP connections to target netlocs if user provides no
 	// value.
 	DefaultTimeout = 20 * time.Second
+
+	// DefaultAttemptTimeout defines a timeout of a single attempt to
+	// establish a TCP connection to one of netloc IPs if user provides
+	// no value.
+	DefaultAttemptTimeout = 5 * time.Second
+
+	// DefaultFallbackDelay defines a delay between connection attempts
+	// to different IPs of the same netloc if user provides no value.
+	// This is 'Connection Attempt Delay' of RFC 8305.
+	DefaultFallbackDelay = 250 * time.Millisecond
 )
 
 // Opts define a set of common options for each dialer. This struct here
@@ -19,6 +31,18 @@ type Opts struct {
 	// target netlocs. It is also a timeout on UpgradeToTLS operations.
 	Timeout time.Duration
 
+	// AttemptTimeout defines a timeout of a single attempt to
+	// establish a TCP connection to one of netloc IPs. Whole dialing
+	// is still limited by Timeout. The last attempt is limited only by
+	// Timeout: there is no other address to fall back to.
+	AttemptTimeout time.Duration
+
+	// FallbackDelay defines how long to wait for a connection attempt
+	// before starting the next one in parallel. Dialers race IPv6 and
+	// IPv4 addresses of netlocs as RFC 8305 (Happy Eyeballs) suggests
+	// so a single blackholed IP does not consume a whole Timeout.
+	FallbackDelay time.Duration
+
 	// TLSSkipVerify defines if we want to skip verification of
 	// TLScertificates or not.
 	//
@@ -27,6 +51,19 @@ type Opts struct {
 	//
 	// You was warned.
 	TLSSkipVerify bool
+
+	// Upstream defines a dialer which is used to reach netlocs
+	// (or proxies) instead of direct TCP connections. This is how
+	// dialers are chained: if you pass a SOCKS5 dialer here to HTTP
+	// proxy dialer, HTTP proxy is reached through SOCKS5 one.
+	//
+	// Hostnames are passed to upstream as is so they are resolved on
+	// the remote side.
+	Upstream Dialer
+
+	// DNS defines a caching resolver which is used to resolve netlocs.
+	// Default is dns.Default.
+	DNS dns.Interface
 }
 
 // GetTimeout returns a timeout value or fallbacks to default one.
@@ -38,8 +75,43 @@ func (o *Opts) GetTimeout() time.Duration {
 	return o.Timeout
 }
 
+// GetAttemptTimeout returns a timeout of a single connection attempt
+// or fallbacks to default one.
+func (o *Opts) GetAttemptTimeout() time.Duration {
+	if o.AttemptTimeout == 0 {
+		return DefaultAttemptTimeout
+	}
+
+	return o.AttemptTimeout
+}
+
+// GetFallbackDelay returns a delay between connection attempts or
+// fallbacks to default one.
+func (o *Opts) GetFallbackDelay() time.Duration {
+	if o.FallbackDelay == 0 {
+		return DefaultFallbackDelay
+	}
+
+	return o.FallbackDelay
+}
+
 // GetTLSSkipVerify return a value for skipping TLS verification or
 // fallbacks to default one.
 func (o *Opts) GetTLSSkipVerify() bool {
 	return o.TLSSkipVerify
 }
+
+// GetUpstream returns an upstream dialer. nil means that TCP
+// connections are established directly.
+func (o *Opts) GetUpstream() Dialer {
+	return o.Upstream
+}
+
+// GetDNS returns a DNS resolver or fallbacks to default one.
+func (o *Opts) GetDNS() dns.Interface {
+	if o.DNS == nil {
+		return dns.Default
+	}
+
+	return o.DNS
+}
@@ -4,7 +4,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/9seconds/httransform/v2/cache"
 	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/dns"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -22,6 +24,26 @@ func (suite *OptsTestSuite) TestGetTimeout() {
 	suite.Equal(time.Minute, opt.GetTimeout())
 }
 
+func (suite *OptsTestSuite) TestGetAttemptTimeout() {
+	opt := dialers.Opts{}
+
+	suite.Equal(dialers.DefaultAttemptTimeout, opt.GetAttemptTimeout())
+
+	opt.AttemptTimeout = time.Second
+
+	suite.Equal(time.Second, opt.GetAttemptTimeout())
+}
+
+func (suite *OptsTestSuite) TestGetFallbackDelay() {
+	opt := dialers.Opts{}
+
+	suite.Equal(dialers.DefaultFallbackDelay, opt.GetFallbackDelay())
+
+	opt.FallbackDelay = time.Second
+
+	suite.Equal(time.Second, opt.GetFallbackDelay())
+}
+
 func (suite *OptsTestSuite) TestGetTLSSkipVerify() {
 	opt := dialers.Opts{}
 
@@ -32,6 +54,17 @@ func (suite *OptsTestSuite) TestGetTLSSkipVerify() {
 	suite.True(opt.GetTLSSkipVerify())
 }
 
+func (suite *OptsTestSuite) TestGetDNS() {
+	opt := dialers.Opts{}
+
+	suite.Equal(dns.Default, opt.GetDNS())
+
+	resolver := dns.New(dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+	opt.DNS = resolver
+
+	suite.Equal(resolver, opt.GetDNS())
+}
+
 func TestOpts(t *testing.T) {
 	suite.Run(t, &OptsTestSuite{})
 }
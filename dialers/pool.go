@@ -0,0 +1,375 @@
+package dialers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastrand"
+)
+
+const (
+	// DefaultPoolProbeInterval defines how often proxies which are
+	// marked as down are probed.
+	DefaultPoolProbeInterval = 30 * time.Second
+
+	// DefaultPoolDialAttempts defines how many proxies are tried
+	// before Dial gives up.
+	DefaultPoolDialAttempts = 3
+)
+
+// PoolStrategy defines how Pool chooses a proxy for a new connection.
+type PoolStrategy uint8
+
+const (
+	// PoolStrategyRoundRobin chooses proxies one by one.
+	PoolStrategyRoundRobin PoolStrategy = iota
+
+	// PoolStrategyRandom chooses a random proxy.
+	PoolStrategyRandom
+
+	// PoolStrategyLeastConnections chooses a proxy with the least
+	// number of active connections.
+	PoolStrategyLeastConnections
+
+	// PoolStrategyStickyUser chooses the same proxy for the same user
+	// while this proxy is alive. A user is taken from a context, see
+	// WithUser.
+	PoolStrategyStickyUser
+
+	// PoolStrategyStickyHost chooses the same proxy for the same
+	// netloc hostname while this proxy is alive.
+	PoolStrategyStickyHost
+)
+
+// String conforms fmt.Stringer interface.
+func (p PoolStrategy) String() string {
+	switch p {
+	case PoolStrategyRoundRobin:
+		return "round-robin"
+	case PoolStrategyRandom:
+		return "random"
+	case PoolStrategyLeastConnections:
+		return "least-connections"
+	case PoolStrategyStickyUser:
+		return "sticky-user"
+	case PoolStrategyStickyHost:
+		return "sticky-host"
+	}
+
+	return fmt.Sprintf("PoolStrategy(%d)", uint8(p))
+}
+
+// PoolOpts defines a set of options for Pool.
+type PoolOpts struct {
+	// Opts are options of dialers which are created for each proxy.
+	Opts
+
+	// ProxyURLs is a list of proxies in a format of DialerFromURL.
+	ProxyURLs []string
+
+	// Strategy defines how a proxy is chosen for a new connection.
+	// Default is round-robin.
+	Strategy PoolStrategy
+
+	// ProbeInterval defines how often proxies which are marked as down
+	// are probed.
+	ProbeInterval time.Duration
+
+	// ProbeAddress is an address (host:port) which is dialed through
+	// a proxy to check if it is alive. If it is empty, only a TCP
+	// connection to the proxy itself is established.
+	ProbeAddress string
+
+	// DialAttempts defines how many proxies are tried before Dial gives
+	// up.
+	DialAttempts uint
+}
+
+// GetProbeInterval returns a probe interval or fallbacks to default
+// one.
+func (p *PoolOpts) GetProbeInterval() time.Duration {
+	if p.ProbeInterval == 0 {
+		return DefaultPoolProbeInterval
+	}
+
+	return p.ProbeInterval
+}
+
+// GetDialAttempts returns a number of dial attempts or fallbacks to
+// default one.
+func (p *PoolOpts) GetDialAttempts() int {
+	if p.DialAttempts == 0 {
+		return DefaultPoolDialAttempts
+	}
+
+	return int(p.DialAttempts)
+}
+
+// poolMember is a single proxy of the pool. id is an URL of the
+// proxy: several proxies can share the same address but have different
+// credentials.
+type poolMember struct {
+	dialer      Dialer
+	id          string
+	address     string
+	down        int32
+	connections int32
+}
+
+func (p *poolMember) isDown() bool {
+	return atomic.LoadInt32(&p.down) != 0
+}
+
+// Pool is a dialer which spreads connections between many upstream
+// proxies. Proxies are marked as down if Pool cannot dial through them
+// and probed in background until they are alive again. If proxy
+// reports that it cannot reach a netloc (for example, CONNECT has
+// got 502 response), its error is returned as is: proxy is not marked
+// as down and other proxies are not tried.
+//
+// Pool uses proxies only to establish TCP tunnels: HTTP proxies are
+// asked for CONNECT even for plain HTTP requests. TLS upgrade is done
+// by Pool itself.
+//
+// If all proxies are down, Pool still tries to use them.
+type Pool struct {
+	baseDialer   *base
+	ctx          context.Context
+	members      []*poolMember
+	strategy     PoolStrategy
+	probeAddress string
+	dialAttempts int
+	counter      uint32
+}
+
+// Dial conforms Dialer interface.
+func (p *Pool) Dial(ctx context.Context, host, port string) (net.Conn, error) {
+	var (
+		lastErr error
+		tried   = map[*poolMember]bool{}
+	)
+
+	for attempt := 0; attempt < p.dialAttempts && len(tried) < len(p.members); attempt++ {
+		member := p.choose(ctx, host, tried)
+		tried[member] = true
+
+		conn, err := dialTunnel(ctx, member.dialer, host, port)
+		if err == nil {
+			atomic.StoreInt32(&member.down, 0)
+			atomic.AddInt32(&member.connections, 1)
+
+			return &poolConn{
+				Conn:   conn,
+				member: member,
+			}, nil
+		}
+
+		// proxy is alive but netloc is not reachable: other proxies
+		// won't help.
+		if isTunnelRefused(err) {
+			return nil, fmt.Errorf("cannot dial via %s: %w", member.address, err)
+		}
+
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cannot dial via %s: %w", member.address, err)
+		default:
+			atomic.StoreInt32(&member.down, 1)
+		}
+	}
+
+	return nil, fmt.Errorf("cannot dial via pool: %w", lastErr)
+}
+
+// UpgradeToTLS conforms Dialer interface.
+func (p *Pool) UpgradeToTLS(ctx context.Context, conn net.Conn, host, port string) (net.Conn, error) {
+	return p.baseDialer.UpgradeToTLS(ctx, conn, host, port)
+}
+
+// PatchHTTPRequest conforms Dialer interface.
+func (p *Pool) PatchHTTPRequest(req *fasthttp.Request) {
+	p.baseDialer.PatchHTTPRequest(req)
+}
+
+func (p *Pool) choose(ctx context.Context, host string, tried map[*poolMember]bool) *poolMember {
+	candidates := make([]*poolMember, 0, len(p.members))
+
+	for _, member := range p.members {
+		if !tried[member] && !member.isDown() {
+			candidates = append(candidates, member)
+		}
+	}
+
+	if len(candidates) == 0 {
+		for _, member := range p.members {
+			if !tried[member] {
+				candidates = append(candidates, member)
+			}
+		}
+	}
+
+	switch p.strategy {
+	case PoolStrategyRandom:
+		return candidates[fastrand.Uint32n(uint32(len(candidates)))]
+	case PoolStrategyLeastConnections:
+		chosen := candidates[0]
+
+		for _, member := range candidates[1:] {
+			if atomic.LoadInt32(&member.connections) < atomic.LoadInt32(&chosen.connections) {
+				chosen = member
+			}
+		}
+
+		return chosen
+	case PoolStrategyStickyUser:
+		return poolChooseSticky(candidates, User(ctx))
+	case PoolStrategyStickyHost:
+		return poolChooseSticky(candidates, host)
+	}
+
+	idx := atomic.AddUint32(&p.counter, 1)
+
+	return candidates[int(idx)%len(candidates)]
+}
+
+func (p *Pool) run(probeInterval time.Duration) {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			wg := &sync.WaitGroup{}
+
+			for _, member := range p.members {
+				if member.isDown() {
+					wg.Add(1)
+
+					go func(member *poolMember) {
+						defer wg.Done()
+
+						p.probe(member)
+					}(member)
+				}
+			}
+
+			wg.Wait()
+		}
+	}
+}
+
+func (p *Pool) probe(member *poolMember) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.baseDialer.netDialer.Timeout)
+	defer cancel()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if p.probeAddress != "" {
+		host, port, _ := net.SplitHostPort(p.probeAddress)
+		conn, err = dialTunnel(ctx, member.dialer, host, port)
+	} else {
+		host, port, _ := net.SplitHostPort(member.address)
+		conn, err = p.baseDialer.Dial(ctx, host, port)
+	}
+
+	if err == nil {
+		conn.Close()
+		atomic.StoreInt32(&member.down, 0)
+	}
+}
+
+// poolChooseSticky chooses a member with rendezvous hashing so a key
+// is moved to another member only if its member is gone.
+func poolChooseSticky(candidates []*poolMember, key string) *poolMember {
+	var (
+		chosen    *poolMember
+		maxWeight uint64
+	)
+
+	for _, member := range candidates {
+		hasher := fnv.New64a()
+
+		hasher.Write([]byte(member.id)) // nolint: errcheck
+		hasher.Write([]byte{0})         // nolint: errcheck
+		hasher.Write([]byte(key))       // nolint: errcheck
+
+		if weight := hasher.Sum64(); chosen == nil || weight > maxWeight {
+			chosen = member
+			maxWeight = weight
+		}
+	}
+
+	return chosen
+}
+
+type poolConn struct {
+	net.Conn
+
+	member *poolMember
+	once   sync.Once
+}
+
+func (p *poolConn) Close() error {
+	p.once.Do(func() {
+		atomic.AddInt32(&p.member.connections, -1)
+	})
+
+	return p.Conn.Close() // nolint: wrapcheck
+}
+
+// NewPool returns a new pool of proxies. Background probing of proxies
+// is stopped when a given context is closed.
+func NewPool(ctx context.Context, opts PoolOpts) (*Pool, error) {
+	if len(opts.ProxyURLs) == 0 {
+		return nil, ErrPoolNoProxies
+	}
+
+	if opts.Strategy > PoolStrategyStickyHost {
+		return nil, fmt.Errorf("unknown pool strategy %v", opts.Strategy)
+	}
+
+	pool := &Pool{
+		baseDialer:   NewBase(opts.Opts).(*base),
+		ctx:          ctx,
+		members:      make([]*poolMember, 0, len(opts.ProxyURLs)),
+		strategy:     opts.Strategy,
+		probeAddress: opts.ProbeAddress,
+		dialAttempts: opts.GetDialAttempts(),
+	}
+
+	for _, proxyURL := range opts.ProxyURLs {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse proxy url: %w", err)
+		}
+
+		dialer, err := DialerFromURL(opts.Opts, proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot make dialer for %s: %w", parsed.Host, err)
+		}
+
+		pool.members = append(pool.members, &poolMember{
+			dialer:  dialer,
+			id:      proxyURL,
+			address: parsed.Host,
+		})
+	}
+
+	go pool.run(opts.GetProbeInterval())
+
+	return pool, nil
+}
@@ -0,0 +1,286 @@
+package dialers_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/armon/go-socks5"
+	"github.com/go-httpproxy/httpproxy"
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolTestSuite struct {
+	suite.Suite
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	target    net.Listener
+	proxies   []*countingListener
+	deadAddr  string
+}
+
+func (suite *PoolTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+	target, _ := net.Listen("tcp", "127.0.0.1:0")
+	suite.target = target
+
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				buf := make([]byte, 1)
+
+				conn.Read(buf)
+				conn.Close()
+			}()
+		}
+	}()
+
+	suite.proxies = []*countingListener{suite.startSOCKS5(""), suite.startSOCKS5("")}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	suite.deadAddr = ln.Addr().String()
+
+	ln.Close()
+}
+
+func (suite *PoolTestSuite) TearDownTest() {
+	suite.ctxCancel()
+	suite.target.Close()
+
+	for _, v := range suite.proxies {
+		v.Close()
+	}
+}
+
+func (suite *PoolTestSuite) startSOCKS5(addr string) *countingListener {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	suite.Require().NoError(err)
+
+	listener := &countingListener{Listener: ln}
+	server, _ := socks5.New(&socks5.Config{})
+
+	go server.Serve(listener)
+
+	return listener
+}
+
+func (suite *PoolTestSuite) makePool(strategy dialers.PoolStrategy, addrs ...string) *dialers.Pool {
+	urls := make([]string, 0, len(addrs))
+
+	for _, v := range addrs {
+		urls = append(urls, "socks5://"+v)
+	}
+
+	pool, err := dialers.NewPool(suite.ctx, dialers.PoolOpts{
+		Opts:          dialers.Opts{Timeout: time.Second},
+		ProxyURLs:     urls,
+		Strategy:      strategy,
+		ProbeInterval: 50 * time.Millisecond,
+	})
+	suite.Require().NoError(err)
+
+	return pool
+}
+
+func (suite *PoolTestSuite) dial(ctx context.Context, pool *dialers.Pool) net.Conn {
+	host, port, _ := net.SplitHostPort(suite.target.Addr().String())
+
+	conn, err := pool.Dial(ctx, host, port)
+	suite.Require().NoError(err)
+
+	return conn
+}
+
+func (suite *PoolTestSuite) accepted(idx int) int32 {
+	return atomic.LoadInt32(&suite.proxies[idx].accepted)
+}
+
+func (suite *PoolTestSuite) TestNoProxies() {
+	_, err := dialers.NewPool(suite.ctx, dialers.PoolOpts{})
+
+	suite.Error(err)
+}
+
+func (suite *PoolTestSuite) TestUnknownStrategy() {
+	_, err := dialers.NewPool(suite.ctx, dialers.PoolOpts{
+		ProxyURLs: []string{"socks5://127.0.0.1:1080"},
+		Strategy:  dialers.PoolStrategyStickyHost + 1,
+	})
+
+	suite.Error(err)
+}
+
+func (suite *PoolTestSuite) TestRoundRobin() {
+	pool := suite.makePool(dialers.PoolStrategyRoundRobin,
+		suite.proxies[0].Addr().String(),
+		suite.proxies[1].Addr().String())
+
+	for i := 0; i < 4; i++ {
+		suite.dial(suite.ctx, pool).Close()
+	}
+
+	suite.EqualValues(2, suite.accepted(0))
+	suite.EqualValues(2, suite.accepted(1))
+}
+
+func (suite *PoolTestSuite) TestRandom() {
+	pool := suite.makePool(dialers.PoolStrategyRandom,
+		suite.proxies[0].Addr().String(),
+		suite.proxies[1].Addr().String())
+
+	for i := 0; i < 4; i++ {
+		suite.dial(suite.ctx, pool).Close()
+	}
+
+	suite.EqualValues(4, suite.accepted(0)+suite.accepted(1))
+}
+
+func (suite *PoolTestSuite) TestLeastConnections() {
+	pool := suite.makePool(dialers.PoolStrategyLeastConnections,
+		suite.proxies[0].Addr().String(),
+		suite.proxies[1].Addr().String())
+
+	conn := suite.dial(suite.ctx, pool)
+
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		suite.dial(suite.ctx, pool).Close()
+	}
+
+	suite.EqualValues(4, suite.accepted(0)+suite.accepted(1))
+	suite.True(suite.accepted(0) == 1 || suite.accepted(1) == 1)
+}
+
+func (suite *PoolTestSuite) TestStickyUser() {
+	pool := suite.makePool(dialers.PoolStrategyStickyUser,
+		suite.proxies[0].Addr().String(),
+		suite.proxies[1].Addr().String())
+
+	ctx := dialers.WithUser(suite.ctx, "user")
+
+	for i := 0; i < 4; i++ {
+		suite.dial(ctx, pool).Close()
+	}
+
+	suite.True(suite.accepted(0) == 4 || suite.accepted(1) == 4)
+}
+
+func (suite *PoolTestSuite) TestStickyHost() {
+	pool := suite.makePool(dialers.PoolStrategyStickyHost,
+		suite.proxies[0].Addr().String(),
+		suite.proxies[1].Addr().String())
+
+	for i := 0; i < 4; i++ {
+		suite.dial(suite.ctx, pool).Close()
+	}
+
+	suite.True(suite.accepted(0) == 4 || suite.accepted(1) == 4)
+}
+
+func (suite *PoolTestSuite) TestFailover() {
+	pool := suite.makePool(dialers.PoolStrategyRoundRobin,
+		suite.deadAddr,
+		suite.proxies[0].Addr().String())
+
+	for i := 0; i < 4; i++ {
+		suite.dial(suite.ctx, pool).Close()
+	}
+
+	suite.EqualValues(4, suite.accepted(0))
+}
+
+func (suite *PoolTestSuite) TestProbe() {
+	pool := suite.makePool(dialers.PoolStrategyRoundRobin,
+		suite.deadAddr,
+		suite.proxies[0].Addr().String())
+
+	suite.dial(suite.ctx, pool).Close()
+	suite.EqualValues(1, suite.accepted(0))
+
+	suite.proxies = append(suite.proxies, suite.startSOCKS5(suite.deadAddr))
+
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		suite.dial(suite.ctx, pool).Close()
+	}
+
+	suite.EqualValues(2, suite.accepted(2))
+}
+
+func (suite *PoolTestSuite) TestHTTPProxy() {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	proxy, _ := httpproxy.NewProxy()
+	listener := &countingListener{Listener: ln}
+
+	suite.proxies = append(suite.proxies, listener)
+
+	go (&http.Server{Handler: proxy}).Serve(listener)
+
+	pool, err := dialers.NewPool(suite.ctx, dialers.PoolOpts{
+		ProxyURLs: []string{"http://" + ln.Addr().String()},
+	})
+
+	suite.NoError(err)
+	suite.dial(suite.ctx, pool).Close()
+	suite.EqualValues(1, suite.accepted(2))
+}
+
+func (suite *PoolTestSuite) TestTunnelRefused() {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	listener := &countingListener{Listener: ln}
+
+	suite.proxies = append(suite.proxies, listener)
+
+	go (&http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}),
+	}).Serve(listener)
+
+	pool, err := dialers.NewPool(suite.ctx, dialers.PoolOpts{
+		Opts: dialers.Opts{Timeout: time.Second},
+		ProxyURLs: []string{
+			"socks5://" + suite.proxies[0].Addr().String(),
+			"http://" + ln.Addr().String(),
+		},
+		ProbeInterval: time.Hour,
+	})
+
+	suite.Require().NoError(err)
+
+	host, port, _ := net.SplitHostPort(suite.target.Addr().String())
+
+	_, err = pool.Dial(suite.ctx, host, port)
+
+	suite.Error(err)
+	suite.EqualValues(1, suite.accepted(2))
+	suite.EqualValues(0, suite.accepted(0))
+
+	suite.dial(suite.ctx, pool).Close()
+
+	_, err = pool.Dial(suite.ctx, host, port)
+
+	suite.Error(err)
+	suite.EqualValues(2, suite.accepted(2))
+	suite.EqualValues(1, suite.accepted(0))
+}
+
+func TestPool(t *testing.T) {
+	suite.Run(t, &PoolTestSuite{})
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 
+	"github.com/9seconds/httransform/v2/errors"
 	"github.com/valyala/fasthttp"
 	"golang.org/x/net/proxy"
 )
@@ -15,7 +17,20 @@ type socksProxy struct {
 }
 
 func (s *socksProxy) Dial(ctx context.Context, host, port string) (net.Conn, error) {
-	return s.proxy.DialContext(ctx, "tcp", net.JoinHostPort(host, port)) // nolint: wrapcheck
+	conn, err := s.proxy.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+
+	// x/net/proxy has no typed errors for reply statuses.
+	var opErr *net.OpError
+
+	if errors.As(err, &opErr) && strings.HasPrefix(opErr.Err.Error(), "unknown error ") {
+		return nil, &errors.Error{
+			Message: "socks5 proxy has responded with " + opErr.Err.Error(),
+			Code:    "socks5_proxy_dial",
+			Err:     errTunnelRefused,
+		}
+	}
+
+	return conn, err // nolint: wrapcheck
 }
 
 func (s *socksProxy) UpgradeToTLS(ctx context.Context, conn net.Conn, host, port string) (net.Conn, error) {
@@ -0,0 +1,40 @@
+package dialers
+
+import (
+	"context"
+	"net"
+
+	"github.com/9seconds/httransform/v2/errors"
+)
+
+// errTunnelRefused is wrapped into errors of proxies which have
+// refused to establish a tunnel to the netloc: CONNECT has got
+// non-200 response or SOCKS5 reply has a failure status. Proxy itself
+// is alive in this case, a problem is in the netloc.
+var errTunnelRefused = &errors.Error{
+	Message: "proxy has refused to establish a tunnel",
+	Code:    "tunnel_refused",
+}
+
+// tunneler is implemented by dialers which Dial does not establish
+// a raw TCP connection to the target. For example, HTTP proxy dialer
+// connects to a proxy and a tunnel requires CONNECT method.
+type tunneler interface {
+	DialTunnel(ctx context.Context, host, port string) (net.Conn, error)
+}
+
+// isTunnelRefused checks if error is reported by alive proxy about the
+// netloc.
+func isTunnelRefused(err error) bool {
+	return errors.Is(err, errTunnelRefused)
+}
+
+// dialTunnel establishes a raw TCP connection to the given address
+// with a dialer. It is used to chain dialers.
+func dialTunnel(ctx context.Context, dialer Dialer, host, port string) (net.Conn, error) {
+	if value, ok := dialer.(tunneler); ok {
+		return value.DialTunnel(ctx, host, port) // nolint: wrapcheck
+	}
+
+	return dialer.Dial(ctx, host, port) // nolint: wrapcheck
+}
@@ -1,9 +1,17 @@
 package dialers_test
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/armon/go-socks5"
+	"github.com/go-httpproxy/httpproxy"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -44,3 +52,140 @@ func (suite *DialerFromURLTestSuite) TestUnkown() {
 func TestDialerFromURL(t *testing.T) {
 	suite.Run(t, &DialerFromURLTestSuite{})
 }
+
+type countingListener struct {
+	net.Listener
+
+	accepted int32
+}
+
+func (c *countingListener) Accept() (net.Conn, error) {
+	conn, err := c.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&c.accepted, 1)
+	}
+
+	return conn, err
+}
+
+type DialerFromURLsTestSuite struct {
+	suite.Suite
+
+	endpoint      *httptest.Server
+	httpListener  *countingListener
+	socksListener *countingListener
+}
+
+func (suite *DialerFromURLsTestSuite) SetupSuite() {
+	suite.endpoint = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	httpListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	httpProxy, _ := httpproxy.NewProxy()
+	suite.httpListener = &countingListener{Listener: httpListener}
+
+	go (&http.Server{Handler: httpProxy}).Serve(suite.httpListener)
+
+	socksListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	socksServer, _ := socks5.New(&socks5.Config{})
+	suite.socksListener = &countingListener{Listener: socksListener}
+
+	go socksServer.Serve(suite.socksListener)
+
+	time.Sleep(50 * time.Millisecond)
+}
+
+func (suite *DialerFromURLsTestSuite) TearDownSuite() {
+	suite.endpoint.Close()
+	suite.httpListener.Close()
+	suite.socksListener.Close()
+}
+
+func (suite *DialerFromURLsTestSuite) SetupTest() {
+	atomic.StoreInt32(&suite.httpListener.accepted, 0)
+	atomic.StoreInt32(&suite.socksListener.accepted, 0)
+}
+
+func (suite *DialerFromURLsTestSuite) get(dialer dialers.Dialer) {
+	httpClient := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialTLSContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				host, port, _ := net.SplitHostPort(address)
+
+				conn, err := dialer.Dial(ctx, host, port)
+				if err != nil {
+					return nil, err
+				}
+
+				return dialer.UpgradeToTLS(ctx, conn, host, port)
+			},
+		},
+	}
+
+	resp, err := httpClient.Get(suite.endpoint.URL)
+
+	suite.NoError(err)
+
+	if resp != nil {
+		resp.Body.Close()
+		suite.Equal(http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func (suite *DialerFromURLsTestSuite) TestHTTPThenSOCKS5() {
+	dialer, err := dialers.DialerFromURLs(dialers.Opts{TLSSkipVerify: true}, []string{
+		"http://" + suite.httpListener.Addr().String(),
+		"socks5://" + suite.socksListener.Addr().String(),
+	})
+
+	suite.NoError(err)
+	suite.get(dialer)
+	suite.EqualValues(1, atomic.LoadInt32(&suite.httpListener.accepted))
+	suite.EqualValues(1, atomic.LoadInt32(&suite.socksListener.accepted))
+}
+
+func (suite *DialerFromURLsTestSuite) TestSOCKS5ThenHTTP() {
+	dialer, err := dialers.DialerFromURLs(dialers.Opts{TLSSkipVerify: true}, []string{
+		"socks5://" + suite.socksListener.Addr().String(),
+		"http://" + suite.httpListener.Addr().String(),
+	})
+
+	suite.NoError(err)
+	suite.get(dialer)
+	suite.EqualValues(1, atomic.LoadInt32(&suite.httpListener.accepted))
+	suite.EqualValues(1, atomic.LoadInt32(&suite.socksListener.accepted))
+}
+
+func (suite *DialerFromURLsTestSuite) TestHTTPThenHTTP() {
+	dialer, err := dialers.DialerFromURLs(dialers.Opts{TLSSkipVerify: true}, []string{
+		"http://" + suite.httpListener.Addr().String(),
+		"http://" + suite.httpListener.Addr().String(),
+	})
+
+	suite.NoError(err)
+	suite.get(dialer)
+	suite.EqualValues(2, atomic.LoadInt32(&suite.httpListener.accepted))
+}
+
+func (suite *DialerFromURLsTestSuite) TestNoURLs() {
+	dialer, err := dialers.DialerFromURLs(dialers.Opts{TLSSkipVerify: true}, nil)
+
+	suite.NoError(err)
+	suite.get(dialer)
+	suite.EqualValues(0, atomic.LoadInt32(&suite.httpListener.accepted))
+}
+
+func (suite *DialerFromURLsTestSuite) TestIncorrectURL() {
+	_, err := dialers.DialerFromURLs(dialers.Opts{}, []string{
+		"http://" + suite.httpListener.Addr().String(),
+		"unknown://addr.com:3131",
+	})
+
+	suite.Error(err)
+}
+
+func TestDialerFromURLs(t *testing.T) {
+	suite.Run(t, &DialerFromURLsTestSuite{})
+}
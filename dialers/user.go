@@ -0,0 +1,19 @@
+package dialers
+
+import "context"
+
+type ctxKeyUser struct{}
+
+// WithUser returns a derived context which carries a name of the
+// user a connection is established for. Dialers can use it to choose
+// an upstream, see PoolStrategyStickyUser.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, ctxKeyUser{}, user)
+}
+
+// User returns a name of the user set by WithUser.
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(ctxKeyUser{}).(string)
+
+	return user
+}
@@ -3,6 +3,7 @@ package dns
 import (
 	"context"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/9seconds/httransform/v2/cache"
@@ -10,46 +11,128 @@ import (
 	"github.com/valyala/fastrand"
 )
 
+// dnsEntry is a cached result of the lookup. Negative results (no
+// such host or no IPs) are cached as well.
+type dnsEntry struct {
+	hosts     []string
+	err       error
+	expiresAt time.Time
+}
+
+// dnsCall is a lookup in progress. Concurrent lookups of the same
+// hostname wait for the same call.
+type dnsCall struct {
+	done  chan struct{}
+	entry *dnsEntry
+}
+
 // DNS is a caching resolver.
+//
+// It caches records for their TTLs, NXDOMAIN responses are cached
+// for NegativeCacheTTL. Records with zero TTL are not cached. When a record expires, it is served for
+// StaleTTL more while a fresh one is resolved in background.
 type DNS struct {
-	resolver net.Resolver
-	cache    cache.Interface
+	resolver   Resolver
+	cache      cache.TTLInterface
+	cacheTTL   time.Duration
+	callsMutex sync.Mutex
+	calls      map[string]*dnsCall
 }
 
 // Lookup to conform Interface.
-func (d *DNS) Lookup(ctx context.Context, hostname string) (hosts []string, err error) {
-	if hh := d.cache.Get(hostname); hh != nil {
-		hosts, _ = hh.([]string)
-	} else {
-		hosts, err = d.doLookup(ctx, hostname)
-
-		if err != nil {
-			return nil, errors.Annotate(err, "cannot resolve dns", "dns_lookup", 0)
+func (d *DNS) Lookup(ctx context.Context, hostname string) ([]string, error) {
+	if ip := net.ParseIP(hostname); ip != nil {
+		return []string{ip.String()}, nil
+	}
+
+	var entry *dnsEntry
+
+	if value := d.cache.Get(hostname); value != nil {
+		entry, _ = value.(*dnsEntry)
+	}
+
+	switch {
+	case entry == nil:
+		call := d.startCall(hostname)
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Annotate(ctx.Err(), "cannot resolve dns", "dns_lookup", 0)
+		case <-call.done:
+			entry = call.entry
 		}
+	case time.Now().After(entry.expiresAt):
+		d.startCall(hostname)
+	}
 
-		d.cache.Add(hostname, hosts)
+	if entry.err != nil {
+		return nil, errors.Annotate(entry.err, "cannot resolve dns", "dns_lookup", 0)
 	}
 
-	return d.shuffle(hosts), nil
+	return d.shuffle(entry.hosts), nil
 }
 
-func (d *DNS) doLookup(ctx context.Context, hostname string) ([]string, error) {
-	if net.ParseIP(hostname) == nil {
-		return d.resolver.LookupHost(ctx, hostname) // nolint: wrapcheck
+func (d *DNS) startCall(hostname string) *dnsCall {
+	d.callsMutex.Lock()
+	defer d.callsMutex.Unlock()
+
+	if call, ok := d.calls[hostname]; ok {
+		return call
 	}
 
-	addrs, err := d.resolver.LookupIPAddr(ctx, hostname)
-	if err != nil {
-		return nil, err // nolint: wrapcheck
+	call := &dnsCall{
+		done: make(chan struct{}),
 	}
+	d.calls[hostname] = call
 
-	hostnames := make([]string, len(addrs))
+	go func() {
+		call.entry = d.doLookup(hostname)
 
-	for i := range addrs {
-		hostnames[i] = addrs[i].IP.String()
+		d.callsMutex.Lock()
+		delete(d.calls, hostname)
+		d.callsMutex.Unlock()
+
+		close(call.done)
+	}()
+
+	return call
+}
+
+// doLookup resolves a hostname and caches a result. A lookup is not
+// bound to any request: it is shared between many of them and can be
+// done in background.
+func (d *DNS) doLookup(hostname string) *dnsEntry {
+	ctx, cancel := context.WithTimeout(context.Background(), LookupTimeout)
+	defer cancel()
+
+	hosts, ttl, err := d.resolver.Resolve(ctx, hostname)
+	entry := &dnsEntry{
+		hosts: hosts,
+		err:   err,
 	}
 
-	return hostnames, nil
+	switch {
+	case errors.Is(err, ErrNoSuchHost), err == nil && len(hosts) == 0:
+		entry.expiresAt = time.Now().Add(NegativeCacheTTL)
+		d.cache.AddWithTTL(hostname, entry, NegativeCacheTTL)
+	case err == nil && ttl == 0:
+		// nameserver asks not to cache records so the entry is only
+		// shared with lookups which wait for this call.
+	case err == nil:
+		if ttl < 0 {
+			ttl = d.cacheTTL
+		}
+
+		entry.expiresAt = time.Now().Add(ttl)
+		d.cache.AddWithTTL(hostname, entry, ttl+StaleTTL)
+	}
+
+	// cache is updated asynchronously: a call has to be finished only
+	// when entry is visible. Otherwise concurrent lookups start new
+	// calls.
+	d.cache.Wait()
+
+	return entry
 }
 
 func (d *DNS) shuffle(in []string) []string {
@@ -73,9 +156,30 @@ func (d *DNS) shuffle(in []string) []string {
 	return out
 }
 
-// New returns a new instance of DNS cache.
+// New returns a new instance of DNS cache which uses a resolver of
+// the operating system.
 func New(cacheSize int, cacheTTL time.Duration, evictCallback cache.EvictCallback) Interface {
+	return NewWithResolver(NewSystemResolver(), cacheSize, cacheTTL, evictCallback)
+}
+
+// NewWithResolver returns a new instance of DNS cache which uses a
+// given backend to query nameservers. cacheTTL is used for records if
+// backend does not know their TTLs.
+//
+// evictCallback gets a list of IPs as a value. It is nil for negative
+// results.
+func NewWithResolver(resolver Resolver,
+	cacheSize int,
+	cacheTTL time.Duration,
+	evictCallback cache.EvictCallback) Interface {
 	return &DNS{
-		cache: cache.New(cacheSize, cacheTTL, evictCallback),
+		resolver: resolver,
+		cache: cache.New(cacheSize, cacheTTL, func(key string, value interface{}) {
+			if entry, ok := value.(*dnsEntry); ok {
+				evictCallback(key, entry.hosts)
+			}
+		}),
+		cacheTTL: cacheTTL,
+		calls:    map[string]*dnsCall{},
 	}
 }
@@ -2,14 +2,41 @@ package dns_test
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/9seconds/httransform/v2/cache"
 	"github.com/9seconds/httransform/v2/dns"
 	"github.com/stretchr/testify/suite"
 )
 
+type ResolverMock struct {
+	calls int32
+	ips   []string
+	ttl   time.Duration
+	err   error
+	wait  chan struct{}
+}
+
+func (r *ResolverMock) Resolve(ctx context.Context, hostname string) ([]string, time.Duration, error) {
+	atomic.AddInt32(&r.calls, 1)
+
+	if r.wait != nil {
+		<-r.wait
+	}
+
+	return r.ips, r.ttl, r.err
+}
+
+func (r *ResolverMock) Calls() int32 {
+	return atomic.LoadInt32(&r.calls)
+}
+
 type DNSTestSuite struct {
 	suite.Suite
 
@@ -40,6 +67,162 @@ func (suite *DNSTestSuite) TestResolveIP() {
 	suite.NotEmpty(names)
 }
 
+func (suite *DNSTestSuite) TestCustomResolver() {
+	resolver := dns.NewWithResolver(dns.NewHostsResolver(map[string][]string{
+		"example.test": {"10.0.0.1", "10.0.0.2"},
+	}, nil), dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	names, err := resolver.Lookup(context.Background(), "example.test")
+
+	suite.NoError(err)
+	suite.ElementsMatch([]string{"10.0.0.1", "10.0.0.2"}, names)
+
+	names, err = resolver.Lookup(context.Background(), "10.0.0.3")
+
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.3"}, names)
+
+	_, err = resolver.Lookup(context.Background(), "unknown.test")
+
+	suite.Error(err)
+}
+
+func (suite *DNSTestSuite) TestRecordTTL() {
+	resolver := &ResolverMock{
+		ips: []string{"10.0.0.1"},
+		ttl: 100 * time.Millisecond,
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	_, err := cached.Lookup(context.Background(), "example.test")
+
+	suite.NoError(err)
+
+	_, err = cached.Lookup(context.Background(), "example.test")
+
+	suite.NoError(err)
+	suite.EqualValues(1, resolver.Calls())
+
+	time.Sleep(200 * time.Millisecond)
+
+	// expired record is served while it is refreshed in background.
+	names, err := cached.Lookup(context.Background(), "example.test")
+
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.1"}, names)
+	suite.Eventually(func() bool {
+		return resolver.Calls() == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *DNSTestSuite) TestZeroTTL() {
+	resolver := &ResolverMock{
+		ips: []string{"10.0.0.1"},
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	for i := 0; i < 2; i++ {
+		names, err := cached.Lookup(context.Background(), "example.test")
+
+		suite.NoError(err)
+		suite.Equal([]string{"10.0.0.1"}, names)
+	}
+
+	suite.EqualValues(2, resolver.Calls())
+}
+
+func (suite *DNSTestSuite) TestUnknownTTL() {
+	resolver := &ResolverMock{
+		ips: []string{"10.0.0.1"},
+		ttl: dns.UnknownTTL,
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.Lookup(context.Background(), "example.test")
+
+		suite.NoError(err)
+	}
+
+	suite.EqualValues(1, resolver.Calls())
+}
+
+func (suite *DNSTestSuite) TestNegativeCache() {
+	resolver := &ResolverMock{
+		err: dns.ErrNoSuchHost,
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	_, err := cached.Lookup(context.Background(), "unknown.test")
+
+	suite.True(errors.Is(err, dns.ErrNoSuchHost))
+
+	_, err = cached.Lookup(context.Background(), "unknown.test")
+
+	suite.True(errors.Is(err, dns.ErrNoSuchHost))
+	suite.EqualValues(1, resolver.Calls())
+}
+
+func (suite *DNSTestSuite) TestNoCacheForErrors() {
+	resolver := &ResolverMock{
+		err: io.EOF,
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	_, err := cached.Lookup(context.Background(), "unknown.test")
+
+	suite.Error(err)
+
+	_, err = cached.Lookup(context.Background(), "unknown.test")
+
+	suite.Error(err)
+	suite.EqualValues(2, resolver.Calls())
+}
+
+func (suite *DNSTestSuite) TestSingleFlight() {
+	resolver := &ResolverMock{
+		ips:  []string{"10.0.0.1"},
+		wait: make(chan struct{}),
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			names, err := cached.Lookup(context.Background(), "example.test")
+
+			suite.NoError(err)
+			suite.Equal([]string{"10.0.0.1"}, names)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(resolver.wait)
+	wg.Wait()
+
+	suite.EqualValues(1, resolver.Calls())
+}
+
+func (suite *DNSTestSuite) TestContextClosed() {
+	resolver := &ResolverMock{
+		ips:  []string{"10.0.0.1"},
+		wait: make(chan struct{}),
+	}
+	cached := dns.NewWithResolver(resolver, dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+	defer cancel()
+	defer close(resolver.wait)
+
+	_, err := cached.Lookup(ctx, "example.test")
+
+	suite.Error(err)
+}
+
 func TestDNS(t *testing.T) {
 	suite.Run(t, &DNSTestSuite{})
 }
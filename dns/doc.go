@@ -1,4 +1,19 @@
 // Cachning DNS resolver.
 //
-// This package has a global instance of caching DNS service.
+// This package has a global instance of caching DNS service which
+// uses a resolver of the operating system.
+//
+// If you want to query specific nameservers, please create your own
+// instance with NewWithResolver and pass it to dialers.Opts and
+// layers.NewFilterSubnetsLayerWithDNS. There are several backends:
+// plain UDP/TCP nameservers and DNS-over-TLS (NewNameserverResolver),
+// DNS-over-HTTPS (NewDoHResolver) and static overrides in /etc/hosts
+// format (NewHostsResolver) which can be put in front of any other
+// backend.
+//
+// Cache honors TTLs of records if backend knows them. Hostnames which
+// do not exist are cached for NegativeCacheTTL. Expired records are
+// served for StaleTTL more while they are refreshed in background, and
+// concurrent lookups of the same hostname are collapsed into a single
+// query.
 package dns
@@ -0,0 +1,18 @@
+package dns
+
+import "github.com/9seconds/httransform/v2/errors"
+
+var (
+	// ErrNoSuchHost is returned if nameserver says that hostname does
+	// not exist (NXDOMAIN).
+	ErrNoSuchHost = &errors.Error{
+		Message: "no such host",
+		Code:    "dns_no_such_host",
+	}
+
+	// ErrNoNameservers is returned if resolver is created without
+	// nameservers.
+	ErrNoNameservers = &errors.Error{
+		Message: "no nameservers",
+	}
+)
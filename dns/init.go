@@ -10,8 +10,25 @@ const (
 	// CacheSize is a size of DNS cache.
 	CacheSize = 512
 
-	// CacheTTL is a TTL of a DNS record in a cache.
+	// CacheTTL is a TTL of a DNS record in a cache if resolver does
+	// not know a real one.
 	CacheTTL = 5 * time.Minute
+
+	// NegativeCacheTTL defines for how long we remember that hostname
+	// does not exist or has no IPs.
+	NegativeCacheTTL = 30 * time.Second
+
+	// StaleTTL defines for how long an expired record is served while
+	// a fresh one is resolved in background.
+	StaleTTL = time.Minute
+
+	// UnknownTTL is returned by resolvers which do not know TTLs of
+	// the records. Such records are cached for a default TTL.
+	UnknownTTL time.Duration = -1
+
+	// LookupTimeout defines a timeout of a single lookup. Lookups are
+	// shared between requests so they are not bound to any of them.
+	LookupTimeout = 30 * time.Second
 )
 
 // Default is a default DNS resolver you usually want to use everywhere.
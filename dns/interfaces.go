@@ -1,6 +1,9 @@
 package dns
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Interface is an interface for cachind DNS resolver.
 type Interface interface {
@@ -9,3 +12,12 @@ type Interface interface {
 	// execution.
 	Lookup(context.Context, string) ([]string, error)
 }
+
+// Resolver is an interface for DNS backends which are used by caching
+// resolver to query nameservers.
+type Resolver interface {
+	// Resolve returns IPv4 and IPv6 addresses of given hostname and a
+	// TTL of these records. UnknownTTL means that backend does not know
+	// it. Zero TTL means that records must not be cached.
+	Resolve(context.Context, string) ([]string, time.Duration, error)
+}
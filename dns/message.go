@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/valyala/fastrand"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// exchanger sends a DNS query to a nameserver and returns a raw
+// response.
+type exchanger interface {
+	exchange(ctx context.Context, query []byte) ([]byte, error)
+}
+
+// messageResolver is a base for backends which talk DNS wire protocol
+// with nameservers by themselves. It sends A and AAAA queries in
+// parallel.
+type messageResolver struct {
+	exchanger exchanger
+}
+
+type messageResult struct {
+	ips []string
+	ttl time.Duration
+	err error
+}
+
+func (m messageResolver) Resolve(ctx context.Context, hostname string) ([]string, time.Duration, error) {
+	results := [2]messageResult{}
+	wg := &sync.WaitGroup{}
+
+	for i, qtype := range [2]dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		wg.Add(1)
+
+		go func(result *messageResult, qtype dnsmessage.Type) {
+			defer wg.Done()
+
+			result.ips, result.ttl, result.err = m.query(ctx, hostname, qtype)
+		}(&results[i], qtype)
+	}
+
+	wg.Wait()
+
+	var (
+		ips []string
+		ttl time.Duration
+	)
+
+	for _, result := range results {
+		if result.err != nil || len(result.ips) == 0 {
+			continue
+		}
+
+		if len(ips) == 0 || result.ttl < ttl {
+			ttl = result.ttl
+		}
+
+		ips = append(ips, result.ips...)
+	}
+
+	if len(ips) == 0 {
+		for _, result := range results {
+			if result.err != nil {
+				return nil, 0, result.err
+			}
+		}
+	}
+
+	return ips, ttl, nil
+}
+
+func (m messageResolver) query(ctx context.Context,
+	hostname string,
+	qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	id := uint16(fastrand.Uint32())
+
+	query, err := messageMakeQuery(id, hostname, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response, err := m.exchanger.exchange(ctx, query)
+	if err != nil {
+		return nil, 0, errors.Annotate(err, "cannot query nameserver", "dns_exchange", 0)
+	}
+
+	return messageParseResponse(query, response)
+}
+
+func messageMakeQuery(id uint16, hostname string, qtype dnsmessage.Type) ([]byte, error) {
+	if !strings.HasSuffix(hostname, ".") {
+		hostname += "."
+	}
+
+	name, err := dnsmessage.NewName(hostname)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect hostname: %w", err)
+	}
+
+	builder := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ // nolint: gomnd
+		ID:               id,
+		RecursionDesired: true,
+	})
+
+	builder.EnableCompression()
+
+	if err := builder.StartQuestions(); err != nil {
+		return nil, fmt.Errorf("cannot build a query: %w", err)
+	}
+
+	err = builder.Question(dnsmessage.Question{
+		Name:  name,
+		Type:  qtype,
+		Class: dnsmessage.ClassINET,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot build a query: %w", err)
+	}
+
+	query, err := builder.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("cannot build a query: %w", err)
+	}
+
+	return query, nil
+}
+
+// messageIsResponseTo checks if response answers a given query: it
+// has to have the same id and the same question.
+func messageIsResponseTo(query, response []byte) bool {
+	queryParser := dnsmessage.Parser{}
+	responseParser := dnsmessage.Parser{}
+
+	queryHeader, err := queryParser.Start(query)
+	if err != nil {
+		return false
+	}
+
+	responseHeader, err := responseParser.Start(response)
+	if err != nil || responseHeader.ID != queryHeader.ID || !responseHeader.Response {
+		return false
+	}
+
+	queryQuestion, err := queryParser.Question()
+	if err != nil {
+		return false
+	}
+
+	responseQuestion, err := responseParser.Question()
+	if err != nil {
+		return false
+	}
+
+	// names are case-insensitive and some nameservers do not preserve
+	// a case of the question.
+	return responseQuestion.Type == queryQuestion.Type &&
+		responseQuestion.Class == queryQuestion.Class &&
+		strings.EqualFold(responseQuestion.Name.String(), queryQuestion.Name.String())
+}
+
+func messageParseResponse(query, response []byte) ([]string, time.Duration, error) {
+	parser := dnsmessage.Parser{}
+
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot parse a response: %w", err)
+	}
+
+	switch {
+	case !messageIsResponseTo(query, response):
+		return nil, 0, fmt.Errorf("unexpected response %d", header.ID)
+	case header.RCode == dnsmessage.RCodeNameError:
+		return nil, 0, ErrNoSuchHost
+	case header.RCode != dnsmessage.RCodeSuccess:
+		return nil, 0, fmt.Errorf("nameserver has responded with %v", header.RCode)
+	}
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("cannot parse questions: %w", err)
+	}
+
+	var (
+		ips []string
+		ttl uint32
+	)
+
+	for {
+		answer, err := parser.AnswerHeader()
+
+		switch {
+		case errors.Is(err, dnsmessage.ErrSectionDone):
+			return ips, time.Duration(ttl) * time.Second, nil
+		case err != nil:
+			return nil, 0, fmt.Errorf("cannot parse answers: %w", err)
+		}
+
+		var ip net.IP
+
+		switch answer.Type {
+		case dnsmessage.TypeA:
+			resource, err := parser.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot parse A record: %w", err)
+			}
+
+			ip = resource.A[:]
+		case dnsmessage.TypeAAAA:
+			resource, err := parser.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot parse AAAA record: %w", err)
+			}
+
+			ip = resource.AAAA[:]
+		default:
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("cannot parse answers: %w", err)
+			}
+
+			continue
+		}
+
+		ips = append(ips, ip.String())
+
+		if len(ips) == 1 || answer.TTL < ttl {
+			ttl = answer.TTL
+		}
+	}
+}
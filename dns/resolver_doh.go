@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const dohContentType = "application/dns-message"
+
+// DoHOpts defines a set of options for NewDoHResolver.
+type DoHOpts struct {
+	// URL is an URL of DNS-over-HTTPS endpoint, for example,
+	// https://cloudflare-dns.com/dns-query.
+	URL string
+
+	// HTTPClient is a client which is used to send queries. Default is
+	// http.DefaultClient with a timeout of DefaultQueryTimeout.
+	HTTPClient *http.Client
+}
+
+// GetHTTPClient returns HTTP client or fallbacks to default one.
+func (d *DoHOpts) GetHTTPClient() *http.Client {
+	if d.HTTPClient == nil {
+		return &http.Client{
+			Timeout: DefaultQueryTimeout,
+		}
+	}
+
+	return d.HTTPClient
+}
+
+type dohExchanger struct {
+	url    string
+	client *http.Client
+}
+
+func (d *dohExchanger) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("cannot build a request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("cannot send a request: %w", err)
+	}
+
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	response, err := ioutil.ReadAll(io.LimitReader(resp.Body, nameserverMaxMessageSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read a response: %w", err)
+	}
+
+	return response, nil
+}
+
+// NewDoHResolver returns a backend which sends queries to
+// DNS-over-HTTPS (RFC 8484) endpoint.
+func NewDoHResolver(opts DoHOpts) (Resolver, error) {
+	parsed, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect url: %w", err)
+	}
+
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported url scheme %s", parsed.Scheme)
+	}
+
+	return messageResolver{
+		exchanger: &dohExchanger{
+			url:    opts.URL,
+			client: opts.GetHTTPClient(),
+		},
+	}, nil
+}
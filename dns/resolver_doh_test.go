@@ -0,0 +1,79 @@
+package dns_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/dns"
+	"github.com/stretchr/testify/suite"
+)
+
+type DoHResolverTestSuite struct {
+	suite.Suite
+
+	server *httptest.Server
+}
+
+func (suite *DoHResolverTestSuite) SetupSuite() {
+	stub := &dnsStub{
+		records: map[string][]string{
+			"example.test": {"10.0.0.1", "fd00::1"},
+		},
+	}
+
+	suite.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		query, _ := ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(stub.handle(query, false))
+	}))
+}
+
+func (suite *DoHResolverTestSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+func (suite *DoHResolverTestSuite) TestIncorrectURL() {
+	_, err := dns.NewDoHResolver(dns.DoHOpts{
+		URL: "ftp://example.com",
+	})
+
+	suite.Error(err)
+}
+
+func (suite *DoHResolverTestSuite) TestResolve() {
+	resolver, err := dns.NewDoHResolver(dns.DoHOpts{
+		URL:        suite.server.URL + "/dns-query",
+		HTTPClient: suite.server.Client(),
+	})
+
+	suite.Require().NoError(err)
+
+	ips, _, err := resolver.Resolve(context.Background(), "example.test")
+
+	suite.NoError(err)
+	suite.ElementsMatch([]string{"10.0.0.1", "fd00::1"}, ips)
+}
+
+func (suite *DoHResolverTestSuite) TestNotVerified() {
+	resolver, _ := dns.NewDoHResolver(dns.DoHOpts{
+		URL: suite.server.URL + "/dns-query",
+	})
+
+	_, _, err := resolver.Resolve(context.Background(), "example.test")
+
+	suite.Error(err)
+}
+
+func TestDoHResolver(t *testing.T) {
+	suite.Run(t, &DoHResolverTestSuite{})
+}
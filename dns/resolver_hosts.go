@@ -0,0 +1,88 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+type hostsResolver struct {
+	hosts    map[string][]string
+	fallback Resolver
+}
+
+func (h *hostsResolver) Resolve(ctx context.Context, hostname string) ([]string, time.Duration, error) {
+	if ips, ok := h.hosts[hostsNormalize(hostname)]; ok {
+		return ips, UnknownTTL, nil
+	}
+
+	if h.fallback == nil {
+		return nil, 0, ErrNoSuchHost
+	}
+
+	return h.fallback.Resolve(ctx, hostname) // nolint: wrapcheck
+}
+
+// NewHostsResolver returns a backend which resolves hostnames with
+// static overrides. Hostnames which are absent in hosts are passed to
+// fallback. If fallback is nil, these hostnames are not resolved.
+//
+// hosts maps hostnames to their IPs. You can read it from a file in
+// /etc/hosts format with ParseHosts.
+func NewHostsResolver(hosts map[string][]string, fallback Resolver) Resolver {
+	normalized := make(map[string][]string, len(hosts))
+
+	for k, v := range hosts {
+		name := hostsNormalize(k)
+		normalized[name] = append(normalized[name], v...)
+	}
+
+	return &hostsResolver{
+		hosts:    normalized,
+		fallback: fallback,
+	}
+}
+
+// ParseHosts parses a content in /etc/hosts format: an IP and a list
+// of its hostnames on each line, comments are started with '#'.
+func ParseHosts(reader io.Reader) (map[string][]string, error) {
+	hosts := map[string][]string{}
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			return nil, fmt.Errorf("incorrect ip %s", fields[0])
+		}
+
+		for _, name := range fields[1:] {
+			name = hostsNormalize(name)
+			hosts[name] = append(hosts[name], ip.String())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read hosts: %w", err)
+	}
+
+	return hosts, nil
+}
+
+func hostsNormalize(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
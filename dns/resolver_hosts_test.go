@@ -0,0 +1,67 @@
+package dns_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/dns"
+	"github.com/stretchr/testify/suite"
+)
+
+type HostsResolverTestSuite struct {
+	suite.Suite
+}
+
+func (suite *HostsResolverTestSuite) TestParseHosts() {
+	hosts, err := dns.ParseHosts(strings.NewReader(`
+# comment
+127.0.0.1 localhost Local.Test.
+::1       localhost # another comment
+`))
+
+	suite.NoError(err)
+	suite.Equal(map[string][]string{
+		"localhost":  {"127.0.0.1", "::1"},
+		"local.test": {"127.0.0.1"},
+	}, hosts)
+}
+
+func (suite *HostsResolverTestSuite) TestParseHostsIncorrectIP() {
+	_, err := dns.ParseHosts(strings.NewReader("localhost 127.0.0.1"))
+
+	suite.Error(err)
+}
+
+func (suite *HostsResolverTestSuite) TestResolve() {
+	resolver := dns.NewHostsResolver(map[string][]string{
+		"Example.Test": {"10.0.0.1"},
+	}, nil)
+
+	ips, _, err := resolver.Resolve(context.Background(), "example.test.")
+
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.1"}, ips)
+
+	_, _, err = resolver.Resolve(context.Background(), "unknown.test")
+
+	suite.True(errors.Is(err, dns.ErrNoSuchHost))
+}
+
+func (suite *HostsResolverTestSuite) TestFallback() {
+	resolver := dns.NewHostsResolver(map[string][]string{
+		"example.test": {"10.0.0.1"},
+	}, dns.NewHostsResolver(map[string][]string{
+		"fallback.test": {"10.0.0.2"},
+	}, nil))
+
+	ips, _, err := resolver.Resolve(context.Background(), "fallback.test")
+
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.2"}, ips)
+}
+
+func TestHostsResolver(t *testing.T) {
+	suite.Run(t, &HostsResolverTestSuite{})
+}
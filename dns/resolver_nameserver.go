@@ -0,0 +1,238 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// DefaultQueryTimeout defines a timeout of a single query to
+	// nameserver if user provides no value.
+	DefaultQueryTimeout = 5 * time.Second
+
+	nameserverMaxMessageSize = 65535
+)
+
+// NameserverProtocol defines how resolver talks to nameservers.
+type NameserverProtocol uint8
+
+const (
+	// NameserverProtocolUDP sends queries over UDP. Truncated responses
+	// are repeated over TCP.
+	NameserverProtocolUDP NameserverProtocol = iota
+
+	// NameserverProtocolTCP sends queries over TCP.
+	NameserverProtocolTCP
+
+	// NameserverProtocolTLS sends queries over TLS (DNS-over-TLS, RFC
+	// 7858).
+	NameserverProtocolTLS
+)
+
+// String conforms fmt.Stringer interface.
+func (n NameserverProtocol) String() string {
+	switch n {
+	case NameserverProtocolUDP:
+		return "udp"
+	case NameserverProtocolTCP:
+		return "tcp"
+	case NameserverProtocolTLS:
+		return "tls"
+	}
+
+	return fmt.Sprintf("NameserverProtocol(%d)", uint8(n))
+}
+
+// NameserverOpts defines a set of options for NewNameserverResolver.
+type NameserverOpts struct {
+	// Nameservers is a list of nameserver addresses (host:port). They
+	// are tried one by one until some of them responds.
+	Nameservers []string
+
+	// Protocol defines how to talk to nameservers. Default is UDP.
+	Protocol NameserverProtocol
+
+	// Timeout defines a timeout of a single query to a nameserver.
+	Timeout time.Duration
+
+	// TLSConfig is used for NameserverProtocolTLS. If ServerName is
+	// empty, a host of the nameserver is used.
+	TLSConfig *tls.Config
+}
+
+// GetTimeout returns a query timeout or fallbacks to default one.
+func (n *NameserverOpts) GetTimeout() time.Duration {
+	if n.Timeout == 0 {
+		return DefaultQueryTimeout
+	}
+
+	return n.Timeout
+}
+
+// GetTLSConfig returns TLS config for given nameserver address.
+func (n *NameserverOpts) GetTLSConfig(address string) *tls.Config {
+	conf := &tls.Config{} // nolint: gosec
+
+	if n.TLSConfig != nil {
+		conf = n.TLSConfig.Clone()
+	}
+
+	if conf.ServerName == "" {
+		conf.ServerName, _, _ = net.SplitHostPort(address)
+	}
+
+	return conf
+}
+
+type nameserverExchanger struct {
+	opts      NameserverOpts
+	netDialer net.Dialer
+}
+
+func (n *nameserverExchanger) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	var err error
+
+	for _, address := range n.opts.Nameservers {
+		var response []byte
+
+		if response, err = n.exchangeWith(ctx, address, query); err == nil {
+			return response, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		default:
+		}
+	}
+
+	return nil, err
+}
+
+func (n *nameserverExchanger) exchangeWith(ctx context.Context, address string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, n.opts.GetTimeout())
+	defer cancel()
+
+	switch n.opts.Protocol {
+	case NameserverProtocolTCP:
+		return n.exchangeStream(ctx, address, query, false)
+	case NameserverProtocolTLS:
+		return n.exchangeStream(ctx, address, query, true)
+	}
+
+	response, err := n.exchangeUDP(ctx, address, query)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := dnsmessage.Parser{}
+
+	if header, err := parser.Start(response); err == nil && header.Truncated {
+		return n.exchangeStream(ctx, address, query, false)
+	}
+
+	return response, nil
+}
+
+func (n *nameserverExchanger) exchangeUDP(ctx context.Context, address string, query []byte) ([]byte, error) {
+	conn, err := n.netDialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial to %s: %w", address, err)
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) // nolint: errcheck
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("cannot send a query to %s: %w", address, err)
+	}
+
+	buf := make([]byte, nameserverMaxMessageSize)
+
+	for {
+		size, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read a response from %s: %w", address, err)
+		}
+
+		// someone can spoof responses so we skip those which have
+		// unexpected ids or questions.
+		if messageIsResponseTo(query, buf[:size]) {
+			return buf[:size], nil
+		}
+	}
+}
+
+func (n *nameserverExchanger) exchangeStream(ctx context.Context,
+	address string,
+	query []byte,
+	useTLS bool) ([]byte, error) {
+	conn, err := n.netDialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial to %s: %w", address, err)
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) // nolint: errcheck
+	}
+
+	if useTLS {
+		tlsConn := tls.Client(conn, n.opts.GetTLSConfig(address))
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, fmt.Errorf("cannot perform TLS handshake with %s: %w", address, err)
+		}
+
+		conn = tlsConn
+	}
+
+	request := make([]byte, 2+len(query)) // nolint: gomnd
+
+	binary.BigEndian.PutUint16(request, uint16(len(query)))
+	copy(request[2:], query)
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("cannot send a query to %s: %w", address, err)
+	}
+
+	if _, err := io.ReadFull(conn, request[:2]); err != nil {
+		return nil, fmt.Errorf("cannot read a response length from %s: %w", address, err)
+	}
+
+	response := make([]byte, binary.BigEndian.Uint16(request))
+
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, fmt.Errorf("cannot read a response from %s: %w", address, err)
+	}
+
+	return response, nil
+}
+
+// NewNameserverResolver returns a backend which sends queries to given
+// nameservers directly with UDP, TCP or DNS-over-TLS.
+func NewNameserverResolver(opts NameserverOpts) (Resolver, error) {
+	if len(opts.Nameservers) == 0 {
+		return nil, ErrNoNameservers
+	}
+
+	if opts.Protocol > NameserverProtocolTLS {
+		return nil, fmt.Errorf("unknown nameserver protocol %v", opts.Protocol)
+	}
+
+	return messageResolver{
+		exchanger: &nameserverExchanger{
+			opts: opts,
+		},
+	}, nil
+}
@@ -0,0 +1,334 @@
+package dns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dns"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsStub is a tiny authoritative nameserver for tests.
+type dnsStub struct {
+	records  map[string][]string
+	truncate int32
+	spoof    int32
+}
+
+// spoofed makes a response with the same id but for another question.
+func (d *dnsStub) spoofed(query []byte) []byte {
+	parser := dnsmessage.Parser{}
+
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+
+	header.Response = true
+	question.Name = dnsmessage.MustNewName("spoofed.test.")
+	builder := dnsmessage.NewBuilder(nil, header)
+
+	builder.StartQuestions()
+	builder.Question(question)
+	builder.StartAnswers()
+
+	if question.Type == dnsmessage.TypeA {
+		builder.AResource(dnsmessage.ResourceHeader{
+			Name:  question.Name,
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		}, dnsmessage.AResource{A: [4]byte{10, 6, 6, 6}})
+	}
+
+	response, _ := builder.Finish()
+
+	return response
+}
+
+func (d *dnsStub) handle(query []byte, udp bool) []byte {
+	parser := dnsmessage.Parser{}
+
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+
+	header.Response = true
+	header.RecursionAvailable = true
+
+	name := strings.TrimSuffix(question.Name.String(), ".")
+	ips, ok := d.records[name]
+
+	switch {
+	case !ok:
+		header.RCode = dnsmessage.RCodeNameError
+	case udp && atomic.LoadInt32(&d.truncate) != 0:
+		header.Truncated = true
+		ips = nil
+	}
+
+	builder := dnsmessage.NewBuilder(nil, header)
+
+	builder.StartQuestions()
+	builder.Question(question)
+	builder.StartAnswers()
+
+	for _, v := range ips {
+		ip := net.ParseIP(v)
+		resource := dnsmessage.ResourceHeader{
+			Name:  question.Name,
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		}
+
+		switch {
+		case ip.To4() != nil && question.Type == dnsmessage.TypeA:
+			a := dnsmessage.AResource{}
+			copy(a.A[:], ip.To4())
+			builder.AResource(resource, a)
+		case ip.To4() == nil && question.Type == dnsmessage.TypeAAAA:
+			aaaa := dnsmessage.AAAAResource{}
+			copy(aaaa.AAAA[:], ip)
+			builder.AAAAResource(resource, aaaa)
+		}
+	}
+
+	response, _ := builder.Finish()
+
+	return response
+}
+
+func (d *dnsStub) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 512)
+
+	for {
+		size, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		if atomic.LoadInt32(&d.spoof) != 0 {
+			conn.WriteTo(d.spoofed(buf[:size]), addr)
+		}
+
+		if response := d.handle(buf[:size], true); response != nil {
+			conn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (d *dnsStub) serveStream(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			length := make([]byte, 2)
+
+			for {
+				if _, err := io.ReadFull(conn, length); err != nil {
+					return
+				}
+
+				query := make([]byte, binary.BigEndian.Uint16(length))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+
+				response := d.handle(query, false)
+
+				binary.BigEndian.PutUint16(length, uint16(len(response)))
+				conn.Write(append(length, response...))
+			}
+		}()
+	}
+}
+
+type NameserverResolverTestSuite struct {
+	suite.Suite
+
+	stub      *dnsStub
+	udpConn   net.PacketConn
+	tcpLn     net.Listener
+	tlsLn     net.Listener
+	tlsServer *httptest.Server
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	deadUDP   string
+}
+
+func (suite *NameserverResolverTestSuite) SetupSuite() {
+	suite.stub = &dnsStub{
+		records: map[string][]string{
+			"example.test": {"10.0.0.1", "10.0.0.2", "fd00::1"},
+		},
+	}
+
+	suite.udpConn, _ = net.ListenPacket("udp", "127.0.0.1:0")
+	suite.tcpLn, _ = net.Listen("tcp", suite.udpConn.LocalAddr().String())
+
+	if suite.tcpLn == nil {
+		suite.tcpLn, _ = net.Listen("tcp", "127.0.0.1:0")
+	}
+
+	// httptest generates a self-signed certificate for us.
+	suite.tlsServer = httptest.NewUnstartedServer(nil)
+	suite.tlsServer.StartTLS()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	suite.tlsLn = tls.NewListener(ln, suite.tlsServer.TLS)
+
+	go suite.stub.serveUDP(suite.udpConn)
+	go suite.stub.serveStream(suite.tcpLn)
+	go suite.stub.serveStream(suite.tlsLn)
+
+	deadConn, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	suite.deadUDP = deadConn.LocalAddr().String()
+
+	deadConn.Close()
+}
+
+func (suite *NameserverResolverTestSuite) TearDownSuite() {
+	suite.udpConn.Close()
+	suite.tcpLn.Close()
+	suite.tlsLn.Close()
+	suite.tlsServer.Close()
+}
+
+func (suite *NameserverResolverTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithTimeout(context.Background(), 5*time.Second)
+	atomic.StoreInt32(&suite.stub.truncate, 0)
+	atomic.StoreInt32(&suite.stub.spoof, 0)
+}
+
+func (suite *NameserverResolverTestSuite) TearDownTest() {
+	suite.ctxCancel()
+}
+
+func (suite *NameserverResolverTestSuite) resolve(opts dns.NameserverOpts, hostname string) ([]string, time.Duration, error) {
+	resolver, err := dns.NewNameserverResolver(opts)
+
+	suite.Require().NoError(err)
+
+	return resolver.Resolve(suite.ctx, hostname)
+}
+
+func (suite *NameserverResolverTestSuite) TestNoNameservers() {
+	_, err := dns.NewNameserverResolver(dns.NameserverOpts{})
+
+	suite.Error(err)
+}
+
+func (suite *NameserverResolverTestSuite) TestUDP() {
+	ips, ttl, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.udpConn.LocalAddr().String()},
+	}, "example.test")
+
+	suite.NoError(err)
+	suite.ElementsMatch([]string{"10.0.0.1", "10.0.0.2", "fd00::1"}, ips)
+	suite.Equal(time.Minute, ttl)
+}
+
+func (suite *NameserverResolverTestSuite) TestSpoofedQuestion() {
+	atomic.StoreInt32(&suite.stub.spoof, 1)
+
+	ips, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.udpConn.LocalAddr().String()},
+	}, "example.test")
+
+	suite.NoError(err)
+	suite.ElementsMatch([]string{"10.0.0.1", "10.0.0.2", "fd00::1"}, ips)
+}
+
+func (suite *NameserverResolverTestSuite) TestTruncated() {
+	if suite.tcpLn.Addr().String() != suite.udpConn.LocalAddr().String() {
+		suite.T().Skip("cannot listen tcp and udp on the same port")
+	}
+
+	atomic.StoreInt32(&suite.stub.truncate, 1)
+
+	ips, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.udpConn.LocalAddr().String()},
+	}, "example.test")
+
+	suite.NoError(err)
+	suite.Len(ips, 3)
+}
+
+func (suite *NameserverResolverTestSuite) TestTCP() {
+	ips, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.tcpLn.Addr().String()},
+		Protocol:    dns.NameserverProtocolTCP,
+	}, "example.test")
+
+	suite.NoError(err)
+	suite.Len(ips, 3)
+}
+
+func (suite *NameserverResolverTestSuite) TestTLS() {
+	ips, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.tlsLn.Addr().String()},
+		Protocol:    dns.NameserverProtocolTLS,
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}, "example.test")
+
+	suite.NoError(err)
+	suite.Len(ips, 3)
+}
+
+func (suite *NameserverResolverTestSuite) TestTLSNotVerified() {
+	_, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.tlsLn.Addr().String()},
+		Protocol:    dns.NameserverProtocolTLS,
+	}, "example.test")
+
+	suite.Error(err)
+}
+
+func (suite *NameserverResolverTestSuite) TestNoSuchHost() {
+	_, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.udpConn.LocalAddr().String()},
+	}, "unknown.test")
+
+	suite.True(errors.Is(err, dns.ErrNoSuchHost))
+}
+
+func (suite *NameserverResolverTestSuite) TestFailover() {
+	ips, _, err := suite.resolve(dns.NameserverOpts{
+		Nameservers: []string{suite.deadUDP, suite.udpConn.LocalAddr().String()},
+		Timeout:     100 * time.Millisecond,
+	}, "example.test")
+
+	suite.NoError(err)
+	suite.Len(ips, 3)
+}
+
+func TestNameserverResolver(t *testing.T) {
+	suite.Run(t, &NameserverResolverTestSuite{})
+}
@@ -0,0 +1,34 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/9seconds/httransform/v2/errors"
+)
+
+type systemResolver struct {
+	resolver net.Resolver
+}
+
+func (s *systemResolver) Resolve(ctx context.Context, hostname string) ([]string, time.Duration, error) {
+	hosts, err := s.resolver.LookupHost(ctx, hostname)
+	if err != nil {
+		var dnsErr *net.DNSError
+
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, 0, ErrNoSuchHost
+		}
+
+		return nil, 0, err // nolint: wrapcheck
+	}
+
+	return hosts, UnknownTTL, nil
+}
+
+// NewSystemResolver returns a backend which uses a resolver of the
+// operating system. It knows nothing about TTLs of the records.
+func NewSystemResolver() Resolver {
+	return &systemResolver{}
+}
@@ -68,4 +68,42 @@
 //
 // 5. TCP connection upgrade is supported. Websockets are supported. But
 // by default you can't interfere: you can just watch.
+//
+// 6. HTTP/2 is negotiated with ALPN for TLS connections of clients. Each
+// h2 stream goes through the same layers and executor as HTTP/1.1
+// request.
+//
+// 7. TLS interception is selective: InterceptPolicy can pass
+// connections to netlocs as is, without generating certificates.
+//
+// 8. Transparent mode: connections redirected by firewall (iptables
+// REDIRECT or TPROXY) are served with Server.ServeTransparent. Both
+// TLS and plain HTTP are supported.
+//
+// 9. Reverse proxy mode: requests are routed to upstreams by Host (or
+// SNI) and path prefixes, TLS is terminated with own certificates.
+//
+// 10. SOCKS5 and SOCKS4a frontend: Server.ServeSOCKS accepts SOCKS
+// clients and processes their tunnels as CONNECT ones. Tunnels which
+// carry neither TLS nor HTTP are passed to netlocs as is.
+//
+// 11. Per-request upstreams: layers can choose a dialer for each
+// request with layers.Context.Dialer. layers.UserDialerLayer does that
+// based on a user.
+//
+// 12. HAR recording: layers.HARLayer records requests and responses
+// with their bodies and har package writes them into rotating HAR
+// files.
+//
+// 13. Offline replay: executor.MakeReplayExecutor responds with
+// recorded HAR entries instead of dialing netlocs.
+//
+// 14. Metrics: metrics package turns events into Prometheus metrics and
+// serves them over HTTP.
+//
+// 15. Tracing: ServerOpts.Tracer traces each request, its layers and
+// dial/TLS/execute phases. W3C traceparent is propagated to netlocs.
+//
+// 16. Access log: accesslog package joins events of each request into
+// a single line of JSON, Common or Combined Log Format.
 package httransform
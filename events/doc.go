@@ -22,4 +22,10 @@
 //
 // If you do that, I recommend you to keep these constants in a single
 // module so you can easily control a uniqueness of values.
+//
+// Events are routed to processors through small buffers. If
+// processors are slower than producers, Send blocks by default so slow
+// processor slows down request processing. If you prefer to lose some
+// events instead, please choose another OverflowPolicy in StreamOpts.
+// A number of lost events is reported by BufferedStream.Dropped.
 package events
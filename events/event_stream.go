@@ -3,6 +3,8 @@ package events
 import (
 	"context"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/OneOfOne/xxhash"
@@ -10,11 +12,23 @@ import (
 )
 
 type eventStream struct {
-	ctx    context.Context
-	shards []chan Event
+	ctx            context.Context
+	shards         []chan Event
+	overflowPolicy OverflowPolicy
+	sampleRate     uint64
+	overflows      uint64
+	dropped        uint64
+	done           chan struct{}
 }
 
 func (e *eventStream) Send(ctx context.Context, eventType EventType, value interface{}, shardKey string) {
+	// processors may have already drained their buffers.
+	if e.ctx.Err() != nil {
+		e.drop()
+
+		return
+	}
+
 	var shard int
 
 	if shardKey == "" {
@@ -28,36 +42,113 @@ func (e *eventStream) Send(ctx context.Context, eventType EventType, value inter
 		Time:  time.Now(),
 		Value: value,
 	}
+	channel := e.shards[shard]
 
+	select {
+	case channel <- evt:
+		return
+	default:
+	}
+
+	switch e.overflowPolicy {
+	case OverflowPolicyDropNewest:
+		e.drop()
+	case OverflowPolicyDropOldest:
+		e.sendDropOldest(channel, evt)
+	case OverflowPolicySample:
+		if atomic.AddUint64(&e.overflows, 1)%e.sampleRate != 0 {
+			e.drop()
+		} else {
+			e.sendBlocking(ctx, channel, evt)
+		}
+	default:
+		e.sendBlocking(ctx, channel, evt)
+	}
+}
+
+func (e *eventStream) sendBlocking(ctx context.Context, channel chan<- Event, evt Event) {
 	select {
 	case <-ctx.Done():
+		e.drop()
 	case <-e.ctx.Done():
-	case e.shards[shard] <- evt:
+		e.drop()
+	case channel <- evt:
 	}
 }
 
+func (e *eventStream) sendDropOldest(channel chan Event, evt Event) {
+	for {
+		select {
+		case channel <- evt:
+			return
+		default:
+		}
+
+		// processor could take an event concurrently so nothing is
+		// dropped.
+		select {
+		case <-channel:
+			e.drop()
+		default:
+		}
+	}
+}
+
+func (e *eventStream) drop() {
+	atomic.AddUint64(&e.dropped, 1)
+}
+
+func (e *eventStream) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+func (e *eventStream) Done() <-chan struct{} {
+	return e.done
+}
+
 // NewStream creates, initialized and returns a new ready Stream
 // instance. It spawns a set of worker goroutines under the hood. Each
 // goroutine corresponds to a its own processor instance (that's why you
 // pass factory here). Processor is initialized within a goroutine.
 func NewStream(ctx context.Context, factory ProcessorFactory) Stream {
+	return NewStreamWithOpts(ctx, factory, StreamOpts{})
+}
+
+// NewStreamWithOpts is the same as NewStream but buffers events and
+// deals with overflows according to given options.
+//
+// Once ctx is cancelled, processors process events which are left in
+// their buffers and are shut down. Events which are sent after that
+// are dropped.
+func NewStreamWithOpts(ctx context.Context, factory ProcessorFactory, opts StreamOpts) BufferedStream {
 	rv := &eventStream{
-		ctx:    ctx,
-		shards: make([]chan Event, runtime.NumCPU()),
+		ctx:            ctx,
+		shards:         make([]chan Event, runtime.NumCPU()),
+		overflowPolicy: opts.GetOverflowPolicy(),
+		sampleRate:     opts.GetSampleRate(),
+		done:           make(chan struct{}),
 	}
 
 	for i := range rv.shards {
-		rv.shards[i] = make(chan Event, 1)
+		rv.shards[i] = make(chan Event, opts.GetBufferSize())
 	}
 
+	wg := &sync.WaitGroup{}
+
+	wg.Add(len(rv.shards))
+
 	for _, v := range rv.shards {
 		go func(channel <-chan Event) {
+			defer wg.Done()
+
 			processor := factory()
 			defer processor.Shutdown()
 
 			for {
 				select {
 				case <-ctx.Done():
+					flushEvents(processor, channel)
+
 					return
 				case evt := <-channel:
 					processor.Process(evt)
@@ -66,5 +157,21 @@ func NewStream(ctx context.Context, factory ProcessorFactory) Stream {
 		}(v)
 	}
 
+	go func() {
+		wg.Wait()
+		close(rv.done)
+	}()
+
 	return rv
 }
+
+func flushEvents(processor Processor, channel <-chan Event) {
+	for {
+		select {
+		case evt := <-channel:
+			processor.Process(evt)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package events_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/stretchr/testify/suite"
+)
+
+type blockingProcessor struct {
+	started chan struct{}
+	gate    chan struct{}
+	mutex   sync.Mutex
+	values  []int
+	closed  int
+}
+
+func (b *blockingProcessor) Process(evt events.Event) {
+	value := evt.Value.(int)
+
+	b.mutex.Lock()
+	b.values = append(b.values, value)
+	b.mutex.Unlock()
+
+	if value == 1 {
+		close(b.started)
+		<-b.gate
+	}
+}
+
+func (b *blockingProcessor) Shutdown() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed++
+}
+
+func (b *blockingProcessor) Values() []int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return append([]int{}, b.values...)
+}
+
+type EventStreamOptsTestSuite struct {
+	suite.Suite
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	processor *blockingProcessor
+}
+
+func (suite *EventStreamOptsTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+	suite.processor = &blockingProcessor{
+		started: make(chan struct{}),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (suite *EventStreamOptsTestSuite) TearDownTest() {
+	suite.ctxCancel()
+}
+
+// makeStream returns a stream where a processor of the shard is
+// stuck on the first event and has a full buffer of 2 events.
+func (suite *EventStreamOptsTestSuite) makeStream(opts events.StreamOpts) events.BufferedStream {
+	opts.BufferSize = 2
+
+	// all processors share the same state but only one of them gets
+	// events.
+	stream := events.NewStreamWithOpts(suite.ctx, func() events.Processor {
+		return suite.processor
+	}, opts)
+
+	stream.Send(suite.ctx, events.EventTypeUserBase, 1, "key")
+	<-suite.processor.started
+
+	stream.Send(suite.ctx, events.EventTypeUserBase, 2, "key")
+	stream.Send(suite.ctx, events.EventTypeUserBase, 3, "key")
+
+	return stream
+}
+
+func (suite *EventStreamOptsTestSuite) waitValues(count int) []int {
+	suite.Eventually(func() bool {
+		return len(suite.processor.Values()) == count
+	}, time.Second, time.Millisecond)
+
+	return suite.processor.Values()
+}
+
+func (suite *EventStreamOptsTestSuite) TestDropNewest() {
+	stream := suite.makeStream(events.StreamOpts{
+		OverflowPolicy: events.OverflowPolicyDropNewest,
+	})
+
+	stream.Send(suite.ctx, events.EventTypeUserBase, 4, "key")
+	stream.Send(suite.ctx, events.EventTypeUserBase, 5, "key")
+	suite.EqualValues(2, stream.Dropped())
+
+	close(suite.processor.gate)
+
+	suite.Equal([]int{1, 2, 3}, suite.waitValues(3))
+}
+
+func (suite *EventStreamOptsTestSuite) TestDropOldest() {
+	stream := suite.makeStream(events.StreamOpts{
+		OverflowPolicy: events.OverflowPolicyDropOldest,
+	})
+
+	stream.Send(suite.ctx, events.EventTypeUserBase, 4, "key")
+	stream.Send(suite.ctx, events.EventTypeUserBase, 5, "key")
+	suite.EqualValues(2, stream.Dropped())
+
+	close(suite.processor.gate)
+
+	suite.Equal([]int{1, 4, 5}, suite.waitValues(3))
+}
+
+func (suite *EventStreamOptsTestSuite) TestSample() {
+	stream := suite.makeStream(events.StreamOpts{
+		OverflowPolicy: events.OverflowPolicySample,
+		SampleRate:     2,
+	})
+
+	stream.Send(suite.ctx, events.EventTypeUserBase, 4, "key")
+	suite.EqualValues(1, stream.Dropped())
+
+	sent := make(chan struct{})
+
+	go func() {
+		defer close(sent)
+
+		stream.Send(suite.ctx, events.EventTypeUserBase, 5, "key")
+	}()
+
+	select {
+	case <-sent:
+		suite.FailNow("sampled event has to block")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(suite.processor.gate)
+	<-sent
+
+	suite.Equal([]int{1, 2, 3, 5}, suite.waitValues(4))
+	suite.EqualValues(1, stream.Dropped())
+}
+
+func (suite *EventStreamOptsTestSuite) TestBlock() {
+	stream := suite.makeStream(events.StreamOpts{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	stream.Send(ctx, events.EventTypeUserBase, 4, "key")
+	suite.EqualValues(1, stream.Dropped())
+
+	close(suite.processor.gate)
+
+	suite.Equal([]int{1, 2, 3}, suite.waitValues(3))
+}
+
+func (suite *EventStreamOptsTestSuite) TestFlushOnShutdown() {
+	stream := suite.makeStream(events.StreamOpts{})
+
+	suite.ctxCancel()
+	close(suite.processor.gate)
+
+	select {
+	case <-stream.Done():
+	case <-time.After(time.Second):
+		suite.FailNow("stream is not done")
+	}
+
+	suite.Equal([]int{1, 2, 3}, suite.processor.Values())
+	suite.NotZero(suite.processor.closed)
+
+	stream.Send(context.Background(), events.EventTypeUserBase, 4, "key")
+	suite.EqualValues(1, stream.Dropped())
+}
+
+func TestEventStreamOpts(t *testing.T) {
+	suite.Run(t, &EventStreamOptsTestSuite{})
+}
@@ -66,13 +66,47 @@ const (
 	EventTypeTraffic
 
 	// EventTypeUserBase defines a constant you should use
-	// to define your own event types.
+	// to define your own event types. User event types have to be
+	// less than 0xF0: the rest is reserved for predefined ones.
 	EventTypeUserBase
 )
 
+// Event types which are added after EventTypeUserBase are allocated
+// from the end of the range so values of user event types are not
+// shifted.
+const eventTypeReservedBase EventType = 0xF0
+
+const (
+	// EventTypeDial is generated when dialer has established a TCP
+	// connection to a netloc. If netloc has several IPs, dialer races
+	// them and this event tells which one has won.
+	//
+	// Corresponding value is DialMeta instance.
+	EventTypeDial EventType = eventTypeReservedBase + iota
+
+	// EventTypeHAREntry is generated by layers.HARLayer when request
+	// and response are completely recorded.
+	//
+	// Corresponding value is *har.Entry instance.
+	EventTypeHAREntry
+
+	// EventTypeReplayMiss is generated by replay executor if it has
+	// no recorded response for the request.
+	//
+	// Corresponding value is RequestMeta instance.
+	EventTypeReplayMiss
+
+	// EventTypeCertificateStoreError is generated if generated TLS
+	// certificate cannot be saved to ca.CertStore. Certificate is still
+	// used but it is going to be generated again after restart.
+	//
+	// Corresponding value is CertificateStoreErrorMeta instance.
+	EventTypeCertificateStoreError
+)
+
 // IsUser returns if this event type is user one or predefined.
 func (e EventType) IsUser() bool {
-	return e >= EventTypeUserBase
+	return e >= EventTypeUserBase && e < eventTypeReservedBase
 }
 
 // String conforms fmt.Stringer interface.
@@ -96,6 +130,14 @@ func (e EventType) String() string { // nolint: cyclop
 		return "FINISH_REQUEST"
 	case EventTypeTraffic:
 		return "TRAFFIC"
+	case EventTypeDial:
+		return "DIAL"
+	case EventTypeHAREntry:
+		return "HAR_ENTRY"
+	case EventTypeReplayMiss:
+		return "REPLAY_MISS"
+	case EventTypeCertificateStoreError:
+		return "CERTIFICATE_STORE_ERROR"
 	case EventTypeUserBase:
 	}
 
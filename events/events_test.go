@@ -21,11 +21,19 @@ func (suite *EventTypeTestSuite) TestIsUser() {
 	suite.False(events.EventTypeFailedRequest.IsUser())
 	suite.False(events.EventTypeFinishRequest.IsUser())
 	suite.False(events.EventTypeTraffic.IsUser())
+	suite.False(events.EventTypeDial.IsUser())
+	suite.False(events.EventTypeHAREntry.IsUser())
+	suite.False(events.EventTypeReplayMiss.IsUser())
+	suite.False(events.EventTypeCertificateStoreError.IsUser())
 
 	suite.True(events.EventTypeUserBase.IsUser())
 	suite.True((events.EventTypeUserBase + 1).IsUser())
 }
 
+func (suite *EventTypeTestSuite) TestUserBaseIsNotShifted() {
+	suite.EqualValues(9, events.EventTypeUserBase)
+}
+
 func (suite *EventTypeTestSuite) TestString() {
 	suite.Equal("NOT_SET", events.EventTypeNotSet.String())
 	suite.Equal("COMMON_ERROR", events.EventTypeCommonError.String())
@@ -36,6 +44,10 @@ func (suite *EventTypeTestSuite) TestString() {
 	suite.Equal("FAILED_REQUEST", events.EventTypeFailedRequest.String())
 	suite.Equal("FINISH_REQUEST", events.EventTypeFinishRequest.String())
 	suite.Equal("TRAFFIC", events.EventTypeTraffic.String())
+	suite.Equal("DIAL", events.EventTypeDial.String())
+	suite.Equal("HAR_ENTRY", events.EventTypeHAREntry.String())
+	suite.Equal("REPLAY_MISS", events.EventTypeReplayMiss.String())
+	suite.Equal("CERTIFICATE_STORE_ERROR", events.EventTypeCertificateStoreError.String())
 
 	suite.Equal("USER(0)", events.EventTypeUserBase.String())
 	suite.Equal("USER(1)", (1 + events.EventTypeUserBase).String())
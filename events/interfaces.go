@@ -28,3 +28,19 @@ type Stream interface {
 	// given interface and sharding key.
 	Send(context.Context, EventType, interface{}, string)
 }
+
+// BufferedStream is a Stream which buffers events of each shard and
+// reports how it deals with overflows.
+type BufferedStream interface {
+	Stream
+
+	// Dropped returns a number of events which were not delivered to
+	// processors: because of overflow policy or because they were
+	// sent after stream context was cancelled.
+	Dropped() uint64
+
+	// Done returns a channel which is closed when stream context is
+	// cancelled, processors have processed buffered events and were
+	// shut down.
+	Done() <-chan struct{}
+}
@@ -3,6 +3,7 @@ package events
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -71,6 +72,15 @@ type RequestMeta struct {
 	// RequestType defines a set of characteristics related to that
 	// request.
 	RequestType RequestType
+
+	// Protocol is a protocol of the request like HTTP/1.1.
+	Protocol string
+
+	// Referer is a value of Referer header of the request.
+	Referer string
+
+	// UserAgent is a value of User-Agent header of the request.
+	UserAgent string
 }
 
 // String conforms fmt.Stringer interface.
@@ -91,11 +101,14 @@ type ResponseMeta struct {
 
 	// StatusCode is HTTP status code of the response.
 	StatusCode int
+
+	// Timings is a breakdown of request processing time.
+	Timings Timings
 }
 
 // String conforms fmt.Stringer interface.
 func (r *ResponseMeta) String() string {
-	return fmt.Sprintf("<%s(status_code=%d)>", r.RequestID, r.StatusCode)
+	return fmt.Sprintf("<%s(status_code=%d, %v)>", r.RequestID, r.StatusCode, &r.Timings)
 }
 
 // ErrorMeta defines a metadata related to some logical error related to
@@ -144,6 +157,26 @@ func (c *CommonErrorMeta) Unwrap() error {
 	return c.Err
 }
 
+// CertificateStoreErrorMeta defines a metadata related to a failure of
+// the certificate store.
+type CertificateStoreErrorMeta struct {
+	// Host is a hostname of the certificate.
+	Host string
+
+	// Err is an underlying error.
+	Err error
+}
+
+// Error conforms error interface.
+func (c *CertificateStoreErrorMeta) Error() string {
+	return c.Host + ": " + c.Err.Error()
+}
+
+// Unwrap conforms go1.13 error interface.
+func (c *CertificateStoreErrorMeta) Unwrap() error {
+	return c.Err
+}
+
 // TrafficMeta defines a metadata related to a TrafficConn completed work.
 type TrafficMeta struct {
 	// ID a unique identifier of the TrafficConn. Usually it is the same
@@ -169,3 +202,33 @@ func (t *TrafficMeta) String() string {
 		t.ReadBytes,
 		t.WrittenBytes)
 }
+
+// DialMeta defines a metadata related to established TCP connection to
+// a netloc.
+type DialMeta struct {
+	// ID is a unique identifier of the request this connection is
+	// established for.
+	ID string
+
+	// Host is a hostname of the netloc as it was passed to dialer.
+	Host string
+
+	// Addr defines a 'netloc' IP address which has won a race.
+	Addr net.Addr
+
+	// Attempts defines how many connection attempts were started.
+	Attempts int
+
+	// Elapsed defines how long it took to establish a connection.
+	Elapsed time.Duration
+}
+
+// String conforms fmt.Stringer interface.
+func (d *DialMeta) String() string {
+	return fmt.Sprintf("<%s(host=%s, addr=%v, attempts=%d, elapsed=%v)>",
+		d.ID,
+		d.Host,
+		d.Addr,
+		d.Attempts,
+		d.Elapsed)
+}
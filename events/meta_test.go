@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/9seconds/httransform/v2/events"
 	"github.com/stretchr/testify/suite"
@@ -53,11 +54,15 @@ func (suite *ResponseMetaTestSuite) TestString() {
 	meta := events.ResponseMeta{
 		RequestID:  "reqid",
 		StatusCode: 404,
+		Timings: events.Timings{
+			TimeToFirstByte: time.Second,
+		},
 	}
 	value := meta.String()
 
 	suite.Contains(value, "reqid")
 	suite.Contains(value, "404")
+	suite.Contains(value, "ttfb=1s")
 }
 
 type ErrorMetaTestSuite struct {
@@ -166,6 +171,27 @@ func (suite *TrafficMetaTestSuite) TestString() {
 	suite.Contains(value, "500")
 }
 
+type DialMetaTestSuite struct {
+	suite.Suite
+}
+
+func (suite *DialMetaTestSuite) TestString() {
+	meta := events.DialMeta{
+		ID:       "reqid",
+		Host:     "example.com",
+		Addr:     &net.TCPAddr{IP: net.IP{127, 0, 0, 1}, Port: 6002},
+		Attempts: 3,
+		Elapsed:  time.Second,
+	}
+	value := meta.String()
+
+	suite.Contains(value, "reqid")
+	suite.Contains(value, "example.com")
+	suite.Contains(value, "127.0.0.1:6002")
+	suite.Contains(value, "attempts=3")
+	suite.Contains(value, "1s")
+}
+
 func TestRequestType(t *testing.T) {
 	suite.Run(t, &RequestTypeTestSuite{})
 }
@@ -189,3 +215,7 @@ func TestCommonErrorMeta(t *testing.T) {
 func TestTrafficMeta(t *testing.T) {
 	suite.Run(t, &TrafficMetaTestSuite{})
 }
+
+func TestDialMeta(t *testing.T) {
+	suite.Run(t, &DialMetaTestSuite{})
+}
@@ -0,0 +1,92 @@
+package events
+
+const (
+	// DefaultStreamBufferSize defines a size of the buffer of each
+	// shard if user provides no value.
+	DefaultStreamBufferSize = 1
+
+	// DefaultStreamSampleRate defines a sample rate of
+	// OverflowPolicySample if user provides no value.
+	DefaultStreamSampleRate = 10
+)
+
+// OverflowPolicy defines what Send does if a buffer of the shard is
+// full because processor is slower than producers.
+type OverflowPolicy byte
+
+const (
+	// OverflowPolicyBlock blocks Send until processor takes an event
+	// or context is cancelled. No events are lost but slow processor
+	// stalls request processing. This is a default policy.
+	OverflowPolicyBlock OverflowPolicy = iota
+
+	// OverflowPolicyDropNewest drops an event which is being sent.
+	OverflowPolicyDropNewest
+
+	// OverflowPolicyDropOldest drops the oldest buffered event of the
+	// shard to make a room for a new one.
+	OverflowPolicyDropOldest
+
+	// OverflowPolicySample blocks Send for one of StreamOpts.SampleRate
+	// overflowing events and drops the rest.
+	OverflowPolicySample
+)
+
+// String conforms fmt.Stringer interface.
+func (o OverflowPolicy) String() string {
+	switch o {
+	case OverflowPolicyBlock:
+		return "block"
+	case OverflowPolicyDropNewest:
+		return "drop_newest"
+	case OverflowPolicyDropOldest:
+		return "drop_oldest"
+	case OverflowPolicySample:
+		return "sample"
+	}
+
+	return "unknown"
+}
+
+// StreamOpts defines a set of options for event stream.
+type StreamOpts struct {
+	// BufferSize defines how many events can be buffered for each
+	// shard.
+	BufferSize uint
+
+	// OverflowPolicy defines what to do if a buffer of the shard is
+	// full.
+	OverflowPolicy OverflowPolicy
+
+	// SampleRate defines that one of SampleRate overflowing events is
+	// delivered if OverflowPolicy is OverflowPolicySample.
+	SampleRate uint
+}
+
+// GetBufferSize returns a size of the shard buffer or fallbacks to
+// default one.
+func (s *StreamOpts) GetBufferSize() int {
+	if s == nil || s.BufferSize == 0 {
+		return DefaultStreamBufferSize
+	}
+
+	return int(s.BufferSize)
+}
+
+// GetOverflowPolicy returns a policy to use on overflow.
+func (s *StreamOpts) GetOverflowPolicy() OverflowPolicy {
+	if s == nil {
+		return OverflowPolicyBlock
+	}
+
+	return s.OverflowPolicy
+}
+
+// GetSampleRate returns a sample rate or fallbacks to default one.
+func (s *StreamOpts) GetSampleRate() uint64 {
+	if s == nil || s.SampleRate == 0 {
+		return DefaultStreamSampleRate
+	}
+
+	return uint64(s.SampleRate)
+}
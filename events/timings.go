@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type timingsKey struct{}
+
+// Timings defines a breakdown of request processing time. Phases
+// which have not happened are zero: for example, requests which are
+// sent over reused connections have no DNS, Dial and TLSHandshake.
+//
+// If some phase has happened several times (like retries of requests
+// over dead pooled connections), durations are summed.
+type Timings struct {
+	// DNS defines how long it took to resolve a netloc hostname.
+	DNS time.Duration
+
+	// Dial defines how long it took to establish a TCP connection
+	// after hostname is resolved.
+	Dial time.Duration
+
+	// TLSHandshake defines how long it took to perform TLS handshake
+	// with a netloc.
+	TLSHandshake time.Duration
+
+	// Send defines how long it took to send a request to a netloc.
+	Send time.Duration
+
+	// TimeToFirstByte defines how long we have waited for a netloc
+	// response after a request is sent.
+	TimeToFirstByte time.Duration
+
+	// Layers defines how long request and response were processed by
+	// layers.
+	Layers time.Duration
+
+	// Executor defines how long executor has worked. It includes DNS,
+	// Dial, TLSHandshake, Send and TimeToFirstByte.
+	Executor time.Duration
+
+	// Total defines a total time of request processing. Please pay
+	// attention that response body is streamed to a client after
+	// that.
+	Total time.Duration
+}
+
+// String conforms fmt.Stringer interface.
+func (t *Timings) String() string {
+	return fmt.Sprintf("dns=%v, dial=%v, tls=%v, send=%v, ttfb=%v, layers=%v, executor=%v, total=%v",
+		t.DNS,
+		t.Dial,
+		t.TLSHandshake,
+		t.Send,
+		t.TimeToFirstByte,
+		t.Layers,
+		t.Executor,
+		t.Total)
+}
+
+// WithTimings returns a context which carries timings of the request.
+// Dialers and executors which get such context fill corresponding
+// phases.
+func WithTimings(ctx context.Context, timings *Timings) context.Context {
+	return context.WithValue(ctx, timingsKey{}, timings)
+}
+
+// GetTimings returns timings from a context. It returns nil if context
+// has no timings.
+func GetTimings(ctx context.Context) *Timings {
+	if value, ok := ctx.Value(timingsKey{}).(*Timings); ok {
+		return value
+	}
+
+	return nil
+}
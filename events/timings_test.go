@@ -0,0 +1,30 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/stretchr/testify/suite"
+)
+
+type TimingsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TimingsTestSuite) TestNoTimings() {
+	suite.Nil(events.GetTimings(context.Background()))
+}
+
+func (suite *TimingsTestSuite) TestWithTimings() {
+	timings := &events.Timings{}
+	ctx, cancel := context.WithCancel(events.WithTimings(context.Background(), timings))
+
+	defer cancel()
+
+	suite.Equal(timings, events.GetTimings(ctx))
+}
+
+func TestTimings(t *testing.T) {
+	suite.Run(t, &TimingsTestSuite{})
+}
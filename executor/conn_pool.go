@@ -0,0 +1,363 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/errors"
+)
+
+const (
+	// DefaultConnPoolIdleTimeout defines a time period after which idle
+	// connection is closed.
+	DefaultConnPoolIdleTimeout = 90 * time.Second
+
+	// DefaultConnPoolMaxIdleConnsPerHost defines a max number of idle
+	// connections which are kept for each netloc.
+	DefaultConnPoolMaxIdleConnsPerHost = 16
+)
+
+// ConnPoolOpts defines a set of options for ConnPool.
+type ConnPoolOpts struct {
+	// IdleTimeout defines a time period after which idle connection is
+	// closed.
+	IdleTimeout time.Duration
+
+	// MaxIdleConnsPerHost defines a max number of idle connections
+	// which are kept for each netloc. If connection is returned to a
+	// full pool, it is closed.
+	MaxIdleConnsPerHost uint
+
+	// MaxConnsPerHost limits a total number of connections to each
+	// netloc (host and port): active and idle ones. This limit is
+	// shared by all dialers and users, and by plain and TLS
+	// connections. If limit is reached, pool closes an idle connection
+	// to this netloc, if any, or executor waits until some connection
+	// is released or closed. If request context is closed before that,
+	// executor fails. 0 means no limit.
+	MaxConnsPerHost uint
+}
+
+// GetIdleTimeout returns idle timeout or fallbacks to default one.
+func (c *ConnPoolOpts) GetIdleTimeout() time.Duration {
+	if c.IdleTimeout == 0 {
+		return DefaultConnPoolIdleTimeout
+	}
+
+	return c.IdleTimeout
+}
+
+// GetMaxIdleConnsPerHost returns a max number of idle connections per
+// netloc or fallbacks to default one.
+func (c *ConnPoolOpts) GetMaxIdleConnsPerHost() int {
+	if c.MaxIdleConnsPerHost == 0 {
+		return DefaultConnPoolMaxIdleConnsPerHost
+	}
+
+	return int(c.MaxIdleConnsPerHost)
+}
+
+// GetMaxConnsPerHost returns a max number of connections per netloc.
+// 0 means no limit.
+func (c *ConnPoolOpts) GetMaxConnsPerHost() int {
+	return int(c.MaxConnsPerHost)
+}
+
+type connPoolKey struct {
+	dialer dialers.Dialer
+	user   string
+	host   string
+	port   string
+	tls    bool
+}
+
+func (c connPoolKey) netloc() string {
+	return net.JoinHostPort(c.host, c.port)
+}
+
+type connPoolItem struct {
+	conn     net.Conn
+	deadline time.Time
+}
+
+// ConnPool is a pool of idle keep-alive connections to netlocs.
+// Connections are keyed by dialer, user, host, port and a sign if
+// connection is upgraded to TLS so it is safe to share a single pool
+// between many executors. Connections are not shared between users
+// because dialers may choose different upstreams for them. A limit of
+// connections is applied to netloc though, see
+// ConnPoolOpts.MaxConnsPerHost.
+//
+// Pool closes idle connections by timeout and checks that connection
+// is still alive before returning it.
+type ConnPool struct {
+	ctx                 context.Context
+	mutex               sync.Mutex
+	idle                map[connPoolKey][]connPoolItem
+	conns               map[string]int
+	released            chan struct{}
+	idleTimeout         time.Duration
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+}
+
+// acquire returns an idle connection if allowReuse is set and pool has
+// any. Otherwise it reserves a slot for a new connection: returned
+// connection is nil and caller has to dial a new one and call release
+// if dial has failed. If there are no free slots, it waits until
+// connection is returned to the pool or closed.
+func (c *ConnPool) acquire(ctx context.Context, key connPoolKey, allowReuse bool) (net.Conn, error) {
+	for {
+		c.mutex.Lock()
+
+		if allowReuse {
+			if conn := c.getIdle(key); conn != nil {
+				c.mutex.Unlock()
+
+				return conn, nil
+			}
+		}
+
+		netloc := key.netloc()
+
+		if c.maxConnsPerHost != 0 && c.conns[netloc] >= c.maxConnsPerHost {
+			c.closeIdleNetlocLocked(netloc)
+		}
+
+		if c.maxConnsPerHost == 0 || c.conns[netloc] < c.maxConnsPerHost {
+			c.conns[netloc]++
+			c.mutex.Unlock()
+
+			return nil, nil
+		}
+
+		released := c.released
+
+		c.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Annotate(ctx.Err(), "cannot wait for a free connection", "", 0)
+		case <-c.ctx.Done():
+			return nil, errors.Annotate(c.ctx.Err(), "connection pool is closed", "", 0)
+		case <-released:
+		}
+	}
+}
+
+// release frees a slot of the closed connection.
+func (c *ConnPool) release(key connPoolKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.releaseLocked(key)
+}
+
+func (c *ConnPool) releaseLocked(key connPoolKey) {
+	netloc := key.netloc()
+
+	if c.conns[netloc] <= 1 {
+		delete(c.conns, netloc)
+	} else {
+		c.conns[netloc]--
+	}
+
+	c.notifyLocked()
+}
+
+// notifyLocked wakes up all callers which are waiting for a free
+// connection.
+func (c *ConnPool) notifyLocked() {
+	close(c.released)
+	c.released = make(chan struct{})
+}
+
+func (c *ConnPool) closeLocked(key connPoolKey, conn net.Conn) {
+	conn.Close()
+	c.releaseLocked(key)
+}
+
+func (c *ConnPool) getIdle(key connPoolKey) net.Conn {
+	items := c.idle[key]
+	now := time.Now()
+
+	for len(items) > 0 {
+		item := items[len(items)-1]
+		items = items[:len(items)-1]
+
+		if item.deadline.After(now) && connPoolIsAlive(item.conn) {
+			c.setItems(key, items)
+
+			return item.conn
+		}
+
+		c.closeLocked(key, item.conn)
+	}
+
+	c.setItems(key, items)
+
+	return nil
+}
+
+// closeIdleNetlocLocked closes the oldest idle connection to a given
+// netloc to free a slot for another key.
+func (c *ConnPool) closeIdleNetlocLocked(netloc string) {
+	for key, items := range c.idle {
+		if key.netloc() == netloc {
+			c.closeLocked(key, items[0].conn)
+			c.setItems(key, items[1:])
+
+			return
+		}
+	}
+}
+
+func (c *ConnPool) put(key connPoolKey, conn net.Conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	items := c.idle[key]
+
+	select {
+	case <-c.ctx.Done():
+		c.closeLocked(key, conn)
+
+		return
+	default:
+	}
+
+	if len(items) >= c.maxIdleConnsPerHost {
+		c.closeLocked(key, conn)
+
+		return
+	}
+
+	c.idle[key] = append(items, connPoolItem{
+		conn:     conn,
+		deadline: time.Now().Add(c.idleTimeout),
+	})
+
+	c.notifyLocked()
+}
+
+func (c *ConnPool) setItems(key connPoolKey, items []connPoolItem) {
+	if len(items) == 0 {
+		delete(c.idle, key)
+	} else {
+		c.idle[key] = items
+	}
+}
+
+func (c *ConnPool) run() {
+	ticker := time.NewTicker(c.idleTimeout / 2) // nolint: gomnd
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.closeIdle(time.Time{})
+
+			return
+		case now := <-ticker.C:
+			c.closeIdle(now)
+		}
+	}
+}
+
+// closeIdle closes connections which are expired to a given moment. If
+// moment is zero, it closes all of them.
+func (c *ConnPool) closeIdle(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, items := range c.idle {
+		alive := items[:0]
+
+		for _, item := range items {
+			if now.IsZero() || !item.deadline.After(now) {
+				c.closeLocked(key, item.conn)
+			} else {
+				alive = append(alive, item)
+			}
+		}
+
+		c.setItems(key, alive)
+	}
+}
+
+// connPoolIsAlive checks that idle connection was not closed by the
+// netloc. Idle keep-alive connection must have nothing to read so we
+// try to read with a deadline in the past: timeout means that
+// connection is alive, anything else means that it is dead or netloc
+// sends something unexpected.
+func connPoolIsAlive(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now()); err != nil {
+		return false
+	}
+
+	var buf [1]byte
+
+	_, err := conn.Read(buf[:])
+
+	var netErr net.Error
+
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		return false
+	}
+
+	return conn.SetReadDeadline(time.Time{}) == nil
+}
+
+// NewConnPool returns a new pool of keep-alive connections. Pool closes
+// all its connections when a given context is closed.
+func NewConnPool(ctx context.Context, opts ConnPoolOpts) *ConnPool {
+	pool := &ConnPool{
+		ctx:                 ctx,
+		idle:                map[connPoolKey][]connPoolItem{},
+		conns:               map[string]int{},
+		released:            make(chan struct{}),
+		idleTimeout:         opts.GetIdleTimeout(),
+		maxIdleConnsPerHost: opts.GetMaxIdleConnsPerHost(),
+		maxConnsPerHost:     opts.GetMaxConnsPerHost(),
+	}
+
+	go pool.run()
+
+	return pool
+}
+
+// pooledConn returns connection back to the pool on closing if it is
+// marked as reusable. Otherwise it closes connection and frees its
+// slot in the pool.
+type pooledConn struct {
+	net.Conn
+
+	pool     *ConnPool
+	key      connPoolKey
+	reusable bool
+	closed   bool
+}
+
+func (p *pooledConn) Close() error {
+	if p.closed {
+		return nil
+	}
+
+	p.closed = true
+
+	if p.reusable {
+		p.reusable = false
+		p.pool.put(p.key, p.Conn)
+
+		return nil
+	}
+
+	err := p.Conn.Close()
+
+	p.pool.release(p.key)
+
+	return err // nolint: wrapcheck
+}
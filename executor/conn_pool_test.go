@@ -0,0 +1,293 @@
+package executor_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/executor"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+)
+
+type ConnPoolTestSuite struct {
+	suite.Suite
+
+	endpoint      *httptest.Server
+	newConns      int32
+	user          string
+	slowStarted   chan struct{}
+	slowUnblock   chan struct{}
+	ctx           context.Context
+	cancel        context.CancelFunc
+	eventsChannel *EventChannelMock
+	exec          executor.Executor
+}
+
+func (suite *ConnPoolTestSuite) SetupTest() {
+	suite.newConns = 0
+	suite.user = "user"
+	suite.slowStarted = make(chan struct{}, 1)
+	suite.slowUnblock = make(chan struct{})
+	suite.endpoint = suite.makeEndpoint()
+	suite.endpoint.Start()
+
+	suite.ctx, suite.cancel = context.WithCancel(context.Background())
+	suite.eventsChannel = &EventChannelMock{}
+	suite.eventsChannel.On("Send",
+		mock.Anything,
+		events.EventTypeTraffic,
+		mock.AnythingOfType("*events.TrafficMeta"),
+		mock.Anything).Maybe()
+	suite.eventsChannel.On("Send",
+		mock.Anything,
+		events.EventTypeDial,
+		mock.AnythingOfType("*events.DialMeta"),
+		mock.Anything).Maybe()
+
+	suite.exec = executor.MakeDefaultExecutorWithPool(dialers.NewBase(dialers.Opts{}),
+		executor.NewConnPool(suite.ctx, executor.ConnPoolOpts{}))
+}
+
+func (suite *ConnPoolTestSuite) TearDownTest() {
+	suite.cancel()
+	suite.endpoint.Close()
+	suite.eventsChannel.AssertExpectations(suite.T())
+}
+
+func (suite *ConnPoolTestSuite) makeEndpoint() *httptest.Server {
+	endpoint := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/close":
+			w.Header().Set("Connection", "close")
+		case "/slow":
+			suite.slowStarted <- struct{}{}
+			<-suite.slowUnblock
+		}
+
+		w.Write([]byte("hello")) // nolint: errcheck
+	}))
+	endpoint.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&suite.newConns, 1)
+		}
+	}
+
+	return endpoint
+}
+
+func (suite *ConnPoolTestSuite) setPool(dialerOpts dialers.Opts, opts executor.ConnPoolOpts) {
+	suite.exec = executor.MakeDefaultExecutorWithPool(dialers.NewBase(dialerOpts),
+		executor.NewConnPool(suite.ctx, opts))
+}
+
+func (suite *ConnPoolTestSuite) execute(path string) {
+	suite.NoError(suite.request(path, 0))
+}
+
+// request executes a request to the endpoint. If timeout is not 0,
+// request is cancelled after it.
+func (suite *ConnPoolTestSuite) request(path string, timeout time.Duration) error {
+	fhttpCtx := &fasthttp.RequestCtx{}
+
+	fhttpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 65342,
+	}, nil)
+	fhttpCtx.Request.SetRequestURI(suite.endpoint.URL + path)
+	fhttpCtx.Request.Header.SetHost("127.0.0.1")
+
+	ctx := layers.AcquireContext()
+	defer layers.ReleaseContext(ctx)
+
+	requestType := events.RequestType(0)
+	if suite.endpoint.TLS != nil {
+		requestType = events.RequestTypeTLS
+	}
+
+	suite.NoError(ctx.Init(fhttpCtx,
+		suite.endpoint.Listener.Addr().String(),
+		suite.eventsChannel,
+		suite.user,
+		requestType))
+
+	if timeout != 0 {
+		timeoutLayer := layers.TimeoutLayer{Timeout: timeout}
+
+		suite.NoError(timeoutLayer.OnRequest(ctx))
+
+		defer timeoutLayer.OnResponse(ctx, nil) // nolint: errcheck
+	}
+
+	if err := suite.exec(ctx); err != nil {
+		return err
+	}
+
+	suite.Equal("hello", string(ctx.Response().Body()))
+
+	return nil
+}
+
+func (suite *ConnPoolTestSuite) TestReuse() {
+	suite.execute("/")
+	suite.execute("/")
+	suite.execute("/")
+
+	suite.EqualValues(1, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestConnectionClose() {
+	suite.execute("/close")
+	suite.execute("/close")
+
+	suite.EqualValues(2, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestDeadConnection() {
+	suite.execute("/")
+	suite.endpoint.CloseClientConnections()
+	suite.execute("/")
+
+	suite.EqualValues(2, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestTLSReuse() {
+	suite.endpoint.Close()
+	atomic.StoreInt32(&suite.newConns, 0)
+
+	suite.endpoint = suite.makeEndpoint()
+	suite.endpoint.StartTLS()
+
+	suite.setPool(dialers.Opts{TLSSkipVerify: true}, executor.ConnPoolOpts{})
+
+	suite.execute("/")
+	suite.execute("/")
+	suite.execute("/")
+
+	suite.EqualValues(1, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestIdleTimeout() {
+	suite.setPool(dialers.Opts{}, executor.ConnPoolOpts{
+		IdleTimeout: 50 * time.Millisecond,
+	})
+
+	suite.execute("/")
+	suite.execute("/")
+
+	suite.EqualValues(1, atomic.LoadInt32(&suite.newConns))
+
+	time.Sleep(150 * time.Millisecond)
+
+	suite.execute("/")
+
+	suite.EqualValues(2, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestMaxConnsPerHostWait() {
+	suite.setPool(dialers.Opts{}, executor.ConnPoolOpts{
+		MaxConnsPerHost: 1,
+	})
+
+	slowDone := make(chan struct{})
+	fastDone := make(chan struct{})
+
+	go func() {
+		defer close(slowDone)
+
+		suite.execute("/slow")
+	}()
+
+	<-suite.slowStarted
+
+	go func() {
+		defer close(fastDone)
+
+		suite.execute("/")
+	}()
+
+	select {
+	case <-fastDone:
+		suite.FailNow("request has not waited for a free connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(suite.slowUnblock)
+	<-slowDone
+	<-fastDone
+
+	suite.EqualValues(1, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestMaxConnsPerHostFail() {
+	suite.setPool(dialers.Opts{}, executor.ConnPoolOpts{
+		MaxConnsPerHost: 1,
+	})
+
+	slowDone := make(chan struct{})
+
+	go func() {
+		defer close(slowDone)
+
+		suite.execute("/slow")
+	}()
+
+	<-suite.slowStarted
+
+	suite.Error(suite.request("/", 100*time.Millisecond))
+
+	close(suite.slowUnblock)
+	<-slowDone
+
+	suite.EqualValues(1, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestMaxConnsPerHostUsers() {
+	suite.setPool(dialers.Opts{}, executor.ConnPoolOpts{
+		MaxConnsPerHost: 1,
+	})
+
+	slowDone := make(chan struct{})
+
+	go func() {
+		defer close(slowDone)
+
+		suite.execute("/slow")
+	}()
+
+	<-suite.slowStarted
+
+	suite.user = "other"
+
+	suite.Error(suite.request("/", 100*time.Millisecond))
+
+	close(suite.slowUnblock)
+	<-slowDone
+
+	suite.EqualValues(1, atomic.LoadInt32(&suite.newConns))
+}
+
+func (suite *ConnPoolTestSuite) TestMaxConnsPerHostIdle() {
+	suite.setPool(dialers.Opts{}, executor.ConnPoolOpts{
+		MaxConnsPerHost: 1,
+	})
+
+	suite.execute("/")
+
+	suite.user = "other"
+
+	suite.NoError(suite.request("/", time.Second))
+	suite.EqualValues(2, atomic.LoadInt32(&suite.newConns))
+}
+
+func TestConnPool(t *testing.T) {
+	suite.Run(t, &ConnPoolTestSuite{})
+}
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/9seconds/httransform/v2/http"
 	"github.com/9seconds/httransform/v2/layers"
 	"github.com/9seconds/httransform/v2/upgrades"
+	"github.com/valyala/fasthttp"
 )
 
 // MakeDefaultExecutor returns a default implementation of executor
@@ -23,10 +25,31 @@ import (
 // it hijacks a connection, returns a correct response and does TCP
 // proxyfying of the sockets.
 //
+// A given dialer is used only if layers have not chosen another one
+// with layers.Context.Dialer.
+//
 // This function is created as a bare minimum to give end user the
 // example on how to implementat his/her own executor.
 func MakeDefaultExecutor(dialer dialers.Dialer) Executor {
+	return MakeDefaultExecutorWithPool(dialer, nil)
+}
+
+// MakeDefaultExecutorWithPool returns the same executor as
+// MakeDefaultExecutor but it reuses keep-alive connections from a given
+// pool. Connection goes back to the pool only when response body is
+// completely read. If pool is nil, connections are not reused at all.
+//
+// Please pay attention that pooled connections report
+// events.EventTypeTraffic for each request separately and count bytes
+// after TLS decryption.
+func MakeDefaultExecutorWithPool(dialer dialers.Dialer, pool *ConnPool) Executor {
 	return func(ctx *layers.Context) error {
+		dialer := requestDialer(ctx, dialer)
+
+		if pool != nil {
+			return defaultExecutorPooled(ctx, dialer, pool)
+		}
+
 		conn, err := defaultExecutorDial(ctx, dialer)
 		if err != nil {
 			return errors.Annotate(err, "cannot dial to the netloc", "", 0)
@@ -34,23 +57,89 @@ func MakeDefaultExecutor(dialer dialers.Dialer) Executor {
 
 		dialer.PatchHTTPRequest(ctx.Request())
 
-		for _, v := range ctx.RequestHeaders.GetLast("Connection").Values() {
-			if strings.EqualFold(v, "Upgrade") {
-				return defaultExecutorConnectionUpgrade(ctx, conn)
-			}
+		if defaultExecutorIsUpgrade(ctx) {
+			return defaultExecutorConnectionUpgrade(ctx, conn)
 		}
 
 		return defaultExecutorHTTPRequest(ctx, conn)
 	}
 }
 
+// requestDialer returns a dialer which has to be used for the request.
+// Layers can choose it with layers.Context.Dialer, otherwise a dialer
+// of the executor is used.
+func requestDialer(ctx *layers.Context, dialer dialers.Dialer) dialers.Dialer {
+	if ctx.Dialer != nil {
+		return ctx.Dialer
+	}
+
+	return dialer
+}
+
+// dialContext returns a context for dialers.Dialer.Dial which carries
+// a user and an event stream of the request.
+func dialContext(ctx *layers.Context) context.Context {
+	return dialers.WithEventStream(dialers.WithUser(ctx, ctx.User), ctx.EventStream, ctx.RequestID)
+}
+
+func defaultExecutorPooled(ctx *layers.Context, dialer dialers.Dialer, pool *ConnPool) error {
+	conn, reused, err := defaultExecutorPooledDial(ctx, dialer, pool, true)
+	if err != nil {
+		return errors.Annotate(err, "cannot dial to the netloc", "", 0)
+	}
+
+	dialer.PatchHTTPRequest(ctx.Request())
+
+	if defaultExecutorIsUpgrade(ctx) {
+		return defaultExecutorConnectionUpgrade(ctx, conn)
+	}
+
+	err = defaultExecutorPooledHTTPRequest(ctx, conn)
+
+	// netloc can close idle connection at any moment so it is possible
+	// that we've got a connection which is already dead. This is safe to
+	// repeat only those requests which can be sent again.
+	if err != nil && reused && defaultExecutorIsRetriable(ctx) {
+		if conn, _, err = defaultExecutorPooledDial(ctx, dialer, pool, false); err != nil {
+			return errors.Annotate(err, "cannot dial to the netloc", "", 0)
+		}
+
+		err = defaultExecutorPooledHTTPRequest(ctx, conn)
+	}
+
+	return err
+}
+
+func defaultExecutorIsUpgrade(ctx *layers.Context) bool {
+	for _, v := range ctx.RequestHeaders.GetLast("Connection").Values() {
+		if strings.EqualFold(v, "Upgrade") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func defaultExecutorIsRetriable(ctx *layers.Context) bool {
+	if ctx.Request().IsBodyStream() {
+		return false
+	}
+
+	switch string(ctx.Request().Header.Method()) {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions, fasthttp.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
 func defaultExecutorDial(ctx *layers.Context, dialer dialers.Dialer) (net.Conn, error) {
 	host, port, err := net.SplitHostPort(ctx.ConnectTo)
 	if err != nil {
 		return nil, errors.Annotate(err, "incorrect address format", "", 0)
 	}
 
-	conn, err := dialer.Dial(ctx, host, port)
+	conn, err := dialer.Dial(dialContext(ctx), host, port)
 	if err != nil {
 		return nil, errors.Annotate(err, "cannot establish tcp connection", "", 0)
 	}
@@ -76,6 +165,64 @@ func defaultExecutorDial(ctx *layers.Context, dialer dialers.Dialer) (net.Conn,
 	return tlsConn, nil
 }
 
+func defaultExecutorPooledDial(ctx *layers.Context,
+	dialer dialers.Dialer,
+	pool *ConnPool,
+	allowReuse bool) (net.Conn, bool, error) {
+	host, port, err := net.SplitHostPort(ctx.ConnectTo)
+	if err != nil {
+		return nil, false, errors.Annotate(err, "incorrect address format", "", 0)
+	}
+
+	key := connPoolKey{
+		dialer: dialer,
+		user:   ctx.User,
+		host:   host,
+		port:   port,
+		tls:    !bytes.EqualFold(ctx.Request().URI().Scheme(), []byte("http")),
+	}
+
+	conn, err := pool.acquire(ctx, key, allowReuse)
+	if err != nil {
+		return nil, false, errors.Annotate(err, "cannot get a connection from the pool", "", 0)
+	}
+
+	reused := conn != nil
+
+	if !reused {
+		if conn, err = dialer.Dial(dialContext(ctx), host, port); err != nil {
+			pool.release(key)
+
+			return nil, false, errors.Annotate(err, "cannot establish tcp connection", "", 0)
+		}
+
+		if key.tls {
+			tlsConn, err := dialer.UpgradeToTLS(ctx, conn, host, port)
+			if err != nil {
+				conn.Close()
+				pool.release(key)
+
+				return nil, false, errors.Annotate(err, "cannot upgrade connection to tls", "", 0)
+			}
+
+			conn = tlsConn
+		}
+	}
+
+	return &conns.TrafficConn{
+		Conn: &pooledConn{
+			Conn: conn,
+			pool: pool,
+			key:  key,
+		},
+		// response body is streamed after request context is released
+		// so traffic has to be reported within a pool lifetime.
+		Context:     pool.ctx,
+		ID:          ctx.RequestID,
+		EventStream: ctx.EventStream,
+	}, reused, nil
+}
+
 func defaultExecutorConnectionUpgrade(ctx *layers.Context, conn net.Conn) error {
 	if err := defaultExecutorHTTPRequest(ctx, conn); err != nil {
 		return errors.Annotate(err, "cannot upgrade http connection", "", 0)
@@ -100,3 +247,19 @@ func defaultExecutorHTTPRequest(ctx *layers.Context, conn io.ReadWriteCloser) er
 
 	return nil
 }
+
+func defaultExecutorPooledHTTPRequest(ctx *layers.Context, conn net.Conn) error {
+	trafficConn, _ := conn.(*conns.TrafficConn)
+	release := func(reusable bool) {
+		trafficConn.Conn.(*pooledConn).reusable = reusable
+		trafficConn.Close()
+	}
+
+	if err := http.ExecuteWithRelease(ctx, conn, ctx.Request(), ctx.Response(), release); err != nil {
+		conn.Close()
+
+		return errors.Annotate(err, "cannot send http request", "", 0)
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	"github.com/9seconds/httransform/v2/dialers"
@@ -27,6 +28,18 @@ func (e *EventChannelMock) Send(ctx context.Context, eventType events.EventType,
 	e.Called(ctx, eventType, value, shardKey)
 }
 
+type countingDialer struct {
+	dialers.Dialer
+
+	calls int32
+}
+
+func (c *countingDialer) Dial(ctx context.Context, host, port string) (net.Conn, error) {
+	atomic.AddInt32(&c.calls, 1)
+
+	return c.Dialer.Dial(ctx, host, port)
+}
+
 type upgrader struct {
 	websocket.Upgrader
 }
@@ -139,6 +152,23 @@ func (suite *MakeDefaultExecutorTestSuite) TestUpgrade() {
 	suite.NoError(suite.exec(suite.ctx))
 }
 
+func (suite *MakeDefaultExecutorTestSuite) TestContextDialer() {
+	suite.eventsChannel.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	dialer := &countingDialer{
+		Dialer: dialers.NewBase(dialers.Opts{}),
+	}
+	req := suite.ctx.Request()
+
+	req.SetRequestURI(suite.endpoint.URL + "/ip")
+	req.Header.SetHost("127.0.0.1")
+
+	suite.ctx.Dialer = dialer
+
+	suite.NoError(suite.exec(suite.ctx))
+	suite.EqualValues(1, atomic.LoadInt32(&dialer.calls))
+}
+
 func TestMakeDefaultExecutor(t *testing.T) {
 	suite.Run(t, &MakeDefaultExecutorTestSuite{})
 }
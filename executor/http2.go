@@ -0,0 +1,525 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/9seconds/httransform/v2/cache"
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/headers"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/9seconds/httransform/v2/tracing"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// HTTP2IdleTimeout defines a time period after which idle HTTP/2
+	// connection to the netloc is closed.
+	HTTP2IdleTimeout = 90 * time.Second
+
+	// HTTP2NoSupportCacheSize defines a size of the cache which stores
+	// netlocs which do not support HTTP/2.
+	HTTP2NoSupportCacheSize = 1024
+
+	// HTTP2NoSupportCacheTTL defines for how long we remember that
+	// netloc does not support HTTP/2.
+	HTTP2NoSupportCacheTTL = 10 * time.Minute
+)
+
+var http2NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+
+type http2Dial struct {
+	done      chan struct{}
+	conn      *http2.ClientConn
+	http1Conn net.Conn
+	err       error
+}
+
+// http2ConnPoolKey identifies shared connections. Connections are not
+// shared between dialers and users because they may choose different
+// upstreams.
+type http2ConnPoolKey struct {
+	dialer  dialers.Dialer
+	user    string
+	address string
+}
+
+type http2ConnPool struct {
+	transport *http2.Transport
+	noSupport cache.Interface
+	mutex     sync.Mutex
+	conns     map[http2ConnPoolKey]http2PoolEntry
+	dials     map[http2ConnPoolKey]*http2Dial
+}
+
+// Get returns a client connection to a netloc. If netloc does not
+// support HTTP/2, it returns established HTTP/1.1 connection instead.
+// This connection is not shared and has to be closed by a caller.
+//
+// A connection is shared by many requests so it is dialed in a
+// separate goroutine which does not depend on a context of the request
+// which has initiated it. Dialer timeouts still apply.
+func (h *http2ConnPool) Get(ctx *layers.Context, dialer dialers.Dialer) (*http2.ClientConn, net.Conn, error) {
+	key := http2ConnPoolKey{
+		dialer:  dialer,
+		user:    ctx.User,
+		address: ctx.ConnectTo,
+	}
+
+	h.mutex.Lock()
+
+	if entry, ok := h.conns[key]; ok {
+		if entry.clientConn.CanTakeNewRequest() {
+			h.mutex.Unlock()
+
+			return entry.clientConn, nil, nil
+		}
+
+		delete(h.conns, key)
+	}
+
+	dial, ok := h.dials[key]
+	owner := !ok
+
+	if owner {
+		dial = &http2Dial{
+			done: make(chan struct{}),
+		}
+		h.dials[key] = dial
+
+		go h.dial(key, dial)
+	}
+
+	h.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		if owner {
+			// nobody else is going to take HTTP/1.1 connection.
+			go func() {
+				<-dial.done
+
+				if dial.http1Conn != nil {
+					dial.http1Conn.Close()
+				}
+			}()
+		}
+
+		return nil, nil, errors.Annotate(ctx.Err(), "cannot wait for http2 connection", "", 0)
+	case <-dial.done:
+	}
+
+	switch {
+	case dial.err != nil:
+		return nil, nil, dial.err
+	case owner:
+		return dial.conn, dial.http1Conn, nil
+	case dial.conn == nil:
+		return h.Get(ctx, dialer)
+	}
+
+	return dial.conn, nil, nil
+}
+
+// evict removes a closed connection from the pool.
+func (h *http2ConnPool) evict(key http2ConnPoolKey, conn *http2PoolConn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.conns[key].conn == conn {
+		delete(h.conns, key)
+	}
+}
+
+func (h *http2ConnPool) dial(key http2ConnPoolKey, dial *http2Dial) {
+	entry, http1Conn, err := h.doDial(key)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	dial.conn = entry.clientConn
+	dial.http1Conn = http1Conn
+	dial.err = err
+
+	if entry.clientConn != nil {
+		h.conns[key] = entry
+	}
+
+	delete(h.dials, key)
+	close(dial.done)
+}
+
+func (h *http2ConnPool) doDial(key http2ConnPoolKey) (http2PoolEntry, net.Conn, error) {
+	host, port, err := net.SplitHostPort(key.address)
+	if err != nil {
+		return http2PoolEntry{}, nil, errors.Annotate(err, "incorrect address format", "", 0)
+	}
+
+	ctx := dialers.WithUser(context.Background(), key.user)
+
+	conn, err := key.dialer.Dial(ctx, host, port)
+	if err != nil {
+		return http2PoolEntry{}, nil, errors.Annotate(err, "cannot establish tcp connection", "", 0)
+	}
+
+	tlsConn, err := key.dialer.UpgradeToTLS(dialers.WithNextProtos(ctx, http2NextProtos), conn, host, port)
+	if err != nil {
+		conn.Close()
+
+		return http2PoolEntry{}, nil, errors.Annotate(err, "cannot upgrade connection to tls", "", 0)
+	}
+
+	state, ok := tlsConn.(http2ConnectionStater)
+	if !ok || state.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		h.noSupport.Add(key.address, true)
+
+		return http2PoolEntry{}, tlsConn, nil
+	}
+
+	poolConn := &http2PoolConn{
+		Conn:   tlsConn,
+		stater: state,
+		pool:   h,
+		key:    key,
+	}
+
+	clientConn, err := h.transport.NewClientConn(poolConn)
+	if err != nil {
+		tlsConn.Close()
+
+		return http2PoolEntry{}, nil, errors.Annotate(err, "cannot initialize http2 connection", "http2", 0)
+	}
+
+	return http2PoolEntry{
+		clientConn: clientConn,
+		conn:       poolConn,
+	}, nil, nil
+}
+
+type http2PoolEntry struct {
+	clientConn *http2.ClientConn
+	conn       *http2PoolConn
+}
+
+type http2ConnectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// http2PoolConn evicts a client connection from the pool when
+// http2.Transport closes it: idle timeout, GOAWAY or a broken
+// connection.
+type http2PoolConn struct {
+	net.Conn
+
+	stater    http2ConnectionStater
+	pool      *http2ConnPool
+	key       http2ConnPoolKey
+	closeOnce sync.Once
+}
+
+// ConnectionState is required by http2.Transport to verify a
+// negotiated TLS connection.
+func (h *http2PoolConn) ConnectionState() tls.ConnectionState {
+	return h.stater.ConnectionState()
+}
+
+func (h *http2PoolConn) Close() error {
+	h.closeOnce.Do(func() {
+		h.pool.evict(h.key, h)
+	})
+
+	return h.Conn.Close() // nolint: wrapcheck
+}
+
+// MakeHTTP2Executor returns an executor which talks HTTP/2 to netlocs
+// which support it. It negotiates h2 with ALPN during
+// dialers.Dialer.UpgradeToTLS and multiplexes all requests to the same
+// netloc over a single connection.
+//
+// If netloc does not support HTTP/2, it falls back to HTTP/1.1 and
+// remembers that for HTTP2NoSupportCacheTTL. Plain HTTP requests and
+// connection upgrades are always executed as MakeDefaultExecutor does.
+//
+// As MakeDefaultExecutor, it respects layers.Context.Dialer. Connections
+// are not shared between different dialers and users.
+//
+// Please pay attention that shared connections do not belong to any
+// request so no events.EventTypeTraffic are sent for them.
+func MakeHTTP2Executor(dialer dialers.Dialer) Executor {
+	// http2.Transport takes idle timeout only from net/http transport
+	// it is bound to.
+	transport, _ := http2.ConfigureTransports(&http.Transport{
+		IdleConnTimeout: HTTP2IdleTimeout,
+	})
+	pool := &http2ConnPool{
+		transport: transport,
+		noSupport: cache.New(HTTP2NoSupportCacheSize,
+			HTTP2NoSupportCacheTTL,
+			cache.NoopEvictCallback),
+		conns: map[http2ConnPoolKey]http2PoolEntry{},
+		dials: map[http2ConnPoolKey]*http2Dial{},
+	}
+	fallback := MakeDefaultExecutor(dialer)
+
+	return func(ctx *layers.Context) error {
+		if bytes.EqualFold(ctx.Request().URI().Scheme(), []byte("http")) ||
+			pool.noSupport.Get(ctx.ConnectTo) != nil {
+			return fallback(ctx)
+		}
+
+		for _, v := range ctx.RequestHeaders.GetLast("Connection").Values() {
+			if strings.EqualFold(v, "Upgrade") {
+				return fallback(ctx)
+			}
+		}
+
+		dialer := requestDialer(ctx, dialer)
+
+		clientConn, http1Conn, err := pool.Get(ctx, dialer)
+		if err != nil {
+			return errors.Annotate(err, "cannot dial to the netloc", "", 0)
+		}
+
+		if http1Conn != nil {
+			dialer.PatchHTTPRequest(ctx.Request())
+
+			return defaultExecutorHTTPRequest(ctx, http1Conn)
+		}
+
+		return http2ExecutorRequest(ctx, clientConn)
+	}
+}
+
+func http2ExecutorRequest(ctx *layers.Context, clientConn *http2.ClientConn) error {
+	// request context has to outlive the executor: response body is
+	// streamed to the client after layers.Context is released.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	roundTripDone := make(chan struct{})
+	ctxDone := ctx.Done()
+
+	go func() {
+		select {
+		case <-ctxDone:
+			cancel()
+		case <-roundTripDone:
+		}
+	}()
+
+	req, err := http2MakeRequest(reqCtx, ctx.Request())
+	if err != nil {
+		close(roundTripDone)
+		cancel()
+
+		return errors.Annotate(err, "cannot build http2 request", "http2", 0)
+	}
+
+	// body is pumped in a separate goroutine which reads fasthttp
+	// request so it is started only when request headers are copied.
+	// netloc may respond before the whole body is sent so it is
+	// closed either by http2.Transport when it has finished with the
+	// body or together with a response body.
+	body := http2RequestBody(ctx.Request())
+
+	if body != nil {
+		req.Body = body
+	} else {
+		req.ContentLength = 0
+	}
+
+	_, span := tracing.StartSpan(ctx, "execute")
+	span.SetAttribute("http.flavor", "2.0")
+
+	startTime := time.Now()
+	resp, err := clientConn.RoundTrip(req)
+
+	// HTTP/2 streams request body concurrently so time to first byte
+	// includes sending of the request.
+	ctx.Timings.TimeToFirstByte += time.Since(startTime)
+
+	close(roundTripDone)
+
+	if err != nil {
+		cancel()
+
+		if body != nil {
+			body.Close()
+		}
+
+		err = errors.Annotate(err, "cannot send http2 request", "http2", 0)
+
+		span.RecordError(err)
+		span.End()
+
+		return err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	span.End()
+
+	http2FillResponse(ctx.Response(), resp, func() {
+		cancel()
+
+		if body != nil {
+			body.Close()
+		}
+	}, ctx.Request().Header.IsHead())
+
+	return nil
+}
+
+func http2MakeRequest(ctx context.Context, request *fasthttp.Request) (*http.Request, error) {
+	parsedURL, err := url.Parse(string(request.URI().FullURI()))
+	if err != nil {
+		return nil, fmt.Errorf("incorrect url: %w", err)
+	}
+
+	req := &http.Request{
+		Method:     string(request.Header.Method()),
+		URL:        parsedURL,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2, // nolint: gomnd
+		Header:     http.Header{},
+		Host:       string(request.Header.Host()),
+	}
+
+	req.ContentLength = int64(request.Header.ContentLength())
+
+	if req.ContentLength < 0 {
+		req.ContentLength = -1
+	}
+
+	request.Header.VisitAll(func(key, value []byte) {
+		name := string(key)
+
+		switch {
+		case headers.IsHopByHop(name),
+			strings.EqualFold(name, "Host"),
+			strings.EqualFold(name, "Content-Length"):
+		default:
+			req.Header[name] = append(req.Header[name], string(value))
+		}
+	})
+
+	return req.WithContext(ctx), nil
+}
+
+func http2RequestBody(request *fasthttp.Request) io.ReadCloser {
+	if !request.IsBodyStream() {
+		if body := request.Body(); len(body) > 0 {
+			return ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		return nil
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	body := &http2RequestBodyStream{
+		PipeReader: pipeReader,
+		done:       make(chan struct{}),
+	}
+
+	go func() {
+		defer close(body.done)
+
+		pipeWriter.CloseWithError(request.BodyWriteTo(pipeWriter)) // nolint: errcheck
+	}()
+
+	return body
+}
+
+// http2RequestBodyStream is a request body which is pumped from
+// fasthttp request. Close waits until pumping is stopped: fasthttp
+// request must not be touched after the response is sent.
+type http2RequestBodyStream struct {
+	*io.PipeReader
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (h *http2RequestBodyStream) Close() error {
+	h.closeOnce.Do(func() {
+		h.PipeReader.Close()
+		<-h.done
+	})
+
+	return nil
+}
+
+func http2FillResponse(response *fasthttp.Response, resp *http.Response, cancel context.CancelFunc, isHead bool) {
+	response.Reset()
+	response.Header.DisableNormalizing()
+	response.SetStatusCode(resp.StatusCode)
+
+	for name, values := range resp.Header {
+		if headers.IsHopByHop(name) || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+
+		for _, value := range values {
+			response.Header.Add(name, value)
+		}
+	}
+
+	body := &http2ResponseBody{
+		body:   resp.Body,
+		cancel: cancel,
+	}
+
+	if isHead || resp.ContentLength == 0 {
+		body.Close()
+		response.SkipBody = true
+
+		if resp.ContentLength >= 0 {
+			response.Header.SetContentLength(int(resp.ContentLength))
+		}
+
+		return
+	}
+
+	response.SetBodyStream(body, int(resp.ContentLength))
+}
+
+type http2ResponseBody struct {
+	body      io.ReadCloser
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	detached  bool
+}
+
+func (h *http2ResponseBody) Read(p []byte) (int, error) {
+	return h.body.Read(p) // nolint: wrapcheck
+}
+
+// Detach makes the next Close call a noop. It is required to replace
+// a body stream of the fasthttp response without closing a stream.
+func (h *http2ResponseBody) Detach() {
+	h.detached = true
+}
+
+func (h *http2ResponseBody) Close() error {
+	if h.detached {
+		h.detached = false
+
+		return nil
+	}
+
+	h.closeOnce.Do(func() {
+		h.body.Close()
+		h.cancel()
+	})
+
+	return nil
+}
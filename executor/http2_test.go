@@ -0,0 +1,238 @@
+package executor_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/executor"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+)
+
+type http2TestResponse struct {
+	Proto      string `json:"proto"`
+	RemoteAddr string `json:"remote_addr"`
+}
+
+func http2TestHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(http2TestResponse{ // nolint: errcheck
+		Proto:      r.Proto,
+		RemoteAddr: r.RemoteAddr,
+	})
+}
+
+type MakeHTTP2ExecutorTestSuite struct {
+	suite.Suite
+
+	h2Endpoint    *httptest.Server
+	h1Endpoint    *httptest.Server
+	eventsChannel *EventChannelMock
+	dialer        dialers.Dialer
+	exec          executor.Executor
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) SetupSuite() {
+	suite.h2Endpoint = httptest.NewUnstartedServer(http.HandlerFunc(http2TestHandler))
+	suite.h2Endpoint.EnableHTTP2 = true
+	suite.h2Endpoint.StartTLS()
+
+	suite.h1Endpoint = httptest.NewTLSServer(http.HandlerFunc(http2TestHandler))
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TearDownSuite() {
+	suite.h2Endpoint.Close()
+	suite.h1Endpoint.Close()
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) SetupTest() {
+	suite.eventsChannel = &EventChannelMock{}
+	suite.eventsChannel.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	suite.dialer = nil
+	suite.exec = executor.MakeHTTP2Executor(dialers.NewBase(dialers.Opts{
+		TLSSkipVerify: true,
+	}))
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TearDownTest() {
+	suite.eventsChannel.AssertExpectations(suite.T())
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) execute(endpoint *httptest.Server) http2TestResponse {
+	fhttpCtx := &fasthttp.RequestCtx{}
+
+	fhttpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 65342,
+	}, nil)
+	fhttpCtx.Request.SetRequestURI(endpoint.URL + "/")
+
+	ctx := layers.AcquireContext()
+	defer layers.ReleaseContext(ctx)
+
+	suite.NoError(ctx.Init(fhttpCtx,
+		endpoint.Listener.Addr().String(),
+		suite.eventsChannel,
+		"user",
+		events.RequestTypeTLS))
+
+	ctx.Dialer = suite.dialer
+
+	suite.NoError(suite.exec(ctx))
+	suite.Equal(fasthttp.StatusOK, ctx.Response().StatusCode())
+
+	resp := http2TestResponse{}
+
+	suite.NoError(json.Unmarshal(ctx.Response().Body(), &resp))
+
+	return resp
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TestHTTP2() {
+	resp := suite.execute(suite.h2Endpoint)
+
+	suite.Equal("HTTP/2.0", resp.Proto)
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TestMultiplexing() {
+	resp1 := suite.execute(suite.h2Endpoint)
+	resp2 := suite.execute(suite.h2Endpoint)
+
+	suite.Equal(resp1.RemoteAddr, resp2.RemoteAddr)
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TestFallbackToHTTP1() {
+	resp := suite.execute(suite.h1Endpoint)
+
+	suite.Equal("HTTP/1.1", resp.Proto)
+
+	resp = suite.execute(suite.h1Endpoint)
+
+	suite.Equal("HTTP/1.1", resp.Proto)
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TestContextDialer() {
+	resp1 := suite.execute(suite.h2Endpoint)
+
+	dialer := &countingDialer{
+		Dialer: dialers.NewBase(dialers.Opts{
+			TLSSkipVerify: true,
+		}),
+	}
+	suite.dialer = dialer
+
+	resp2 := suite.execute(suite.h2Endpoint)
+	resp3 := suite.execute(suite.h2Endpoint)
+
+	suite.NotEqual(resp1.RemoteAddr, resp2.RemoteAddr)
+	suite.Equal(resp2.RemoteAddr, resp3.RemoteAddr)
+	suite.EqualValues(1, atomic.LoadInt32(&dialer.calls))
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TestStreamedRequestBody() {
+	endpoint := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body) // nolint: errcheck
+	}))
+	endpoint.EnableHTTP2 = true
+	endpoint.StartTLS()
+
+	defer endpoint.Close()
+
+	fhttpCtx := &fasthttp.RequestCtx{}
+
+	fhttpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 65342,
+	}, nil)
+	fhttpCtx.Request.SetRequestURI(endpoint.URL + "/")
+	fhttpCtx.Request.Header.SetMethod(fasthttp.MethodPost)
+	// a reader is wrapped to hide io.WriterTo: otherwise fasthttp
+	// copies a body instead of streaming.
+	fhttpCtx.Request.SetBodyStream(struct{ io.Reader }{strings.NewReader("hello")}, len("hello"))
+
+	ctx := layers.AcquireContext()
+	defer layers.ReleaseContext(ctx)
+
+	suite.NoError(ctx.Init(fhttpCtx,
+		endpoint.Listener.Addr().String(),
+		suite.eventsChannel,
+		"user",
+		events.RequestTypeTLS))
+
+	layer := layers.BodyTransformerLayer{
+		Request: func(_ *layers.Context, body io.Reader) (io.Reader, error) {
+			return io.MultiReader(body, strings.NewReader(" world")), nil
+		},
+	}
+
+	suite.NoError(layer.OnRequest(ctx))
+	suite.NoError(ctx.RequestHeaders.Push())
+	suite.NoError(suite.exec(ctx))
+	suite.Equal(fasthttp.StatusOK, ctx.Response().StatusCode())
+	suite.Equal("hello world", string(ctx.Response().Body()))
+}
+
+func (suite *MakeHTTP2ExecutorTestSuite) TestEarlyResponse() {
+	endpoint := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		io.Copy(w, r.Body) // nolint: errcheck
+	}))
+	endpoint.EnableHTTP2 = true
+	endpoint.StartTLS()
+
+	defer endpoint.Close()
+
+	fhttpCtx := &fasthttp.RequestCtx{}
+
+	fhttpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 65342,
+	}, nil)
+	fhttpCtx.Request.SetRequestURI(endpoint.URL + "/")
+	fhttpCtx.Request.Header.SetMethod(fasthttp.MethodPost)
+	fhttpCtx.Request.SetBodyStream(io.MultiReader(
+		strings.NewReader("hello"),
+		&slowReader{Reader: strings.NewReader(" world"), delay: 100 * time.Millisecond},
+	), len("hello world"))
+
+	ctx := layers.AcquireContext()
+	defer layers.ReleaseContext(ctx)
+
+	suite.NoError(ctx.Init(fhttpCtx,
+		endpoint.Listener.Addr().String(),
+		suite.eventsChannel,
+		"user",
+		events.RequestTypeTLS))
+
+	suite.NoError(suite.exec(ctx))
+	suite.Equal(fasthttp.StatusOK, ctx.Response().StatusCode())
+	suite.Equal("hello world", string(ctx.Response().Body()))
+}
+
+type slowReader struct {
+	io.Reader
+
+	delay time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+
+	return s.Reader.Read(p) // nolint: wrapcheck
+}
+
+func TestMakeHTTP2Executor(t *testing.T) {
+	suite.Run(t, &MakeHTTP2ExecutorTestSuite{})
+}
@@ -0,0 +1,296 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/9seconds/httransform/v2/headers"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/valyala/fasthttp"
+)
+
+// ErrReplayMiss is returned by replay executor if it has no recorded
+// response for the request and passthrough is not configured.
+var ErrReplayMiss = &errors.Error{
+	Message:    "no recorded response for the request",
+	Code:       "replay_miss",
+	StatusCode: fasthttp.StatusBadGateway,
+}
+
+// ReplayMatch defines a bitmask of request parts which are used to
+// find a recorded response.
+type ReplayMatch byte
+
+const (
+	// ReplayMatchMethod matches requests by HTTP method.
+	ReplayMatchMethod ReplayMatch = 1 << iota
+
+	// ReplayMatchURL matches requests by full URL including a query.
+	ReplayMatchURL
+
+	// ReplayMatchBody matches requests by SHA256 hash of the body.
+	// Recorded requests with truncated bodies never match.
+	ReplayMatchBody
+)
+
+// ReplayOpts defines a set of options for replay executor.
+type ReplayOpts struct {
+	// Entries is a list of recorded entries. Usually they are read
+	// with har.ReadLog. Entries of failed requests and entries with
+	// truncated response bodies are ignored: such requests are
+	// misses.
+	Entries []*har.Entry
+
+	// Match defines which parts of the request have to match. Default
+	// is ReplayMatchMethod | ReplayMatchURL.
+	Match ReplayMatch
+
+	// MatchHeaders is a list of header names which values have to
+	// match. Names are case insensitive.
+	MatchHeaders []string
+
+	// Passthrough is an executor which is used if there is no recorded
+	// response for the request. If it is nil, executor works in strict
+	// mode and returns ErrReplayMiss.
+	Passthrough Executor
+}
+
+// GetMatch returns a set of matching rules or fallbacks to default
+// one.
+func (r *ReplayOpts) GetMatch() ReplayMatch {
+	if r.Match == 0 {
+		return ReplayMatchMethod | ReplayMatchURL
+	}
+
+	return r.Match
+}
+
+type replayResponses struct {
+	entries []*har.Entry
+	next    int
+}
+
+type replayer struct {
+	match     ReplayMatch
+	headers   []string
+	mutex     sync.Mutex
+	responses map[string]*replayResponses
+}
+
+func (r *replayer) get(key string) *har.Entry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	responses, ok := r.responses[key]
+	if !ok {
+		return nil
+	}
+
+	entry := responses.entries[responses.next]
+
+	if responses.next < len(responses.entries)-1 {
+		responses.next++
+	}
+
+	return entry
+}
+
+func (r *replayer) key(method, url string, getHeader func(string) string, body func() []byte) string {
+	builder := strings.Builder{}
+
+	if r.match&ReplayMatchMethod != 0 {
+		builder.WriteString(strings.ToUpper(method))
+	}
+
+	builder.WriteByte(0)
+
+	if r.match&ReplayMatchURL != 0 {
+		builder.WriteString(url)
+	}
+
+	for _, name := range r.headers {
+		builder.WriteByte(0)
+		builder.WriteString(getHeader(name))
+	}
+
+	builder.WriteByte(0)
+
+	if r.match&ReplayMatchBody != 0 {
+		hash := sha256.Sum256(body())
+		builder.WriteString(hex.EncodeToString(hash[:]))
+	}
+
+	return builder.String()
+}
+
+func (r *replayer) entryKey(entry *har.Entry) (string, bool) {
+	postData := entry.Request.PostData
+	if r.match&ReplayMatchBody != 0 && postData != nil && postData.Truncated {
+		return "", false
+	}
+
+	getHeader := func(name string) string {
+		value := ""
+
+		for _, v := range entry.Request.Headers {
+			if strings.EqualFold(v.Name, name) {
+				value = v.Value
+			}
+		}
+
+		return value
+	}
+
+	body := func() []byte {
+		if postData == nil {
+			return nil
+		}
+
+		return replayDecodeText(postData.Text, postData.Encoding)
+	}
+
+	return r.key(entry.Request.Method, entry.Request.URL, getHeader, body), true
+}
+
+func (r *replayer) requestKey(ctx *layers.Context) string {
+	req := ctx.Request()
+
+	getHeader := func(name string) string {
+		if header := ctx.RequestHeaders.GetLast(name); header != nil {
+			return header.Value()
+		}
+
+		return ""
+	}
+
+	body := func() []byte {
+		if req.IsBodyStream() {
+			// body stream can be read only once so it is replaced with
+			// a buffered body for passthrough executor.
+			buf := bytes.Buffer{}
+
+			req.BodyWriteTo(&buf) // nolint: errcheck
+			req.SetBody(buf.Bytes())
+		}
+
+		return req.Body()
+	}
+
+	return r.key(string(req.Header.Method()), string(req.URI().FullURI()), getHeader, body)
+}
+
+// MakeReplayExecutor returns an executor which does not dial netlocs
+// but responds with recorded responses. It is intended to run tests
+// offline against traffic captured with layers.HARLayer or exported
+// from a browser.
+//
+// If the same request is recorded several times, responses are
+// returned in the order of recording. The last one is repeated when
+// they are exhausted.
+//
+// Each request without recorded response produces
+// events.EventTypeReplayMiss.
+//
+// Content-Encoding header is replayed only if a recorded body is not
+// known to be decoded. A body is decoded if har.Content.Decoded is set
+// or if it has a nonzero Compression as browsers report it.
+func MakeReplayExecutor(opts ReplayOpts) Executor {
+	rpl := &replayer{
+		match:     opts.GetMatch(),
+		headers:   opts.MatchHeaders,
+		responses: map[string]*replayResponses{},
+	}
+
+	for _, entry := range opts.Entries {
+		if entry.Error != "" || entry.Response.Status == 0 || entry.Response.Content.Truncated {
+			continue
+		}
+
+		key, ok := rpl.entryKey(entry)
+		if !ok {
+			continue
+		}
+
+		if responses, ok := rpl.responses[key]; ok {
+			responses.entries = append(responses.entries, entry)
+		} else {
+			rpl.responses[key] = &replayResponses{
+				entries: []*har.Entry{entry},
+			}
+		}
+	}
+
+	return func(ctx *layers.Context) error {
+		if entry := rpl.get(rpl.requestKey(ctx)); entry != nil {
+			replayFillResponse(ctx.Response(), entry, ctx.Request().Header.IsHead())
+
+			return nil
+		}
+
+		missMeta := &events.RequestMeta{
+			RequestID:   ctx.RequestID,
+			RequestType: ctx.RequestType,
+			Method:      string(ctx.Request().Header.Method()),
+			User:        ctx.User,
+			Addr:        ctx.RemoteAddr(),
+		}
+
+		ctx.Request().URI().CopyTo(&missMeta.URI)
+		ctx.EventStream.Send(ctx, events.EventTypeReplayMiss, missMeta, ctx.RequestID)
+
+		if opts.Passthrough == nil {
+			return ErrReplayMiss
+		}
+
+		return opts.Passthrough(ctx)
+	}
+}
+
+func replayFillResponse(response *fasthttp.Response, entry *har.Entry, isHead bool) {
+	response.Reset()
+	response.Header.DisableNormalizing()
+	response.SetStatusCode(entry.Response.Status)
+
+	content := &entry.Response.Content
+	isDecoded := content.Decoded || content.Compression != 0
+
+	for _, v := range entry.Response.Headers {
+		switch {
+		case headers.IsHopByHop(v.Name),
+			strings.EqualFold(v.Name, "Content-Length"),
+			isDecoded && strings.EqualFold(v.Name, "Content-Encoding"),
+			// HTTP/2 pseudo headers of browser exports.
+			strings.HasPrefix(v.Name, ":"):
+		default:
+			response.Header.Add(v.Name, v.Value)
+		}
+	}
+
+	body := replayDecodeText(content.Text, content.Encoding)
+
+	if isHead {
+		response.SkipBody = true
+		response.Header.SetContentLength(len(body))
+
+		return
+	}
+
+	response.SetBody(body)
+}
+
+func replayDecodeText(text, encoding string) []byte {
+	if encoding == "base64" {
+		if data, err := base64.StdEncoding.DecodeString(text); err == nil {
+			return data
+		}
+	}
+
+	return []byte(text)
+}
@@ -0,0 +1,218 @@
+package executor_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/executor"
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+)
+
+func makeReplayEntry(method, url, body string, headers ...har.NameValue) *har.Entry {
+	return &har.Entry{
+		Request: har.Request{
+			Method:  method,
+			URL:     url,
+			Headers: headers,
+		},
+		Response: har.Response{
+			Status: fasthttp.StatusOK,
+			Headers: []har.NameValue{
+				{Name: "x-test", Value: "1"},
+				{Name: "Content-Encoding", Value: "gzip"},
+				{Name: "Content-Length", Value: "100"},
+			},
+			Content: har.Content{
+				Text:    body,
+				Decoded: true,
+			},
+		},
+	}
+}
+
+type MakeReplayExecutorTestSuite struct {
+	suite.Suite
+
+	eventsChannel *EventChannelMock
+	ctx           *layers.Context
+}
+
+func (suite *MakeReplayExecutorTestSuite) SetupTest() {
+	fhttpCtx := &fasthttp.RequestCtx{}
+
+	fhttpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 65342}, nil)
+
+	suite.ctx = layers.AcquireContext()
+	suite.eventsChannel = &EventChannelMock{}
+
+	suite.ctx.Init(fhttpCtx, "127.0.0.1:80", suite.eventsChannel, "user", 0)
+	suite.ctx.Request().SetRequestURI("http://example.com/path?q=1")
+}
+
+func (suite *MakeReplayExecutorTestSuite) TearDownTest() {
+	suite.eventsChannel.AssertExpectations(suite.T())
+	layers.ReleaseContext(suite.ctx)
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestHit() {
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{
+			makeReplayEntry("POST", "http://example.com/path?q=1", "post"),
+			makeReplayEntry("GET", "http://example.com/path?q=1", "get"),
+		},
+	})
+
+	suite.NoError(exec(suite.ctx))
+	suite.Equal(fasthttp.StatusOK, suite.ctx.Response().StatusCode())
+	suite.Equal("get", string(suite.ctx.Response().Body()))
+	suite.Equal("1", string(suite.ctx.Response().Header.Peek("x-test")))
+	suite.Empty(suite.ctx.Response().Header.Peek("Content-Encoding"))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestNotDecoded() {
+	entry := makeReplayEntry("GET", "http://example.com/path?q=1", "\x1f\x8b")
+	entry.Response.Content.Decoded = false
+
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{entry},
+	})
+
+	suite.NoError(exec(suite.ctx))
+	suite.Equal("\x1f\x8b", string(suite.ctx.Response().Body()))
+	suite.Equal("gzip", string(suite.ctx.Response().Header.Peek("Content-Encoding")))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestBrowserCompression() {
+	entry := makeReplayEntry("GET", "http://example.com/path?q=1", "get")
+	entry.Response.Content.Decoded = false
+	entry.Response.Content.Compression = 10
+
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{entry},
+	})
+
+	suite.NoError(exec(suite.ctx))
+	suite.Empty(suite.ctx.Response().Header.Peek("Content-Encoding"))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestSequence() {
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{
+			makeReplayEntry("GET", "http://example.com/path?q=1", "first"),
+			makeReplayEntry("GET", "http://example.com/path?q=1", "second"),
+		},
+	})
+
+	for _, expected := range []string{"first", "second", "second"} {
+		suite.NoError(exec(suite.ctx))
+		suite.Equal(expected, string(suite.ctx.Response().Body()))
+	}
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestMiss() {
+	suite.eventsChannel.
+		On("Send", mock.Anything, events.EventTypeReplayMiss, mock.Anything, suite.ctx.RequestID).
+		Once()
+
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{
+			makeReplayEntry("GET", "http://example.com/path", "get"),
+		},
+	})
+
+	suite.True(errors.Is(exec(suite.ctx), executor.ErrReplayMiss))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestTruncated() {
+	suite.eventsChannel.
+		On("Send", mock.Anything, events.EventTypeReplayMiss, mock.Anything, suite.ctx.RequestID).
+		Once()
+
+	entry := makeReplayEntry("GET", "http://example.com/path?q=1", "trunc")
+	entry.Response.Content.Truncated = true
+
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{entry},
+	})
+
+	suite.True(errors.Is(exec(suite.ctx), executor.ErrReplayMiss))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestPassthrough() {
+	suite.eventsChannel.
+		On("Send", mock.Anything, events.EventTypeReplayMiss, mock.Anything, suite.ctx.RequestID).
+		Once()
+
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Passthrough: func(ctx *layers.Context) error {
+			ctx.Response().SetBodyString("passthrough")
+
+			return nil
+		},
+	})
+
+	suite.NoError(exec(suite.ctx))
+	suite.Equal("passthrough", string(suite.ctx.Response().Body()))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestMatchHeaders() {
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{
+			makeReplayEntry("GET", "http://example.com/path?q=1", "v1",
+				har.NameValue{Name: "x-version", Value: "1"}),
+			makeReplayEntry("GET", "http://example.com/path?q=1", "v2",
+				har.NameValue{Name: "x-version", Value: "2"}),
+		},
+		MatchHeaders: []string{"X-Version"},
+	})
+
+	suite.ctx.RequestHeaders.Set("X-Version", "2", true)
+
+	suite.NoError(exec(suite.ctx))
+	suite.Equal("v2", string(suite.ctx.Response().Body()))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestMatchBody() {
+	first := makeReplayEntry("POST", "", "first")
+	first.Request.PostData = &har.PostData{Text: "aGVsbG8=", Encoding: "base64"}
+
+	second := makeReplayEntry("POST", "", "second")
+	second.Request.PostData = &har.PostData{Text: "world"}
+
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{first, second},
+		Match:   executor.ReplayMatchMethod | executor.ReplayMatchBody,
+	})
+
+	suite.ctx.Request().Header.SetMethod("POST")
+	suite.ctx.Request().SetBodyStream(strings.NewReader("hello"), 5)
+
+	suite.NoError(exec(suite.ctx))
+	suite.Equal("first", string(suite.ctx.Response().Body()))
+	suite.Equal("hello", string(suite.ctx.Request().Body()))
+}
+
+func (suite *MakeReplayExecutorTestSuite) TestHead() {
+	exec := executor.MakeReplayExecutor(executor.ReplayOpts{
+		Entries: []*har.Entry{
+			makeReplayEntry("HEAD", "http://example.com/path?q=1", "hello"),
+		},
+	})
+
+	suite.ctx.Request().Header.SetMethod("HEAD")
+
+	suite.NoError(exec(suite.ctx))
+	suite.True(suite.ctx.Response().SkipBody)
+	suite.Equal(5, suite.ctx.Response().Header.ContentLength())
+}
+
+func TestMakeReplayExecutor(t *testing.T) {
+	suite.Run(t, &MakeReplayExecutorTestSuite{})
+}
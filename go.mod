@@ -2,6 +2,7 @@ module github.com/9seconds/httransform/v2
 
 require (
 	github.com/OneOfOne/xxhash v1.2.8
+	github.com/andybalholm/brotli v1.0.4
 	github.com/armon/go-socks5 v0.0.0-20160902184237-e75332964ef5
 	github.com/dgraph-io/ristretto v0.1.0
 	github.com/go-httpproxy/httpproxy v0.0.0-20180417134941-6977c68bf38e
@@ -14,11 +15,15 @@ require (
 	github.com/kentik/patricia v0.0.0-20201202224819-f9447a6e25f1
 	github.com/libp2p/go-reuseport v0.0.2
 	github.com/mccutchen/go-httpbin v1.1.1
-	github.com/stretchr/testify v1.5.1
+	github.com/stretchr/testify v1.7.0
 	github.com/valyala/fasthttp v1.34.0
 	github.com/valyala/fastrand v1.0.0
 	github.com/xeipuuv/gojsonschema v1.2.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f
+	golang.org/x/sys v0.0.0-20220227234510-4e6760a101f9
 )
 
 go 1.15
@@ -0,0 +1,28 @@
+// HTTP Archive (HAR) recording.
+//
+// This package contains types of HAR 1.2 format and a processor of
+// event stream which writes HAR entries into files. Entries are
+// produced by layers.HARLayer:
+//
+//     writer, err := har.NewFileWriter(har.FileWriterOpts{
+//         Dir:      "/var/log/proxy",
+//         MaxFiles: 10,
+//     })
+//
+//     opts := httransform.ServerOpts{
+//         EventProcessorFactory: har.NewProcessorFactory(writer),
+//         Layers: []layers.Layer{
+//             layers.HARLayer{ResponseBodySize: 64 * 1024},
+//             // other layers
+//         },
+//     }
+//
+// Files are rotated when they have too many entries or become too big.
+// Each rotated file is a complete HAR document which can be opened in
+// browser developer tools. A file which is being written is completed
+// when writer is closed.
+//
+// HAR format has no place for some information so a few custom fields
+// prefixed with underscore are added: request ID, user, TLS connection
+// details of a client and an error of the failed request.
+package har
@@ -0,0 +1,22 @@
+package har
+
+import "github.com/9seconds/httransform/v2/errors"
+
+var (
+	// ErrWriterClosed is returned if entry is written to closed
+	// FileWriter.
+	ErrWriterClosed = &errors.Error{
+		Message: "writer is closed",
+	}
+
+	// ErrNotDirectory is returned if FileWriterOpts.Dir is not a
+	// directory.
+	ErrNotDirectory = &errors.Error{
+		Message: "not a directory",
+	}
+
+	// ErrNoLog is returned if HAR document has no log object.
+	ErrNoLog = &errors.Error{
+		Message: "document has no log",
+	}
+)
@@ -0,0 +1,159 @@
+package har
+
+// Version is a version of HAR format.
+const Version = "1.2"
+
+// Log is a root object of HAR file.
+type Log struct {
+	Version string   `json:"version"`
+	Creator Creator  `json:"creator"`
+	Pages   []Page   `json:"pages"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Creator describes an application which has created HAR file.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Page is a page of HAR file. httransform does not group entries by
+// pages so it is defined only to conform a format.
+type Page struct {
+	StartedDateTime string `json:"startedDateTime"`
+	ID              string `json:"id"`
+	Title           string `json:"title"`
+}
+
+// Entry is a single HTTP request made through a proxy.
+type Entry struct {
+	// StartedDateTime is a time when request has been started in ISO
+	// 8601 format.
+	StartedDateTime string `json:"startedDateTime"`
+
+	// Time is a total time of the request in milliseconds.
+	Time float64 `json:"time"`
+
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	Cache    Cache    `json:"cache"`
+	Timings  Timings  `json:"timings"`
+
+	// ServerIPAddress is an IP address of the netloc if it is known.
+	ServerIPAddress string `json:"serverIPAddress,omitempty"`
+
+	Comment string `json:"comment,omitempty"`
+
+	// RequestID is a httransform identifier of the request.
+	RequestID string `json:"_requestId,omitempty"`
+
+	// User is a name of the user who has made a request.
+	User string `json:"_user,omitempty"`
+
+	// TLS describes a TLS connection between a client and a proxy.
+	// It is nil for plain HTTP requests.
+	TLS *TLS `json:"_tls,omitempty"`
+
+	// Error is set if request has failed and has no response.
+	Error string `json:"_error,omitempty"`
+}
+
+// Request describes HTTP request.
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Response describes HTTP response.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// NameValue is a name-value pair of headers and query parameters.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Cookie describes a cookie of the request or response.
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// PostData describes a body of the request.
+type PostData struct {
+	MimeType string      `json:"mimeType"`
+	Params   []NameValue `json:"params"`
+	Text     string      `json:"text"`
+
+	// Encoding is 'base64' if text is base64-encoded binary data.
+	Encoding string `json:"_encoding,omitempty"`
+
+	// Truncated is true if only a part of the body is recorded.
+	Truncated bool `json:"_truncated,omitempty"`
+}
+
+// Content describes a body of the response.
+type Content struct {
+	// Size is a length of the body in bytes after decoding of
+	// Content-Encoding.
+	Size        int64  `json:"size"`
+	Compression int64  `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+
+	// Encoding is 'base64' if text is base64-encoded binary data.
+	Encoding string `json:"encoding,omitempty"`
+
+	// Truncated is true if only a part of the body is recorded.
+	Truncated bool `json:"_truncated,omitempty"`
+
+	// Decoded is true if Text is decoded from Content-Encoding of the
+	// response. Otherwise it can keep raw compressed bytes if decoding
+	// has failed.
+	Decoded bool `json:"_decoded,omitempty"`
+}
+
+// Cache describes a cache usage. It is always empty.
+type Cache struct{}
+
+// Timings describes how long different phases of the request have
+// taken in milliseconds. -1 means that a phase is unknown.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// TLS describes a TLS connection between a client and a proxy.
+type TLS struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipherSuite"`
+	ServerName  string `json:"serverName,omitempty"`
+	ALPN        string `json:"alpn,omitempty"`
+}
@@ -0,0 +1,38 @@
+package har
+
+import "github.com/9seconds/httransform/v2/events"
+
+type processor struct {
+	writer *FileWriter
+}
+
+func (p processor) Process(evt events.Event) {
+	if evt.Type != events.EventTypeHAREntry {
+		return
+	}
+
+	if entry, ok := evt.Value.(*Entry); ok {
+		// there is nobody to report an error to.
+		p.writer.Write(entry) // nolint: errcheck
+	}
+}
+
+func (p processor) Shutdown() {
+	p.writer.release()
+}
+
+// NewProcessorFactory returns a factory of processors which write
+// events.EventTypeHAREntry events with a given writer. All other
+// events are ignored.
+//
+// All processors share the same writer. It is closed when the last
+// processor is shut down.
+func NewProcessorFactory(writer *FileWriter) events.ProcessorFactory {
+	return func() events.Processor {
+		writer.acquire()
+
+		return processor{
+			writer: writer,
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package har_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProcessorTestSuite struct {
+	suite.Suite
+
+	dir    string
+	writer *har.FileWriter
+}
+
+func (suite *ProcessorTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "har")
+
+	suite.Require().NoError(err)
+
+	suite.dir = dir
+	suite.writer, err = har.NewFileWriter(har.FileWriterOpts{
+		Dir: dir,
+	})
+
+	suite.Require().NoError(err)
+}
+
+func (suite *ProcessorTestSuite) TearDownTest() {
+	suite.writer.Close()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *ProcessorTestSuite) TestProcess() {
+	factory := har.NewProcessorFactory(suite.writer)
+	proc1 := factory()
+	proc2 := factory()
+
+	proc1.Process(events.Event{
+		Type:  events.EventTypeHAREntry,
+		Value: &har.Entry{RequestID: "1"},
+	})
+	proc2.Process(events.Event{
+		Type:  events.EventTypeHAREntry,
+		Value: &har.Entry{RequestID: "2"},
+	})
+	proc2.Process(events.Event{
+		Type: events.EventTypeFinishRequest,
+	})
+
+	proc1.Shutdown()
+	suite.NoError(suite.writer.Write(&har.Entry{RequestID: "3"}))
+
+	proc2.Shutdown()
+	suite.Error(suite.writer.Write(&har.Entry{RequestID: "4"}))
+
+	names, _ := filepath.Glob(filepath.Join(suite.dir, "*.har"))
+
+	suite.Require().Len(names, 1)
+
+	data, _ := ioutil.ReadFile(names[0])
+	doc := struct {
+		Log har.Log `json:"log"`
+	}{}
+
+	suite.NoError(json.Unmarshal(data, &doc))
+	suite.Len(doc.Log.Entries, 3)
+}
+
+func TestProcessor(t *testing.T) {
+	suite.Run(t, &ProcessorTestSuite{})
+}
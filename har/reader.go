@@ -0,0 +1,25 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ReadLog reads a HAR document. It can read files of FileWriter as
+// well as HAR files exported from browsers.
+func ReadLog(reader io.Reader) (*Log, error) {
+	doc := struct {
+		Log *Log `json:"log"`
+	}{}
+
+	if err := json.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("cannot decode a document: %w", err)
+	}
+
+	if doc.Log == nil {
+		return nil, ErrNoLog
+	}
+
+	return doc.Log, nil
+}
@@ -0,0 +1,40 @@
+package har_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReadLogTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ReadLogTestSuite) TestOk() {
+	log, err := har.ReadLog(strings.NewReader(`{"log": {"version": "1.2", "entries": [
+		{"request": {"method": "GET", "url": "http://example.com"}, "response": {"status": 200}}
+	]}}`))
+
+	suite.NoError(err)
+	suite.Len(log.Entries, 1)
+	suite.Equal("http://example.com", log.Entries[0].Request.URL)
+	suite.Equal(200, log.Entries[0].Response.Status)
+}
+
+func (suite *ReadLogTestSuite) TestNoLog() {
+	_, err := har.ReadLog(strings.NewReader(`{}`))
+
+	suite.Error(err)
+}
+
+func (suite *ReadLogTestSuite) TestIncorrectJSON() {
+	_, err := har.ReadLog(strings.NewReader(`{`))
+
+	suite.Error(err)
+}
+
+func TestReadLog(t *testing.T) {
+	suite.Run(t, &ReadLogTestSuite{})
+}
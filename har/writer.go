@@ -0,0 +1,276 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFilePrefix defines a prefix of HAR file names if user
+	// provides no value.
+	DefaultFilePrefix = "httransform"
+
+	// DefaultMaxEntries defines a max number of entries in a single
+	// HAR file if user provides no value.
+	DefaultMaxEntries = 1000
+
+	// DefaultMaxSize defines a max size of a single HAR file in bytes
+	// if user provides no value.
+	DefaultMaxSize = 100 * 1024 * 1024
+
+	fileTimeFormat = "20060102T150405.000000000"
+	fileFooter     = "]}}\n"
+)
+
+// FileWriterOpts defines a set of options for FileWriter.
+type FileWriterOpts struct {
+	// Dir is a directory where HAR files are created. Default is a
+	// current working directory.
+	Dir string
+
+	// Prefix is a prefix of HAR file names. Files are named like
+	// <prefix>-<UTC time>.har.
+	Prefix string
+
+	// MaxEntries defines a max number of entries in a single file.
+	MaxEntries uint
+
+	// MaxSize defines a max size of a single file in bytes. A file
+	// can be a bit bigger if entry does not fit into an empty file.
+	MaxSize uint
+
+	// MaxFiles defines how many files are kept in Dir. The oldest
+	// files are removed on rotation. 0 means that files are never
+	// removed.
+	MaxFiles uint
+}
+
+// GetDir returns a directory for HAR files or fallbacks to default
+// one.
+func (f *FileWriterOpts) GetDir() string {
+	if f.Dir == "" {
+		return "."
+	}
+
+	return f.Dir
+}
+
+// GetPrefix returns a prefix of file names or fallbacks to default
+// one.
+func (f *FileWriterOpts) GetPrefix() string {
+	if f.Prefix == "" {
+		return DefaultFilePrefix
+	}
+
+	return f.Prefix
+}
+
+// GetMaxEntries returns a max number of entries in a file or
+// fallbacks to default one.
+func (f *FileWriterOpts) GetMaxEntries() int {
+	if f.MaxEntries == 0 {
+		return DefaultMaxEntries
+	}
+
+	return int(f.MaxEntries)
+}
+
+// GetMaxSize returns a max size of a file or fallbacks to default one.
+func (f *FileWriterOpts) GetMaxSize() int {
+	if f.MaxSize == 0 {
+		return DefaultMaxSize
+	}
+
+	return int(f.MaxSize)
+}
+
+// FileWriter writes HAR entries into rotating files. Each file is a
+// valid HAR document once it is rotated or writer is closed.
+//
+// FileWriter is safe for concurrent use.
+type FileWriter struct {
+	opts    FileWriterOpts
+	header  []byte
+	mutex   sync.Mutex
+	file    *os.File
+	entries int
+	size    int
+	refs    int
+	closed  bool
+}
+
+// Write appends an entry to the current file. It rotates a file if
+// necessary.
+func (f *FileWriter) Write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cannot encode an entry: %w", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return ErrWriterClosed
+	}
+
+	if f.file != nil &&
+		(f.entries >= f.opts.GetMaxEntries() || f.size+len(data)+len(fileFooter) > f.opts.GetMaxSize()) {
+		if err := f.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	if f.file == nil {
+		if err := f.openFile(); err != nil {
+			return err
+		}
+	}
+
+	if f.entries > 0 {
+		data = append([]byte{','}, data...)
+	}
+
+	n, err := f.file.Write(data)
+	f.size += n
+
+	if err != nil {
+		return fmt.Errorf("cannot write an entry: %w", err)
+	}
+
+	f.entries++
+
+	return nil
+}
+
+// Close finalizes and closes the current file.
+func (f *FileWriter) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+
+	if f.file != nil {
+		return f.closeFile()
+	}
+
+	return nil
+}
+
+func (f *FileWriter) acquire() {
+	f.mutex.Lock()
+	f.refs++
+	f.mutex.Unlock()
+}
+
+func (f *FileWriter) release() {
+	f.mutex.Lock()
+	f.refs--
+	needToClose := f.refs == 0
+	f.mutex.Unlock()
+
+	if needToClose {
+		f.Close() // nolint: errcheck
+	}
+}
+
+func (f *FileWriter) openFile() error {
+	name := filepath.Join(f.opts.GetDir(),
+		f.opts.GetPrefix()+"-"+time.Now().UTC().Format(fileTimeFormat)+".har")
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644) // nolint: gomnd
+	if err != nil {
+		return fmt.Errorf("cannot create a file: %w", err)
+	}
+
+	if _, err := file.Write(f.header); err != nil {
+		file.Close()
+
+		return fmt.Errorf("cannot write a header: %w", err)
+	}
+
+	f.file = file
+	f.entries = 0
+	f.size = len(f.header)
+
+	f.removeOldFiles()
+
+	return nil
+}
+
+func (f *FileWriter) closeFile() error {
+	file := f.file
+	f.file = nil
+
+	if _, err := file.WriteString(fileFooter); err != nil {
+		file.Close()
+
+		return fmt.Errorf("cannot write a footer: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot close a file: %w", err)
+	}
+
+	return nil
+}
+
+func (f *FileWriter) removeOldFiles() {
+	if f.opts.MaxFiles == 0 {
+		return
+	}
+
+	names, _ := filepath.Glob(filepath.Join(f.opts.GetDir(), f.opts.GetPrefix()+"-*.har"))
+
+	// time in names is sortable so the oldest files go first.
+	sort.Strings(names)
+
+	for len(names) > int(f.opts.MaxFiles) {
+		if !strings.HasSuffix(names[0], filepath.Base(f.file.Name())) {
+			os.Remove(names[0])
+		}
+
+		names = names[1:]
+	}
+}
+
+// NewFileWriter returns a new FileWriter. Files are created lazily,
+// on the first entry.
+func NewFileWriter(opts FileWriterOpts) (*FileWriter, error) {
+	if stat, err := os.Stat(opts.GetDir()); err != nil || !stat.IsDir() {
+		return nil, fmt.Errorf("incorrect directory %s: %w", opts.GetDir(), ErrNotDirectory)
+	}
+
+	header, err := json.Marshal(map[string]interface{}{
+		"log": Log{
+			Version: Version,
+			Creator: Creator{
+				Name:    "httransform",
+				Version: "2",
+			},
+			Pages: []Page{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode a header: %w", err)
+	}
+
+	// header is '{"log":{...,"entries":null}}' and we need to cut
+	// 'null}}' to append entries.
+	header = append(header[:len(header)-len("null}}")], '[')
+
+	return &FileWriter{
+		opts:   opts,
+		header: header,
+	}, nil
+}
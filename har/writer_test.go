@@ -0,0 +1,142 @@
+package har_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/stretchr/testify/suite"
+)
+
+type FileWriterTestSuite struct {
+	suite.Suite
+
+	dir string
+}
+
+func (suite *FileWriterTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "har")
+
+	suite.Require().NoError(err)
+
+	suite.dir = dir
+}
+
+func (suite *FileWriterTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *FileWriterTestSuite) readFiles() []har.Log {
+	names, _ := filepath.Glob(filepath.Join(suite.dir, "*.har"))
+	logs := make([]har.Log, 0, len(names))
+
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		suite.Require().NoError(err)
+
+		doc := struct {
+			Log har.Log `json:"log"`
+		}{}
+
+		suite.Require().NoError(json.Unmarshal(data, &doc), string(data))
+
+		logs = append(logs, doc.Log)
+	}
+
+	return logs
+}
+
+func (suite *FileWriterTestSuite) write(writer *har.FileWriter, count int) {
+	for i := 0; i < count; i++ {
+		suite.NoError(writer.Write(&har.Entry{
+			RequestID: "id",
+		}))
+		// file names have nanosecond precision but not all
+		// platforms have such timers.
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (suite *FileWriterTestSuite) TestIncorrectDir() {
+	_, err := har.NewFileWriter(har.FileWriterOpts{
+		Dir: filepath.Join(suite.dir, "unknown"),
+	})
+
+	suite.Error(err)
+}
+
+func (suite *FileWriterTestSuite) TestWrite() {
+	writer, err := har.NewFileWriter(har.FileWriterOpts{
+		Dir: suite.dir,
+	})
+
+	suite.Require().NoError(err)
+
+	suite.write(writer, 3)
+	suite.NoError(writer.Close())
+
+	logs := suite.readFiles()
+
+	suite.Require().Len(logs, 1)
+	suite.Equal(har.Version, logs[0].Version)
+	suite.Equal("httransform", logs[0].Creator.Name)
+	suite.Len(logs[0].Entries, 3)
+	suite.Equal("id", logs[0].Entries[0].RequestID)
+
+	suite.Error(writer.Write(&har.Entry{}))
+	suite.NoError(writer.Close())
+}
+
+func (suite *FileWriterTestSuite) TestRotateEntries() {
+	writer, _ := har.NewFileWriter(har.FileWriterOpts{
+		Dir:        suite.dir,
+		MaxEntries: 2,
+	})
+
+	suite.write(writer, 5)
+	suite.NoError(writer.Close())
+
+	logs := suite.readFiles()
+
+	suite.Require().Len(logs, 3)
+	suite.Len(logs[0].Entries, 2)
+	suite.Len(logs[1].Entries, 2)
+	suite.Len(logs[2].Entries, 1)
+}
+
+func (suite *FileWriterTestSuite) TestRotateSize() {
+	writer, _ := har.NewFileWriter(har.FileWriterOpts{
+		Dir:     suite.dir,
+		MaxSize: 200,
+	})
+
+	suite.write(writer, 3)
+	suite.NoError(writer.Close())
+
+	suite.Len(suite.readFiles(), 3)
+}
+
+func (suite *FileWriterTestSuite) TestMaxFiles() {
+	writer, _ := har.NewFileWriter(har.FileWriterOpts{
+		Dir:        suite.dir,
+		Prefix:     "proxy",
+		MaxEntries: 1,
+		MaxFiles:   2,
+	})
+
+	suite.write(writer, 5)
+	suite.NoError(writer.Close())
+
+	names, _ := filepath.Glob(filepath.Join(suite.dir, "proxy-*.har"))
+
+	suite.Len(names, 2)
+	suite.Len(suite.readFiles(), 2)
+}
+
+func TestFileWriter(t *testing.T) {
+	suite.Run(t, &FileWriterTestSuite{})
+}
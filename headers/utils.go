@@ -31,3 +31,15 @@ func Values(value string) []string {
 
 	return values
 }
+
+// IsHopByHop checks if header with a given name is connection-specific.
+// Such headers make sense only for a single transport-level connection
+// and should not be proxied as is. HTTP/2 also forbids them.
+func IsHopByHop(name string) bool {
+	switch makeHeaderID(name) {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+		return true
+	}
+
+	return false
+}
@@ -30,3 +30,23 @@ func (suite *ValuesTestSuite) TestManySpace() {
 func TestValues(t *testing.T) {
 	suite.Run(t, &ValuesTestSuite{})
 }
+
+type IsHopByHopTestSuite struct {
+	suite.Suite
+}
+
+func (suite *IsHopByHopTestSuite) TestHopByHop() {
+	suite.True(headers.IsHopByHop("Connection"))
+	suite.True(headers.IsHopByHop("transfer-encoding"))
+	suite.True(headers.IsHopByHop(" Keep-Alive "))
+}
+
+func (suite *IsHopByHopTestSuite) TestEndToEnd() {
+	suite.False(headers.IsHopByHop("Content-Length"))
+	suite.False(headers.IsHopByHop("Accept"))
+	suite.False(headers.IsHopByHop(""))
+}
+
+func TestIsHopByHop(t *testing.T) {
+	suite.Run(t, &IsHopByHopTestSuite{})
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"sync"
 )
@@ -10,11 +11,20 @@ type closingReader struct {
 	bufReader *bufio.Reader
 	reader    io.Reader
 	closeOnce sync.Once
+	release   func(bool)
+	reusable  bool
+	chunked   bool
+	drained   bool
+	detached  bool
 }
 
 func (c *closingReader) Read(p []byte) (int, error) {
 	n, err := c.reader.Read(p)
 	if err != nil {
+		if err == io.EOF { // nolint: errorlint
+			c.drained = !c.chunked || c.skipTrailer()
+		}
+
 		c.closeOnce.Do(c.doClose)
 	}
 
@@ -22,11 +32,45 @@ func (c *closingReader) Read(p []byte) (int, error) {
 }
 
 func (c *closingReader) Close() error {
+	if c.detached {
+		c.detached = false
+
+		return nil
+	}
+
 	c.closeOnce.Do(c.doClose)
 
 	return nil
 }
 
+// Detach makes the next Close call a noop. It is required to replace
+// a body stream of the fasthttp response without closing a connection.
+func (c *closingReader) Detach() {
+	c.detached = true
+}
+
 func (c *closingReader) doClose() {
+	reusable := c.reusable && c.drained && c.bufReader.Buffered() == 0
+
 	releaseBufioReader(c.bufReader)
+
+	if c.release != nil {
+		c.release(reusable)
+	}
+}
+
+// skipTrailer reads a trailer part of the chunked body. Chunked reader
+// stops right after the last chunk so we have to consume the rest of
+// the message before connection can be reused.
+func (c *closingReader) skipTrailer() bool {
+	for {
+		line, err := c.bufReader.ReadSlice('\n')
+		if err != nil {
+			return false
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			return true
+		}
+	}
 }
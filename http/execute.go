@@ -4,24 +4,64 @@ import (
 	"context"
 	"io"
 	"net/http/httputil"
+	"time"
 
 	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/tracing"
 	"github.com/valyala/fasthttp"
 )
 
 // Execute sends an http request and assign a streaming body to the
 // given response. conn is a closable connection to the netloc.
-func Execute(ctx context.Context, // nolint: funlen, cyclop
+//
+// If ctx has timings (see events.WithTimings), Send and
+// TimeToFirstByte phases are filled. If ctx has a span (see
+// tracing.ContextWithSpan), sending of the request and reading of
+// response headers are traced as its child.
+func Execute(ctx context.Context,
 	conn io.ReadWriteCloser,
 	request *fasthttp.Request,
 	response *fasthttp.Response) error {
+	return ExecuteWithRelease(ctx, conn, request, response, nil)
+}
+
+// ExecuteWithRelease is the same as Execute but also notifies when
+// the response is completely consumed and connection is not used
+// anymore.
+//
+// release callback is called only if function returns no error. It
+// is called exactly once with a flag which defines if connection can
+// be reused for another request: response was read till the end, both
+// parties agreed to keep connection alive and nothing unexpected has
+// left in a socket.
+func ExecuteWithRelease(ctx context.Context, // nolint: funlen, cyclop
+	conn io.ReadWriteCloser,
+	request *fasthttp.Request,
+	response *fasthttp.Response,
+	release func(bool)) (err error) {
+	_, span := tracing.StartSpan(ctx, "execute")
+
+	defer func() {
+		if err == nil {
+			span.SetAttribute("http.status_code", response.Header.StatusCode())
+		}
+
+		span.RecordError(err)
+		span.End()
+	}()
+
 	subCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// ctx can be pooled and reset after we return so we should not
+	// touch it within a goroutine.
+	ctxDone := ctx.Done()
+
 	go func() {
 		select {
 		case <-subCtx.Done():
-		case <-ctx.Done():
+		case <-ctxDone:
 			select {
 			case <-subCtx.Done():
 			default:
@@ -30,6 +70,9 @@ func Execute(ctx context.Context, // nolint: funlen, cyclop
 		}
 	}()
 
+	timings := events.GetTimings(ctx)
+	startTime := time.Now()
+
 	if _, err := request.WriteTo(conn); err != nil {
 		return &errors.Error{
 			Message: "cannot send a request",
@@ -41,8 +84,17 @@ func Execute(ctx context.Context, // nolint: funlen, cyclop
 	response.Reset()
 	response.Header.DisableNormalizing()
 
+	sentTime := time.Now()
 	bufReader := acquireBufioReader(conn)
 
+	if timings != nil {
+		// an error is going to be returned by header reading.
+		bufReader.Peek(1) // nolint: errcheck
+
+		timings.Send += sentTime.Sub(startTime)
+		timings.TimeToFirstByte += time.Since(sentTime)
+	}
+
 	for code := fasthttp.StatusContinue; code == fasthttp.StatusContinue; code = response.Header.StatusCode() {
 		if err := response.Header.Read(bufReader); err != nil {
 			releaseBufioReader(bufReader)
@@ -56,21 +108,25 @@ func Execute(ctx context.Context, // nolint: funlen, cyclop
 	}
 
 	contentLength := response.Header.ContentLength()
+	reader := &closingReader{
+		bufReader: bufReader,
+		release:   release,
+		reusable: !request.Header.ConnectionClose() &&
+			!response.Header.ConnectionClose() &&
+			contentLength != -2, // nolint: gomnd
+	}
 
 	switch {
 	case contentLength == 0 || request.Header.IsHead():
 		response.SkipBody = true
+		reader.drained = true
+		reader.Close()
 	case contentLength > 0:
-		reader := &closingReader{
-			bufReader: bufReader,
-			reader:    io.LimitReader(bufReader, int64(contentLength)),
-		}
+		reader.reader = io.LimitReader(bufReader, int64(contentLength))
 		response.SetBodyStream(reader, contentLength)
 	default:
-		reader := &closingReader{
-			bufReader: bufReader,
-			reader:    httputil.NewChunkedReader(bufReader),
-		}
+		reader.chunked = true
+		reader.reader = httputil.NewChunkedReader(bufReader)
 		response.SetBodyStream(reader, -1)
 	}
 
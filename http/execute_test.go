@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/9seconds/httransform/v2/events"
 	"github.com/9seconds/httransform/v2/http"
 	"github.com/mccutchen/go-httpbin/httpbin"
 	"github.com/stretchr/testify/mock"
@@ -135,6 +136,29 @@ func (suite *ExecuteTestSuite) TestResponseContentLength() {
 	suite.Equal(nethttp.StatusOK, suite.resp.StatusCode())
 }
 
+func (suite *ExecuteTestSuite) TestTimings() {
+	endpoint := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(nethttp.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	suite.req.SetRequestURI(endpoint.URL + "/")
+
+	addr := endpoint.Listener.Addr()
+
+	conn, _ := net.Dial(addr.Network(), addr.String())
+	defer conn.Close()
+
+	timings := &events.Timings{}
+	ctx := events.WithTimings(suite.ctx, timings)
+
+	suite.NoError(http.Execute(ctx, conn, suite.req, suite.resp))
+	suite.Equal(nethttp.StatusNoContent, suite.resp.StatusCode())
+	suite.Greater(int64(timings.Send), int64(0))
+	suite.GreaterOrEqual(int64(timings.TimeToFirstByte), int64(50*time.Millisecond))
+}
+
 func (suite *ExecuteTestSuite) TestResponseStream() {
 	app := httpbin.NewHTTPBin()
 	endpoint := httptest.NewServer(app.Handler())
@@ -0,0 +1,83 @@
+package httransform
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/9seconds/httransform/v2/errors"
+)
+
+// tunnelSniffTimeout defines how long we wait for the first bytes of
+// tunneled connection. Some protocols expect the netloc to speak first
+// so we cannot wait forever.
+const tunnelSniffTimeout = 3 * time.Second
+
+var errClientHelloSniffed = &errors.Error{
+	Message: "client hello is sniffed",
+}
+
+// InterceptPolicy decides if TLS connection should be intercepted.
+// user is a name of authenticated user, host is a hostname from
+// CONNECT method and hello is a ClientHello client has sent. If policy
+// returns false, connection is passed to the netloc as is.
+//
+// This is useful for certificate-pinned applications or domains you
+// do not want to touch: banks, health services and so on.
+type InterceptPolicy func(user, host string, hello *tls.ClientHelloInfo) bool
+
+// InterceptAll is an InterceptPolicy which intercepts all TLS
+// connections.
+func InterceptAll(_, _ string, _ *tls.ClientHelloInfo) bool {
+	return true
+}
+
+// sniffConn is a connection which discards everything which is
+// written. We use it to parse ClientHello with crypto/tls without
+// responding to a client.
+type sniffConn struct {
+	net.Conn
+}
+
+func (s sniffConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// sniffClientHello reads and parses ClientHello from the connection.
+// Connection is expected to be conns.UnreadConn so read bytes can be
+// replayed later. If client does not talk TLS, tls.RecordHeaderError
+// is returned.
+func sniffClientHello(conn net.Conn) (*tls.ClientHelloInfo, error) {
+	var hello *tls.ClientHelloInfo
+
+	err := tls.Server(sniffConn{conn}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = info
+
+			return nil, errClientHelloSniffed
+		},
+	}).Handshake()
+
+	if hello == nil {
+		return nil, err // nolint: wrapcheck
+	}
+
+	return hello, nil
+}
+
+// looksLikeHTTP checks if given first bytes of the stream look like a
+// beginning of HTTP/1 request: a method in uppercase letters followed
+// by a space.
+func looksLikeHTTP(data []byte) bool {
+	for i, c := range data {
+		switch {
+		case c >= 'A' && c <= 'Z':
+		case c == ' ':
+			return i >= 3 // nolint: gomnd
+		default:
+			return false
+		}
+	}
+
+	return len(data) >= 3 // nolint: gomnd
+}
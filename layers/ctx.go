@@ -2,18 +2,28 @@ package layers
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/9seconds/httransform/v2/conns"
+	"github.com/9seconds/httransform/v2/dialers"
 	"github.com/9seconds/httransform/v2/errors"
 	"github.com/9seconds/httransform/v2/events"
 	"github.com/9seconds/httransform/v2/headers"
+	"github.com/9seconds/httransform/v2/tracing"
 	"github.com/gofrs/uuid"
 	"github.com/valyala/fasthttp"
 )
 
+// ParentContextKey is a key of fasthttp.RequestCtx user value. If it
+// keeps context.Context, this context is used as a parent of Context
+// instead of fasthttp.RequestCtx. Servers which do not own a client
+// connection (like HTTP/2 one) set it so request is cancelled when
+// client goes away.
+const ParentContextKey = "httransform_parent_context"
+
 // RequestHijacker is a function signature you can use for internal
 // hijacking. You function will have both ends: a client connection and
 // a netloc connection.
@@ -36,9 +46,26 @@ type Context struct {
 	// authentication, it is an empty string.
 	User string
 
+	// SNI is a server name client has sent in TLS ClientHello. It is
+	// empty for plain HTTP requests or if client has sent no SNI.
+	// Please pay attention that it may differ from a hostname of
+	// ConnectTo: some clients do CONNECT to IP addresses.
+	SNI string
+
+	// Dialer is a dialer executor has to use for this request. If it
+	// is nil, executor uses a dialer it was created with. Layers can
+	// set it in OnRequest to route requests through different
+	// upstreams, for example, based on a User.
+	Dialer dialers.Dialer
+
 	// EventStream is an instance of event stream to use.
 	EventStream events.Stream
 
+	// Timings is a breakdown of request processing time. Context
+	// carries it (see events.WithTimings) so dialers and executors
+	// fill their phases. It is sent with events.EventTypeFinishRequest.
+	Timings events.Timings
+
 	// RequestType is a bitset related to different characteristics of the
 	// request.
 	RequestType events.RequestType
@@ -113,6 +140,16 @@ func (c *Context) LocalAddr() net.Addr {
 	return nil
 }
 
+// TLSConnectionState returns a state of TLS connection between a client
+// and a proxy. It returns nil for plain HTTP requests.
+func (c *Context) TLSConnectionState() *tls.ConnectionState {
+	if c.originalCtx != nil {
+		return c.originalCtx.TLSConnectionState()
+	}
+
+	return nil
+}
+
 // Respond is just a shortcut for the fast response. This response is
 // just a plain text with a status code.
 func (c *Context) Respond(msg string, statusCode int) {
@@ -172,6 +209,13 @@ func (c *Context) Hijacked() bool {
 	return c.originalCtx != nil && c.originalCtx.Hijacked()
 }
 
+// SetSpan sets a span of the request. Spans started with this
+// context are its children. Usually server sets it so you do not need
+// to call it.
+func (c *Context) SetSpan(span tracing.Span) {
+	c.ctx = tracing.ContextWithSpan(c.ctx, span)
+}
+
 // Init initializes a Context based on given parameters.
 //
 // fasthttpCtx is a parent context which produced this one, connectTo
@@ -183,8 +227,15 @@ func (c *Context) Init(fasthttpCtx *fasthttp.RequestCtx,
 	eventStream events.Stream,
 	user string,
 	requestType events.RequestType) error {
-	ctx, cancel := context.WithCancel(fasthttpCtx)
+	var parent context.Context = fasthttpCtx
 
+	if value, ok := fasthttpCtx.UserValue(ParentContextKey).(context.Context); ok {
+		parent = value
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+
+	c.Timings = events.Timings{}
 	c.RequestID = uuid.Must(uuid.NewV4()).String()
 	c.RequestType = requestType
 	c.EventStream = eventStream
@@ -192,7 +243,7 @@ func (c *Context) Init(fasthttpCtx *fasthttp.RequestCtx,
 	c.User = user
 
 	c.originalCtx = fasthttpCtx
-	c.ctx = ctx
+	c.ctx = events.WithTimings(ctx, &c.Timings)
 	c.ctxCancel = cancel
 
 	uri := fasthttpCtx.Request.URI()
@@ -232,6 +283,9 @@ func (c *Context) Reset() {
 	c.EventStream = nil
 	c.ConnectTo = ""
 	c.User = ""
+	c.SNI = ""
+	c.Dialer = nil
+	c.Timings = events.Timings{}
 
 	c.RequestHeaders.Reset(nil)
 	c.ResponseHeaders.Reset(nil)
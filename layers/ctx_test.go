@@ -7,6 +7,7 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/9seconds/httransform/v2/errors"
 	"github.com/9seconds/httransform/v2/events"
@@ -147,6 +148,17 @@ func (suite *ContextTestSuite) TestErrorCustom() {
 	suite.Equal("application/json", string(resp.Header.ContentType()))
 }
 
+func (suite *ContextTestSuite) TestTimings() {
+	suite.Equal(&suite.ctx.Timings, events.GetTimings(suite.ctx))
+
+	suite.ctx.Timings.DNS = time.Second
+
+	layers.ReleaseContext(suite.ctx)
+	suite.ctx = layers.AcquireContext()
+
+	suite.Zero(suite.ctx.Timings.DNS)
+}
+
 func (suite *ContextTestSuite) TestHijack() {
 	suite.ctx.Hijack(nil, func(_, _ net.Conn) {})
 	suite.True(suite.ctx.Hijacked())
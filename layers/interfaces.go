@@ -1,5 +1,7 @@
 package layers
 
+import "github.com/9seconds/httransform/v2/dialers"
+
 // Layer is a middleware which processes a request and a response.
 //
 // You can think about layers as stacks: you go through the list forward
@@ -24,3 +26,18 @@ type Layer interface {
 	// return a new one.
 	OnResponse(*Context, error) error
 }
+
+// TunnelDialerLayer is a layer which also chooses a dialer for tunnels
+// which are not intercepted: TLS connections which are passed through
+// and raw TCP tunnels. Such tunnels carry no HTTP requests so OnRequest
+// is never called for them.
+//
+// If several layers implement this interface, the last one which
+// returns a dialer wins: the same as with Context.Dialer.
+type TunnelDialerLayer interface {
+	Layer
+
+	// TunnelDialer returns a dialer for a tunnel of the user to the
+	// given address. If it returns nil, a dialer of the server is used.
+	TunnelDialer(user, address string) dialers.Dialer
+}
@@ -0,0 +1,439 @@
+package layers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/headers"
+	"github.com/andybalholm/brotli"
+	"github.com/valyala/fasthttp"
+)
+
+// zlibMethodDeflate is a compression method of zlib header (RFC 1950)
+// which corresponds to deflate.
+const zlibMethodDeflate = 8
+
+// BodyTransformer is a function which wraps a body stream with a
+// filter. body is already decoded according to Content-Encoding header
+// and returned reader has to produce decoded data as well:
+// BodyTransformerLayer encodes it back.
+//
+// Please pay attention that transformer is called within a layer but
+// returned reader is consumed after that, when Context is already
+// released. So returned reader must not keep a reference to a context.
+// If returned reader implements io.Closer, it is closed when body is
+// consumed or aborted.
+type BodyTransformer func(ctx *Context, body io.Reader) (io.Reader, error)
+
+// BodyDetacher is implemented by body streams which can be detached
+// from fasthttp request or response. fasthttp closes a body stream
+// when it is replaced so a body stream should ignore the next Close
+// call after Detach.
+//
+// Body streams of executors and layers of this package support
+// detaching so layers can be chained without buffering. If a
+// body stream is io.Closer but does not implement this interface,
+// BodyTransformerLayer has to buffer it.
+type BodyDetacher interface {
+	Detach()
+}
+
+// BodyTransformerLayer rewrites bodies of requests and responses with
+// given transformers. Bodies are processed in a streaming manner: they
+// are never buffered if executor supports BodyDetacher.
+//
+// This layer takes care of framing: Content-Length is removed and
+// body is sent with chunked Transfer-Encoding. gzip, deflate and br
+// content encodings are decoded before a transformer and encoded back
+// after. Bodies with unknown content encodings are not transformed.
+type BodyTransformerLayer struct {
+	// Request is a transformer of request bodies. It is optional.
+	Request BodyTransformer
+
+	// Response is a transformer of response bodies. It is optional.
+	Response BodyTransformer
+}
+
+// OnRequest is to conform Layer interface.
+func (b BodyTransformerLayer) OnRequest(ctx *Context) error {
+	if b.Request == nil {
+		return nil
+	}
+
+	req := ctx.Request()
+	if !req.IsBodyStream() && len(req.Body()) == 0 {
+		return nil
+	}
+
+	contentLength := req.Header.ContentLength()
+
+	// fasthttp reads client body stream according to a content length
+	// of the request header which is going to be changed.
+	pinContentLength := func(stream io.Reader) io.Reader {
+		return newBodyTransformerRequestStream(stream, &req.Header, contentLength)
+	}
+
+	body, err := b.transform(ctx, b.Request, &ctx.RequestHeaders, req.BodyWriteTo, pinContentLength)
+	if err != nil {
+		return errors.Annotate(err, "cannot transform request body", "body_transformer", 0)
+	}
+
+	if body != nil {
+		req.SetBodyStream(body, -1)
+	}
+
+	return nil
+}
+
+// OnResponse is to conform Layer interface.
+func (b BodyTransformerLayer) OnResponse(ctx *Context, err error) error {
+	if err != nil || b.Response == nil {
+		return err
+	}
+
+	resp := ctx.Response()
+
+	switch {
+	case resp.SkipBody,
+		ctx.Request().Header.IsHead(),
+		resp.StatusCode() == fasthttp.StatusNoContent,
+		resp.StatusCode() == fasthttp.StatusNotModified:
+		return nil
+	}
+
+	body, err := b.transform(ctx, b.Response, &ctx.ResponseHeaders, resp.BodyWriteTo, nil)
+	if err != nil {
+		return errors.Annotate(err, "cannot transform response body", "body_transformer", 0)
+	}
+
+	if body != nil {
+		resp.SetBodyStream(body, -1)
+	}
+
+	return nil
+}
+
+func (b BodyTransformerLayer) transform(ctx *Context,
+	transformer BodyTransformer,
+	hdrs *headers.Headers,
+	bodyWriteTo func(io.Writer) error,
+	wrapStolen func(io.Reader) io.Reader) (io.ReadCloser, error) {
+	encoding := ""
+
+	if header := hdrs.GetLast("Content-Encoding"); header != nil {
+		encoding = strings.ToLower(strings.TrimSpace(header.Value()))
+	}
+
+	switch encoding {
+	case "", "identity", "gzip", "x-gzip", "deflate", "br":
+	default:
+		return nil, nil
+	}
+
+	stealer := &bodyStealer{}
+	if err := bodyWriteTo(stealer); err != nil {
+		return nil, errors.Annotate(err, "cannot read a body", "", 0)
+	}
+
+	original := stealer.getStream()
+
+	if _, ok := original.(BodyDetacher); !ok && stealer.stream != nil && wrapStolen != nil {
+		original = wrapStolen(original)
+	}
+
+	filtered, err := transformer(ctx, bodyTransformerDecode(encoding, original))
+	if err != nil {
+		if closer, ok := original.(io.Closer); ok {
+			closer.Close()
+		}
+
+		return nil, err
+	}
+
+	stream := &bodyTransformerStream{
+		original: original,
+		filtered: filtered,
+		encoder:  bodyTransformerEncoder(encoding),
+	}
+
+	hdrs.Remove("Content-Length")
+	hdrs.Set("Transfer-Encoding", "chunked", true)
+
+	return stream, nil
+}
+
+// bodyStealer takes a body stream from fasthttp request or response.
+// fasthttp has no method to get a body stream but BodyWriteTo uses
+// io.Copy which calls ReaderFrom with an original stream.
+type bodyStealer struct {
+	stream io.Reader
+	buf    bytes.Buffer
+}
+
+func (b *bodyStealer) ReadFrom(r io.Reader) (int64, error) {
+	switch value := r.(type) {
+	case BodyDetacher:
+		value.Detach()
+	case io.Closer:
+		// stream is going to be closed by fasthttp so we have to
+		// read it till the end.
+		return b.buf.ReadFrom(r) // nolint: wrapcheck
+	}
+
+	b.stream = r
+
+	return 0, nil
+}
+
+func (b *bodyStealer) Write(p []byte) (int, error) {
+	return b.buf.Write(p) // nolint: wrapcheck
+}
+
+func (b *bodyStealer) getStream() io.Reader {
+	if b.stream != nil {
+		return b.stream
+	}
+
+	return &b.buf
+}
+
+// bodyTransformerRequestStream reads a client body stream of fasthttp.
+// fasthttp reads a body of known size according to a content length of
+// the request header but layers change it. Executor writes request
+// headers before it reads a body so the original content length is
+// set back once, on the first read. Reader is limited by this length
+// so it never reads after the body.
+type bodyTransformerRequestStream struct {
+	reader        io.Reader
+	header        *fasthttp.RequestHeader
+	contentLength int
+	pinned        bool
+}
+
+func (b *bodyTransformerRequestStream) Read(p []byte) (int, error) {
+	if !b.pinned {
+		b.pinned = true
+
+		if b.header.ContentLength() != b.contentLength {
+			b.header.SetContentLength(b.contentLength)
+		}
+	}
+
+	return b.reader.Read(p) // nolint: wrapcheck
+}
+
+func newBodyTransformerRequestStream(stream io.Reader,
+	header *fasthttp.RequestHeader,
+	contentLength int) *bodyTransformerRequestStream {
+	reader := stream
+
+	// chunked body has no known length: fasthttp reads it till the
+	// last chunk and request header stays chunked.
+	if contentLength >= 0 {
+		reader = io.LimitReader(stream, int64(contentLength))
+	}
+
+	return &bodyTransformerRequestStream{
+		reader:        reader,
+		header:        header,
+		contentLength: contentLength,
+		pinned:        contentLength < 0,
+	}
+}
+
+// bodyTransformerStream is a body stream which is set to fasthttp
+// request or response. If body has to be encoded, encoding is done in
+// a separate goroutine. This goroutine owns an original stream after
+// start so it closes it on exit.
+type bodyTransformerStream struct {
+	original   io.Reader
+	filtered   io.Reader
+	encoder    func(io.Writer) io.WriteCloser
+	pipeReader *io.PipeReader
+	mutex      sync.Mutex
+	started    bool
+	closed     bool
+	detached   bool
+}
+
+func (b *bodyTransformerStream) Read(p []byte) (int, error) {
+	if b.encoder == nil {
+		n, err := b.filtered.Read(p)
+		if err != nil {
+			b.Close()
+		}
+
+		return n, err // nolint: wrapcheck
+	}
+
+	b.mutex.Lock()
+
+	if b.closed {
+		b.mutex.Unlock()
+
+		return 0, io.ErrClosedPipe
+	}
+
+	if !b.started {
+		pipeReader, pipeWriter := io.Pipe()
+
+		b.started = true
+		b.pipeReader = pipeReader
+
+		go b.encode(pipeWriter)
+	}
+
+	b.mutex.Unlock()
+
+	n, err := b.pipeReader.Read(p)
+	if err != nil {
+		b.Close()
+	}
+
+	return n, err // nolint: wrapcheck
+}
+
+func (b *bodyTransformerStream) encode(pipeWriter *io.PipeWriter) {
+	defer b.closeSources()
+
+	writer := b.encoder(pipeWriter)
+	_, err := io.Copy(writer, b.filtered)
+
+	if err2 := writer.Close(); err == nil {
+		err = err2
+	}
+
+	pipeWriter.CloseWithError(err) // nolint: errcheck
+}
+
+// Detach conforms BodyDetacher interface.
+func (b *bodyTransformerStream) Detach() {
+	b.mutex.Lock()
+	b.detached = true
+	b.mutex.Unlock()
+}
+
+func (b *bodyTransformerStream) Close() error {
+	b.mutex.Lock()
+
+	if b.detached {
+		b.detached = false
+		b.mutex.Unlock()
+
+		return nil
+	}
+
+	if b.closed {
+		b.mutex.Unlock()
+
+		return nil
+	}
+
+	b.closed = true
+	started := b.started
+
+	b.mutex.Unlock()
+
+	if started {
+		// encoding goroutine closes sources when it is finished.
+		b.pipeReader.Close()
+	} else {
+		b.closeSources()
+	}
+
+	return nil
+}
+
+func (b *bodyTransformerStream) closeSources() {
+	if closer, ok := b.filtered.(io.Closer); ok {
+		closer.Close()
+	}
+
+	if closer, ok := b.original.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// bodyTransformerLazyReader initializes a reader on the first read.
+// Some decoders read headers on initialization so we do not want to
+// block a layer until netloc sends a body.
+type bodyTransformerLazyReader struct {
+	init   func() (io.Reader, error)
+	reader io.Reader
+	err    error
+}
+
+func (b *bodyTransformerLazyReader) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.reader, b.err = b.init()
+	}
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.reader.Read(p) // nolint: wrapcheck
+}
+
+func bodyTransformerDecode(encoding string, body io.Reader) io.Reader {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return &bodyTransformerLazyReader{
+			init: func() (io.Reader, error) {
+				return gzip.NewReader(body)
+			},
+		}
+	case "deflate":
+		return &bodyTransformerLazyReader{
+			init: func() (io.Reader, error) {
+				return bodyTransformerDeflateReader(body)
+			},
+		}
+	case "br":
+		return brotli.NewReader(body)
+	}
+
+	return body
+}
+
+// bodyTransformerDeflateReader decodes deflate content encoding. RFC
+// says it is zlib stream but some servers send raw deflate data so it
+// is decoded if there is no valid zlib header.
+func bodyTransformerDeflateReader(body io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(body)
+
+	header, err := reader.Peek(2) // nolint: gomnd
+	if err == nil &&
+		header[0]&0x0f == zlibMethodDeflate &&
+		(uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(reader) // nolint: wrapcheck
+	}
+
+	return flate.NewReader(reader), nil
+}
+
+func bodyTransformerEncoder(encoding string) func(io.Writer) io.WriteCloser {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		}
+	case "deflate":
+		return func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		}
+	case "br":
+		return func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriter(w)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package layers_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/suite"
+)
+
+type upperReader struct {
+	reader io.Reader
+}
+
+func (u upperReader) Read(p []byte) (int, error) {
+	n, err := u.reader.Read(p)
+
+	copy(p[:n], bytes.ToUpper(p[:n]))
+
+	return n, err
+}
+
+type detachableStream struct {
+	io.Reader
+
+	detached bool
+	closed   bool
+}
+
+func (d *detachableStream) Detach() {
+	d.detached = true
+}
+
+func (d *detachableStream) Close() error {
+	if d.detached {
+		d.detached = false
+
+		return nil
+	}
+
+	d.closed = true
+
+	return nil
+}
+
+type LayerBodyTransformerTestSuite struct {
+	BaseLayerTestSuite
+}
+
+func (suite *LayerBodyTransformerTestSuite) SetupTest() {
+	suite.BaseLayerTestSuite.SetupTest()
+
+	transformer := func(_ *layers.Context, body io.Reader) (io.Reader, error) {
+		return upperReader{body}, nil
+	}
+
+	suite.l = layers.BodyTransformerLayer{
+		Request:  transformer,
+		Response: transformer,
+	}
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestRequest() {
+	suite.ctx.Request().SetBodyString("hello")
+	suite.ctx.RequestHeaders.Set("Content-Length", "5", true)
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.True(suite.ctx.Request().IsBodyStream())
+	suite.Nil(suite.ctx.RequestHeaders.GetLast("Content-Length"))
+	suite.Equal("chunked", suite.ctx.RequestHeaders.GetLast("Transfer-Encoding").Value())
+	suite.Equal("HELLO", string(suite.ctx.Request().Body()))
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestRequestNoBody() {
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.False(suite.ctx.Request().IsBodyStream())
+	suite.Nil(suite.ctx.RequestHeaders.GetLast("Transfer-Encoding"))
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseError() {
+	err := errors.New("unexpected")
+
+	suite.ctx.Response().SetBodyString("hello")
+
+	suite.Equal(err, suite.l.OnResponse(suite.ctx, err))
+	suite.False(suite.ctx.Response().IsBodyStream())
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseStream() {
+	stream := &detachableStream{
+		Reader: strings.NewReader("hello"),
+	}
+
+	suite.ctx.Response().SetBodyStream(stream, 5)
+	suite.ctx.ResponseHeaders.Set("Content-Length", "5", true)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.False(stream.closed)
+	suite.Nil(suite.ctx.ResponseHeaders.GetLast("Content-Length"))
+	suite.Equal("HELLO", string(suite.ctx.Response().Body()))
+	suite.True(stream.closed)
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseGzip() {
+	buf := bytes.Buffer{}
+	writer := gzip.NewWriter(&buf)
+
+	writer.Write([]byte("hello"))
+	writer.Close()
+
+	suite.ctx.Response().SetBody(buf.Bytes())
+	suite.ctx.ResponseHeaders.Set("Content-Encoding", "gzip", true)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+
+	reader, err := gzip.NewReader(bytes.NewReader(suite.ctx.Response().Body()))
+
+	suite.NoError(err)
+
+	data, err := ioutil.ReadAll(reader)
+
+	suite.NoError(err)
+	suite.Equal("HELLO", string(data))
+	suite.Equal("gzip", suite.ctx.ResponseHeaders.GetLast("Content-Encoding").Value())
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseBrotli() {
+	buf := bytes.Buffer{}
+	writer := brotli.NewWriter(&buf)
+
+	writer.Write([]byte("hello"))
+	writer.Close()
+
+	suite.ctx.Response().SetBodyStream(&detachableStream{Reader: &buf}, -1)
+	suite.ctx.ResponseHeaders.Set("Content-Encoding", "br", true)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+
+	data, err := ioutil.ReadAll(brotli.NewReader(bytes.NewReader(suite.ctx.Response().Body())))
+
+	suite.NoError(err)
+	suite.Equal("HELLO", string(data))
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseUnknownEncoding() {
+	suite.ctx.Response().SetBodyString("hello")
+	suite.ctx.ResponseHeaders.Set("Content-Encoding", "zstd", true)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.False(suite.ctx.Response().IsBodyStream())
+	suite.Equal("hello", string(suite.ctx.Response().Body()))
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseHead() {
+	suite.ctx.Request().Header.SetMethod("HEAD")
+	suite.ctx.Response().SetBodyString("hello")
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.False(suite.ctx.Response().IsBodyStream())
+}
+
+func (suite *LayerBodyTransformerTestSuite) TestResponseDeflate() {
+	for _, zlibWrapped := range []bool{true, false} {
+		buf := bytes.Buffer{}
+
+		var writer io.WriteCloser = zlib.NewWriter(&buf)
+
+		if !zlibWrapped {
+			writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		}
+
+		writer.Write([]byte("hello"))
+		writer.Close()
+
+		suite.ctx.Response().SetBody(buf.Bytes())
+		suite.ctx.ResponseHeaders.Set("Content-Encoding", "deflate", true)
+
+		suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+
+		reader, err := zlib.NewReader(bytes.NewReader(suite.ctx.Response().Body()))
+
+		suite.NoError(err)
+
+		data, err := ioutil.ReadAll(reader)
+
+		suite.NoError(err)
+		suite.Equal("HELLO", string(data))
+	}
+}
+
+// This test pins a behaviour of fasthttp the layer relies on:
+// Response.BodyWriteTo passes a body stream to io.ReaderFrom as is. If
+// it is changed, a body is going to be read before a transformer is
+// called.
+func (suite *LayerBodyTransformerTestSuite) TestResponseStreamIsNotRead() {
+	pipeReader, pipeWriter := io.Pipe()
+	stream := &detachableStream{
+		Reader: pipeReader,
+	}
+
+	var transformed io.Reader
+
+	suite.l = layers.BodyTransformerLayer{
+		Response: func(_ *layers.Context, body io.Reader) (io.Reader, error) {
+			transformed = body
+
+			return upperReader{body}, nil
+		},
+	}
+
+	suite.ctx.Response().SetBodyStream(stream, -1)
+
+	done := make(chan error)
+
+	go func() {
+		done <- suite.l.OnResponse(suite.ctx, nil)
+	}()
+
+	select {
+	case err := <-done:
+		suite.NoError(err)
+	case <-time.After(time.Second):
+		pipeWriter.Close()
+		suite.FailNow("body stream is read before transformation")
+	}
+
+	suite.Same(stream, transformed)
+
+	go func() {
+		pipeWriter.Write([]byte("hello"))
+		pipeWriter.Close()
+	}()
+
+	suite.Equal("HELLO", string(suite.ctx.Response().Body()))
+}
+
+func TestLayerBodyTransformer(t *testing.T) {
+	suite.Run(t, &LayerBodyTransformerTestSuite{})
+}
@@ -12,14 +12,15 @@ import (
 )
 
 type filterSubnetsLayer struct {
-	v4 *bool_tree.TreeV4
-	v6 *bool_tree.TreeV6
+	v4  *bool_tree.TreeV4
+	v6  *bool_tree.TreeV6
+	dns dns.Interface
 }
 
 func (f *filterSubnetsLayer) OnRequest(ctx *Context) error {
 	host, _, _ := net.SplitHostPort(ctx.ConnectTo)
 
-	resolved, err := dns.Default.Lookup(ctx, host)
+	resolved, err := f.dns.Lookup(ctx, host)
 	if err != nil {
 		// pass unresolved name, delegate it to executor.
 		return nil // nolint: nilerr
@@ -76,11 +77,20 @@ func (f *filterSubnetsLayer) OnResponse(ctx *Context, err error) error {
 // For example, you can block requests to 127.0.0.1/8, 10.0.0.0/8.
 //
 // This layer does DNS queries and uses their results to understand if
-// it worth to proceed or not.
+// it worth to proceed or not. DNS queries are done with dns.Default.
 func NewFilterSubnetsLayer(subnets []net.IPNet) (Layer, error) {
+	return NewFilterSubnetsLayerWithDNS(subnets, dns.Default)
+}
+
+// NewFilterSubnetsLayerWithDNS returns the same layer as
+// NewFilterSubnetsLayer but it uses a given DNS resolver. Please use
+// the same resolver as your dialer does, otherwise they can get
+// different IPs.
+func NewFilterSubnetsLayerWithDNS(subnets []net.IPNet, resolver dns.Interface) (Layer, error) {
 	instance := &filterSubnetsLayer{
-		v4: bool_tree.NewTreeV4(),
-		v6: bool_tree.NewTreeV6(),
+		v4:  bool_tree.NewTreeV4(),
+		v6:  bool_tree.NewTreeV6(),
+		dns: resolver,
 	}
 
 	for i := range subnets {
@@ -4,6 +4,8 @@ import (
 	"net"
 	"testing"
 
+	"github.com/9seconds/httransform/v2/cache"
+	"github.com/9seconds/httransform/v2/dns"
 	"github.com/9seconds/httransform/v2/layers"
 	"github.com/stretchr/testify/suite"
 )
@@ -37,6 +39,23 @@ func (suite *FilterSubnetLayerTestSuite) TestIPv6() {
 	suite.NoError(suite.l.OnRequest(suite.ctx))
 }
 
+func (suite *FilterSubnetLayerTestSuite) TestCustomDNS() {
+	resolver := dns.NewWithResolver(dns.NewHostsResolver(map[string][]string{
+		"internal.example": {"10.0.0.1"},
+	}, nil), dns.CacheSize, dns.CacheTTL, cache.NoopEvictCallback)
+
+	suite.l, _ = layers.NewFilterSubnetsLayerWithDNS([]net.IPNet{
+		{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)},
+	}, resolver)
+	suite.ctx.ConnectTo = "internal.example:443"
+
+	suite.Error(suite.l.OnRequest(suite.ctx))
+
+	suite.ctx.ConnectTo = "external.example:443"
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+}
+
 func TestFilterSubnetLayer(t *testing.T) {
 	suite.Run(t, &FilterSubnetLayerTestSuite{})
 }
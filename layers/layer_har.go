@@ -0,0 +1,474 @@
+package layers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/9seconds/httransform/v2/headers"
+	"github.com/valyala/fasthttp"
+)
+
+// HARLayerKeyRecord defines a key which is used in context to store
+// some internal data.
+const HARLayerKeyRecord = "har_layer__record"
+
+// HARLayer records requests and responses as HAR 1.2 entries and sends
+// them to the event stream as events.EventTypeHAREntry. Use
+// har.NewProcessorFactory to write them into files.
+//
+// Headers are recorded as a client sends and receives them so this
+// layer should be the first one in a list of layers. An entry is sent
+// when a response body is consumed by a client or the request fails.
+//
+// Bodies are recorded only if size limits are set. Response bodies
+// with gzip, deflate or br Content-Encoding are decoded if they are
+// recorded completely; such entries have har.Content.Decoded set.
+// Bodies which are not valid UTF-8 are encoded with base64.
+type HARLayer struct {
+	// RequestBodySize defines how many bytes of request body are
+	// recorded. 0 means that request bodies are not recorded.
+	RequestBodySize int
+
+	// ResponseBodySize defines how many bytes of response body are
+	// recorded. 0 means that response bodies are not recorded.
+	ResponseBodySize int
+}
+
+// OnRequest conforms Layer interface.
+func (h HARLayer) OnRequest(ctx *Context) error {
+	req := ctx.Request()
+	record := &harRecord{
+		eventStream:  ctx.EventStream,
+		requestID:    ctx.RequestID,
+		startedAt:    time.Now(),
+		responseSize: h.ResponseBodySize,
+		entry: &har.Entry{
+			RequestID: ctx.RequestID,
+			User:      ctx.User,
+			Cache:     har.Cache{},
+		},
+	}
+
+	if state := ctx.TLSConnectionState(); state != nil {
+		record.entry.TLS = &har.TLS{
+			Version:     harTLSVersion(state.Version),
+			CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+			ServerName:  state.ServerName,
+			ALPN:        state.NegotiatedProtocol,
+		}
+	}
+
+	harRequest := &record.entry.Request
+	harRequest.Method = string(req.Header.Method())
+	harRequest.URL = string(req.URI().FullURI())
+	harRequest.HTTPVersion = harHTTPVersion(record.entry.TLS, req.Header.Protocol())
+	harRequest.Headers = harHeaders(&ctx.RequestHeaders)
+	harRequest.Cookies = []har.Cookie{}
+	harRequest.QueryString = []har.NameValue{}
+	harRequest.HeadersSize = -1
+
+	req.Header.VisitAllCookie(func(key, value []byte) {
+		harRequest.Cookies = append(harRequest.Cookies, har.Cookie{
+			Name:  string(key),
+			Value: string(value),
+		})
+	})
+	req.URI().QueryArgs().VisitAll(func(key, value []byte) {
+		harRequest.QueryString = append(harRequest.QueryString, har.NameValue{
+			Name:  string(key),
+			Value: string(value),
+		})
+	})
+
+	if header := ctx.RequestHeaders.GetLast("Content-Type"); header != nil {
+		record.requestMimeType = header.Value()
+	}
+
+	record.requestBody = &harBody{
+		limit: h.RequestBodySize,
+	}
+
+	if !req.IsBodyStream() {
+		record.requestBody.Write(req.Body()) // nolint: errcheck
+		record.requestBody.done = true
+	} else {
+		contentLength := req.Header.ContentLength()
+		stealer := &bodyStealer{}
+
+		if err := req.BodyWriteTo(stealer); err != nil {
+			return fmt.Errorf("cannot read a request body: %w", err)
+		}
+
+		stream := stealer.getStream()
+
+		// see BodyTransformerLayer.OnRequest
+		if _, ok := stream.(BodyDetacher); !ok && stealer.stream != nil {
+			stream = newBodyTransformerRequestStream(stream, &req.Header, contentLength)
+		}
+
+		record.requestBody.reader = stream
+
+		req.SetBodyStream(record.requestBody, contentLength)
+	}
+
+	ctx.Set(HARLayerKeyRecord, record)
+
+	return nil
+}
+
+// OnResponse conforms Layer interface.
+func (h HARLayer) OnResponse(ctx *Context, err error) error {
+	value := ctx.Get(HARLayerKeyRecord)
+	if value == nil {
+		return err
+	}
+
+	ctx.Delete(HARLayerKeyRecord)
+
+	record := value.(*harRecord)
+	record.respondedAt = time.Now()
+	record.timings = ctx.Timings
+
+	if err != nil {
+		record.entry.Error = err.Error()
+		record.entry.Response = har.Response{
+			HTTPVersion: record.entry.Request.HTTPVersion,
+			Cookies:     []har.Cookie{},
+			Headers:     []har.NameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+
+		record.finish()
+
+		return err
+	}
+
+	resp := ctx.Response()
+	harResponse := &record.entry.Response
+	harResponse.Status = resp.StatusCode()
+	harResponse.StatusText = fasthttp.StatusMessage(resp.StatusCode())
+	harResponse.HTTPVersion = record.entry.Request.HTTPVersion
+	harResponse.Headers = harHeaders(&ctx.ResponseHeaders)
+	harResponse.Cookies = harSetCookies(&ctx.ResponseHeaders)
+	harResponse.HeadersSize = -1
+
+	if header := ctx.ResponseHeaders.GetLast("Location"); header != nil {
+		harResponse.RedirectURL = header.Value()
+	}
+
+	if header := ctx.ResponseHeaders.GetLast("Content-Type"); header != nil {
+		harResponse.Content.MimeType = header.Value()
+	}
+
+	if header := ctx.ResponseHeaders.GetLast("Content-Encoding"); header != nil {
+		record.responseEncoding = strings.ToLower(strings.TrimSpace(header.Value()))
+	}
+
+	record.responseBody = &harBody{
+		limit:  h.ResponseBodySize,
+		onDone: record.finish,
+	}
+
+	if !resp.IsBodyStream() {
+		record.responseBody.Write(resp.Body()) // nolint: errcheck
+		record.finish()
+
+		return nil
+	}
+
+	stealer := &bodyStealer{}
+	if err := resp.BodyWriteTo(stealer); err != nil {
+		record.finish()
+
+		return fmt.Errorf("cannot read a response body: %w", err)
+	}
+
+	record.responseBody.reader = stealer.getStream()
+
+	resp.SetBodyStream(record.responseBody, resp.Header.ContentLength())
+
+	return nil
+}
+
+// harRecord keeps a state of the entry between OnRequest, OnResponse
+// and the end of response body. Response body is consumed after
+// Context is released so it must not keep a reference to it.
+type harRecord struct {
+	entry            *har.Entry
+	eventStream      events.Stream
+	requestID        string
+	startedAt        time.Time
+	respondedAt      time.Time
+	requestBody      *harBody
+	requestMimeType  string
+	responseBody     *harBody
+	responseEncoding string
+	responseSize     int
+	timings          events.Timings
+	once             sync.Once
+}
+
+func (h *harRecord) finish() {
+	h.once.Do(func() {
+		finishedAt := time.Now()
+
+		h.entry.StartedDateTime = h.startedAt.Format(time.RFC3339Nano)
+		h.entry.Time = harMilliseconds(finishedAt.Sub(h.startedAt))
+		h.entry.Timings = har.Timings{
+			Blocked: -1,
+			DNS:     harOptionalMilliseconds(h.timings.DNS),
+			Connect: -1,
+			SSL:     harOptionalMilliseconds(h.timings.TLSHandshake),
+			Send:    harMilliseconds(h.timings.Send),
+			Wait:    harMilliseconds(h.respondedAt.Sub(h.startedAt)),
+			Receive: harMilliseconds(finishedAt.Sub(h.respondedAt)),
+		}
+
+		// HAR connect time includes TLS handshake.
+		if h.timings.Dial > 0 {
+			h.entry.Timings.Connect = harMilliseconds(h.timings.Dial + h.timings.TLSHandshake)
+		}
+
+		if h.timings.TimeToFirstByte > 0 {
+			h.entry.Timings.Wait = harMilliseconds(h.timings.TimeToFirstByte)
+		}
+
+		h.fillRequestBody()
+		h.fillResponseBody()
+
+		h.eventStream.Send(context.Background(), events.EventTypeHAREntry, h.entry, h.requestID)
+	})
+}
+
+func (h *harRecord) fillRequestBody() {
+	body := h.requestBody
+	h.entry.Request.BodySize = body.size
+
+	if body.size == 0 {
+		return
+	}
+
+	postData := &har.PostData{
+		MimeType:  h.requestMimeType,
+		Params:    []har.NameValue{},
+		Truncated: body.isTruncated(),
+	}
+	postData.Text, postData.Encoding = harEncodeText(body.buf.Bytes())
+
+	if !postData.Truncated && strings.HasPrefix(h.requestMimeType, "application/x-www-form-urlencoded") {
+		args := fasthttp.AcquireArgs()
+
+		args.ParseBytes(body.buf.Bytes())
+		args.VisitAll(func(key, value []byte) {
+			postData.Params = append(postData.Params, har.NameValue{
+				Name:  string(key),
+				Value: string(value),
+			})
+		})
+		fasthttp.ReleaseArgs(args)
+	}
+
+	h.entry.Request.PostData = postData
+}
+
+func (h *harRecord) fillResponseBody() {
+	body := h.responseBody
+	if body == nil {
+		return
+	}
+
+	content := &h.entry.Response.Content
+	data := body.buf.Bytes()
+
+	h.entry.Response.BodySize = body.size
+	content.Size = body.size
+	content.Truncated = body.isTruncated()
+
+	switch h.responseEncoding {
+	case "gzip", "x-gzip", "deflate", "br":
+		if content.Truncated {
+			break
+		}
+
+		// the cap is applied to decoded data as well to be protected
+		// against compression bombs.
+		decoded, err := ioutil.ReadAll(io.LimitReader(
+			bodyTransformerDecode(h.responseEncoding, bytes.NewReader(data)),
+			int64(h.responseSize)+1))
+		if err == nil && len(decoded) <= h.responseSize {
+			data = decoded
+			content.Decoded = true
+			content.Size = int64(len(decoded))
+			content.Compression = content.Size - body.size
+		}
+	}
+
+	content.Text, content.Encoding = harEncodeText(data)
+}
+
+// harBody is a body stream which counts passed bytes and keeps up to
+// limit of them.
+type harBody struct {
+	reader   io.Reader
+	limit    int
+	buf      bytes.Buffer
+	size     int64
+	done     bool
+	detached bool
+	onDone   func()
+}
+
+func (h *harBody) Read(p []byte) (int, error) {
+	n, err := h.reader.Read(p)
+
+	h.Write(p[:n]) // nolint: errcheck
+
+	if err == io.EOF {
+		h.done = true
+
+		if h.onDone != nil {
+			h.onDone()
+		}
+	}
+
+	return n, err // nolint: wrapcheck
+}
+
+func (h *harBody) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+
+	if room := h.limit - h.buf.Len(); room > 0 {
+		if len(p) > room {
+			h.buf.Write(p[:room])
+		} else {
+			h.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+// Detach conforms BodyDetacher interface.
+func (h *harBody) Detach() {
+	h.detached = true
+}
+
+func (h *harBody) Close() error {
+	if h.detached {
+		h.detached = false
+
+		return nil
+	}
+
+	if h.onDone != nil {
+		h.onDone()
+	}
+
+	if closer, ok := h.reader.(io.Closer); ok {
+		return closer.Close() // nolint: wrapcheck
+	}
+
+	return nil
+}
+
+func (h *harBody) isTruncated() bool {
+	return !h.done || int64(h.buf.Len()) < h.size
+}
+
+func harHeaders(hdrs *headers.Headers) []har.NameValue {
+	rv := make([]har.NameValue, 0, len(hdrs.Headers))
+
+	for i := range hdrs.Headers {
+		rv = append(rv, har.NameValue{
+			Name:  hdrs.Headers[i].Name(),
+			Value: hdrs.Headers[i].Value(),
+		})
+	}
+
+	return rv
+}
+
+func harSetCookies(hdrs *headers.Headers) []har.Cookie {
+	rv := []har.Cookie{}
+	cookie := fasthttp.AcquireCookie()
+
+	defer fasthttp.ReleaseCookie(cookie)
+
+	for _, header := range hdrs.GetAll("Set-Cookie") {
+		if err := cookie.Parse(header.Value()); err != nil {
+			continue
+		}
+
+		harCookie := har.Cookie{
+			Name:     string(cookie.Key()),
+			Value:    string(cookie.Value()),
+			Path:     string(cookie.Path()),
+			Domain:   string(cookie.Domain()),
+			HTTPOnly: cookie.HTTPOnly(),
+			Secure:   cookie.Secure(),
+		}
+
+		if expire := cookie.Expire(); expire != fasthttp.CookieExpireUnlimited {
+			harCookie.Expires = expire.Format(time.RFC3339)
+		}
+
+		rv = append(rv, harCookie)
+	}
+
+	return rv
+}
+
+func harHTTPVersion(tlsInfo *har.TLS, protocol []byte) string {
+	if tlsInfo != nil && tlsInfo.ALPN == "h2" {
+		return "HTTP/2.0"
+	}
+
+	return string(protocol)
+}
+
+func harTLSVersion(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+
+	return fmt.Sprintf("0x%04x", version)
+}
+
+func harEncodeText(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+func harMilliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func harOptionalMilliseconds(duration time.Duration) float64 {
+	if duration == 0 {
+		return -1
+	}
+
+	return harMilliseconds(duration)
+}
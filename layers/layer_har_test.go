@@ -0,0 +1,211 @@
+package layers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/har"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type LayerHARTestSuite struct {
+	BaseLayerTestSuite
+
+	entry *har.Entry
+}
+
+func (suite *LayerHARTestSuite) SetupTest() {
+	suite.BaseLayerTestSuite.SetupTest()
+
+	suite.entry = nil
+	suite.l = layers.HARLayer{
+		RequestBodySize:  1024,
+		ResponseBodySize: 1024,
+	}
+
+	suite.eventsChannel.
+		On("Send", mock.Anything, events.EventTypeHAREntry, mock.Anything, suite.ctx.RequestID).
+		Run(func(args mock.Arguments) {
+			suite.entry = args.Get(2).(*har.Entry)
+		}).
+		Once()
+}
+
+func (suite *LayerHARTestSuite) TestRequest() {
+	req := suite.ctx.Request()
+
+	req.Header.SetMethod("POST")
+	req.SetRequestURI("http://example.com/path?q=1")
+	req.Header.Set("Cookie", "session=xxx")
+	req.SetBodyString("a=1&b=2")
+
+	suite.ctx.RequestHeaders.Set("x-lowercase", "1", true)
+	suite.ctx.RequestHeaders.Set("Content-Type", "application/x-www-form-urlencoded", true)
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("POST", suite.entry.Request.Method)
+	suite.Equal("http://example.com/path?q=1", suite.entry.Request.URL)
+	suite.Equal("HTTP/1.1", suite.entry.Request.HTTPVersion)
+	suite.Equal("user", suite.entry.User)
+	suite.Equal([]har.NameValue{{Name: "q", Value: "1"}}, suite.entry.Request.QueryString)
+	suite.Equal([]har.Cookie{{Name: "session", Value: "xxx"}}, suite.entry.Request.Cookies)
+	suite.Contains(suite.entry.Request.Headers, har.NameValue{Name: "x-lowercase", Value: "1"})
+	suite.EqualValues(7, suite.entry.Request.BodySize)
+	suite.Require().NotNil(suite.entry.Request.PostData)
+	suite.Equal("a=1&b=2", suite.entry.Request.PostData.Text)
+	suite.Len(suite.entry.Request.PostData.Params, 2)
+	suite.False(suite.entry.Request.PostData.Truncated)
+}
+
+func (suite *LayerHARTestSuite) TestResponse() {
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+
+	suite.ctx.Response().SetStatusCode(302)
+	suite.ctx.Response().SetBodyString("hello")
+	suite.ctx.ResponseHeaders.Set("Location", "/other", true)
+	suite.ctx.ResponseHeaders.Set("Content-Type", "text/plain", true)
+	suite.ctx.ResponseHeaders.Append("Set-Cookie", "a=b; Path=/; HttpOnly")
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal(302, suite.entry.Response.Status)
+	suite.Equal("/other", suite.entry.Response.RedirectURL)
+	suite.Equal("text/plain", suite.entry.Response.Content.MimeType)
+	suite.Equal("hello", suite.entry.Response.Content.Text)
+	suite.EqualValues(5, suite.entry.Response.Content.Size)
+	suite.Equal([]har.Cookie{{Name: "a", Value: "b", Path: "/", HTTPOnly: true}}, suite.entry.Response.Cookies)
+	suite.Nil(suite.entry.Request.PostData)
+	suite.NotEmpty(suite.entry.StartedDateTime)
+	suite.EqualValues(-1, suite.entry.Timings.DNS)
+}
+
+func (suite *LayerHARTestSuite) TestResponseStream() {
+	buf := bytes.Buffer{}
+	writer := gzip.NewWriter(&buf)
+
+	writer.Write([]byte("hello"))
+	writer.Close()
+
+	compressedSize := buf.Len()
+	stream := &detachableStream{Reader: &buf}
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+
+	suite.ctx.Response().SetBodyStream(stream, -1)
+	suite.ctx.ResponseHeaders.Set("Content-Encoding", "gzip", true)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.Nil(suite.entry)
+	suite.False(stream.closed)
+
+	suite.Len(suite.ctx.Response().Body(), compressedSize)
+	suite.True(stream.closed)
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("hello", suite.entry.Response.Content.Text)
+	suite.EqualValues(5, suite.entry.Response.Content.Size)
+	suite.EqualValues(compressedSize, suite.entry.Response.BodySize)
+	suite.EqualValues(5-compressedSize, suite.entry.Response.Content.Compression)
+	suite.True(suite.entry.Response.Content.Decoded)
+}
+
+func (suite *LayerHARTestSuite) TestResponseNotDecoded() {
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+
+	suite.ctx.Response().SetBody([]byte("hello"))
+	suite.ctx.ResponseHeaders.Set("Content-Encoding", "gzip", true)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("hello", suite.entry.Response.Content.Text)
+	suite.False(suite.entry.Response.Content.Decoded)
+	suite.EqualValues(0, suite.entry.Response.Content.Compression)
+}
+
+func (suite *LayerHARTestSuite) TestResponseBodyTransformer() {
+	transformer := layers.BodyTransformerLayer{
+		Response: func(_ *layers.Context, body io.Reader) (io.Reader, error) {
+			return upperReader{body}, nil
+		},
+	}
+	stream := &detachableStream{Reader: strings.NewReader("hello")}
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.NoError(transformer.OnRequest(suite.ctx))
+
+	suite.ctx.Response().SetBodyStream(stream, 5)
+	suite.ctx.ResponseHeaders.Set("Content-Length", "5", true)
+
+	// transformed stream is detached, not buffered by HAR layer.
+	suite.NoError(transformer.OnResponse(suite.ctx, nil))
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.False(stream.closed)
+	suite.Nil(suite.entry)
+
+	suite.Equal("HELLO", string(suite.ctx.Response().Body()))
+	suite.True(stream.closed)
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("HELLO", suite.entry.Response.Content.Text)
+	suite.EqualValues(5, suite.entry.Response.BodySize)
+}
+
+func (suite *LayerHARTestSuite) TestTruncated() {
+	suite.l = layers.HARLayer{
+		ResponseBodySize: 3,
+	}
+
+	suite.ctx.Request().SetBodyString("hello")
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+
+	suite.ctx.Response().SetBodyStream(&detachableStream{Reader: strings.NewReader("hello")}, 5)
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.Equal("hello", string(suite.ctx.Response().Body()))
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("", suite.entry.Request.PostData.Text)
+	suite.True(suite.entry.Request.PostData.Truncated)
+	suite.Equal("hel", suite.entry.Response.Content.Text)
+	suite.True(suite.entry.Response.Content.Truncated)
+	suite.EqualValues(5, suite.entry.Response.BodySize)
+}
+
+func (suite *LayerHARTestSuite) TestBinary() {
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+
+	suite.ctx.Response().SetBody([]byte{0xff, 0xfe})
+
+	suite.NoError(suite.l.OnResponse(suite.ctx, nil))
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("//4=", suite.entry.Response.Content.Text)
+	suite.Equal("base64", suite.entry.Response.Content.Encoding)
+}
+
+func (suite *LayerHARTestSuite) TestError() {
+	err := errors.New("unexpected")
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.Equal(err, suite.l.OnResponse(suite.ctx, err))
+	suite.Require().NotNil(suite.entry)
+
+	suite.Equal("unexpected", suite.entry.Error)
+	suite.Equal(0, suite.entry.Response.Status)
+}
+
+func TestLayerHAR(t *testing.T) {
+	suite.Run(t, &LayerHARTestSuite{})
+}
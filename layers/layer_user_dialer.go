@@ -0,0 +1,45 @@
+package layers
+
+import "github.com/9seconds/httransform/v2/dialers"
+
+// UserDialerLayer defines a layer which routes requests of different
+// users through different dialers. For example, you can send requests
+// of some users through a dedicated proxy with dialers.DialerFromURL.
+//
+// This layer sets Context.Dialer so executors of executor package
+// use a chosen dialer instead of the one they were created with.
+// It also conforms TunnelDialerLayer so tunnels which are not
+// intercepted are routed the same way.
+type UserDialerLayer struct {
+	// Dialers maps usernames to dialers.
+	Dialers map[string]dialers.Dialer
+
+	// Default is a dialer for users which are absent in Dialers. If it
+	// is nil, executor uses its own dialer for such users.
+	Default dialers.Dialer
+}
+
+// OnRequest conforms Layer interface.
+func (u UserDialerLayer) OnRequest(ctx *Context) error {
+	if dialer, ok := u.Dialers[ctx.User]; ok {
+		ctx.Dialer = dialer
+	} else if u.Default != nil {
+		ctx.Dialer = u.Default
+	}
+
+	return nil
+}
+
+// TunnelDialer conforms TunnelDialerLayer interface.
+func (u UserDialerLayer) TunnelDialer(user, _ string) dialers.Dialer {
+	if dialer, ok := u.Dialers[user]; ok {
+		return dialer
+	}
+
+	return u.Default
+}
+
+// OnResponse conforms Layer interface.
+func (u UserDialerLayer) OnResponse(_ *Context, err error) error {
+	return err
+}
@@ -0,0 +1,74 @@
+package layers_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/suite"
+)
+
+type LayerUserDialerTestSuite struct {
+	BaseLayerTestSuite
+
+	userDialer    dialers.Dialer
+	defaultDialer dialers.Dialer
+}
+
+func (suite *LayerUserDialerTestSuite) SetupTest() {
+	suite.BaseLayerTestSuite.SetupTest()
+
+	suite.userDialer = dialers.NewBase(dialers.Opts{})
+	suite.defaultDialer = dialers.NewBase(dialers.Opts{})
+	suite.l = layers.UserDialerLayer{
+		Dialers: map[string]dialers.Dialer{
+			"user": suite.userDialer,
+		},
+	}
+}
+
+func (suite *LayerUserDialerTestSuite) TestKnownUser() {
+	suite.ctx.User = "user"
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.Equal(suite.userDialer, suite.ctx.Dialer)
+}
+
+func (suite *LayerUserDialerTestSuite) TestUnknownUser() {
+	suite.ctx.User = "unknown"
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.Nil(suite.ctx.Dialer)
+}
+
+func (suite *LayerUserDialerTestSuite) TestDefault() {
+	suite.l = layers.UserDialerLayer{
+		Default: suite.defaultDialer,
+	}
+	suite.ctx.User = "unknown"
+
+	suite.NoError(suite.l.OnRequest(suite.ctx))
+	suite.Equal(suite.defaultDialer, suite.ctx.Dialer)
+}
+
+func (suite *LayerUserDialerTestSuite) TestTunnelDialer() {
+	layer := suite.l.(layers.TunnelDialerLayer)
+
+	suite.Equal(suite.userDialer, layer.TunnelDialer("user", "example.com:443"))
+	suite.Nil(layer.TunnelDialer("unknown", "example.com:443"))
+
+	layer = layers.UserDialerLayer{
+		Default: suite.defaultDialer,
+	}
+
+	suite.Equal(suite.defaultDialer, layer.TunnelDialer("unknown", "example.com:443"))
+}
+
+func (suite *LayerUserDialerTestSuite) TestOnResponse() {
+	suite.Equal(io.EOF, suite.l.OnResponse(suite.ctx, io.EOF))
+}
+
+func TestLayerUserDialer(t *testing.T) {
+	suite.Run(t, &LayerUserDialerTestSuite{})
+}
@@ -0,0 +1,303 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/9seconds/httransform/v2/cache"
+)
+
+const (
+	contentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Collector merges metrics of all processors and exposes them in
+// Prometheus text format. It implements http.Handler so it can be
+// mounted to any HTTP server.
+type Collector struct {
+	namespace string
+	bounds    []float64
+	finished  cache.Interface
+	mutex     sync.Mutex
+	shards    []*shard
+}
+
+// ServeHTTP conforms http.Handler interface. If client accepts
+// OpenMetrics, it is returned instead of Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+
+	if openMetrics {
+		w.Header().Set("Content-Type", contentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", contentTypeText)
+	}
+
+	c.write(w, openMetrics) // nolint: errcheck
+}
+
+// WriteTo writes metrics in Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	return c.write(w, false)
+}
+
+func (c *Collector) write(w io.Writer, openMetrics bool) (int64, error) {
+	snapshot := c.snapshot()
+	writer := &expositionWriter{
+		writer:      bufio.NewWriter(w),
+		namespace:   c.namespace,
+		openMetrics: openMetrics,
+	}
+
+	requests := make([]sample, 0, len(snapshot.requests))
+	for k, v := range snapshot.requests {
+		requests = append(requests, sample{
+			labels: []string{"user", k.user, "method", k.method, "status_class", k.statusClass},
+			value:  float64(v),
+		})
+	}
+
+	writer.counter("requests", "Number of finished requests.", requests)
+
+	errs := make([]sample, 0, len(snapshot.errors))
+	for k, v := range snapshot.errors {
+		errs = append(errs, sample{
+			labels: []string{"user", k.user, "method", k.method, "code", k.code},
+			value:  float64(v),
+		})
+	}
+
+	writer.counter("request_errors", "Number of failed requests by error code.", errs)
+
+	traffic := make([]sample, 0, len(snapshot.traffic))
+	for k, v := range snapshot.traffic {
+		traffic = append(traffic, sample{
+			labels: []string{"user", k.user, "direction", k.direction},
+			value:  float64(v),
+		})
+	}
+
+	writer.counter("traffic_bytes", "Number of bytes read from and written to netlocs.", traffic)
+
+	writer.counter("auth_failures", "Number of failed authentications.",
+		[]sample{{value: float64(snapshot.authFailures)}})
+	writer.counter("common_errors", "Number of errors of HTTP server.",
+		[]sample{{value: float64(snapshot.commonErrors)}})
+	writer.counter("certificates_generated", "Number of generated TLS certificates.",
+		[]sample{{value: float64(snapshot.certsGenerated)}})
+	writer.counter("certificates_dropped", "Number of evicted TLS certificates.",
+		[]sample{{value: float64(snapshot.certsDropped)}})
+	writer.gauge("requests_in_flight", "Number of requests which are processed now.",
+		[]sample{{value: float64(snapshot.inFlight)}})
+
+	durations := make([]histogramSample, 0, len(snapshot.durations))
+	for k, v := range snapshot.durations {
+		durations = append(durations, histogramSample{
+			labels:    []string{"method", k.method, "status_class", k.statusClass},
+			histogram: v,
+		})
+	}
+
+	writer.histogram("request_duration_seconds", "Duration of requests.", c.bounds, durations)
+
+	if openMetrics {
+		writer.line("# EOF")
+	}
+
+	return writer.finish()
+}
+
+func (c *Collector) snapshot() *shard {
+	target := newShard(c.bounds)
+
+	c.mutex.Lock()
+	shards := c.shards
+	c.mutex.Unlock()
+
+	for _, v := range shards {
+		v.mergeTo(target)
+	}
+
+	return target
+}
+
+func (c *Collector) newShard() *shard {
+	rv := newShard(c.bounds)
+
+	c.mutex.Lock()
+	c.shards = append(c.shards, rv)
+	c.mutex.Unlock()
+
+	return rv
+}
+
+// NewCollector returns a new Collector. Use NewProcessorFactory to
+// feed it with events.
+//
+// Metrics are labeled by user so please pay attention to a
+// cardinality if you have many users.
+func NewCollector(opts Opts) *Collector {
+	bounds := append([]float64{}, opts.GetDurationBuckets()...)
+
+	sort.Float64s(bounds)
+
+	return &Collector{
+		namespace: opts.GetNamespace(),
+		bounds:    bounds,
+		finished:  cache.New(TrafficUserCacheSize, TrafficUserCacheTTL, cache.NoopEvictCallback),
+	}
+}
+
+type sample struct {
+	// labels are name-value pairs.
+	labels []string
+	value  float64
+}
+
+type histogramSample struct {
+	labels    []string
+	histogram *histogram
+}
+
+type expositionWriter struct {
+	writer      *bufio.Writer
+	namespace   string
+	openMetrics bool
+	written     int64
+	err         error
+}
+
+func (e *expositionWriter) counter(name, help string, samples []sample) {
+	name = e.namespace + "_" + name
+
+	// OpenMetrics names counter families without _total suffix.
+	if e.openMetrics {
+		e.header(name, help, "counter")
+	} else {
+		e.header(name+"_total", help, "counter")
+	}
+
+	e.samples(name+"_total", samples)
+}
+
+func (e *expositionWriter) gauge(name, help string, samples []sample) {
+	name = e.namespace + "_" + name
+
+	e.header(name, help, "gauge")
+	e.samples(name, samples)
+}
+
+func (e *expositionWriter) histogram(name, help string, bounds []float64, samples []histogramSample) {
+	name = e.namespace + "_" + name
+
+	sort.Slice(samples, func(i, j int) bool {
+		return labelsLess(samples[i].labels, samples[j].labels)
+	})
+
+	e.header(name, help, "histogram")
+
+	for _, v := range samples {
+		cumulative := uint64(0)
+
+		for i, count := range v.histogram.counts {
+			cumulative += count
+			le := "+Inf"
+
+			if i < len(bounds) {
+				le = formatFloat(bounds[i])
+			}
+
+			e.sample(name+"_bucket", append(append([]string{}, v.labels...), "le", le), float64(cumulative))
+		}
+
+		e.sample(name+"_sum", v.labels, v.histogram.sum)
+		e.sample(name+"_count", v.labels, float64(v.histogram.count))
+	}
+}
+
+func (e *expositionWriter) header(name, help, metricType string) {
+	e.line("# HELP " + name + " " + help)
+	e.line("# TYPE " + name + " " + metricType)
+}
+
+func (e *expositionWriter) samples(name string, samples []sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return labelsLess(samples[i].labels, samples[j].labels)
+	})
+
+	for _, v := range samples {
+		e.sample(name, v.labels, v.value)
+	}
+}
+
+func (e *expositionWriter) sample(name string, labels []string, value float64) {
+	builder := strings.Builder{}
+
+	builder.WriteString(name)
+
+	if len(labels) > 0 {
+		builder.WriteByte('{')
+
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+
+			builder.WriteString(labels[i])
+			builder.WriteString(`="`)
+			builder.WriteString(escapeLabelValue(labels[i+1]))
+			builder.WriteByte('"')
+		}
+
+		builder.WriteByte('}')
+	}
+
+	builder.WriteByte(' ')
+	builder.WriteString(formatFloat(value))
+
+	e.line(builder.String())
+}
+
+func (e *expositionWriter) line(text string) {
+	if e.err != nil {
+		return
+	}
+
+	n, err := e.writer.WriteString(text + "\n")
+	e.written += int64(n)
+	e.err = err
+}
+
+func (e *expositionWriter) finish() (int64, error) {
+	if e.err == nil {
+		e.err = e.writer.Flush()
+	}
+
+	return e.written, e.err
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64) // nolint: gomnd
+}
+
+func labelsLess(left, right []string) bool {
+	for i := 0; i < len(left) && i < len(right); i++ {
+		if left[i] != right[i] {
+			return left[i] < right[i]
+		}
+	}
+
+	return len(left) < len(right)
+}
@@ -0,0 +1,217 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/metrics"
+	"github.com/stretchr/testify/suite"
+)
+
+type CollectorTestSuite struct {
+	suite.Suite
+
+	collector *metrics.Collector
+	factory   events.ProcessorFactory
+	now       time.Time
+}
+
+func (suite *CollectorTestSuite) SetupTest() {
+	suite.collector = metrics.NewCollector(metrics.Opts{
+		Namespace:       "test",
+		DurationBuckets: []float64{1, 0.1},
+	})
+	suite.factory = metrics.NewProcessorFactory(suite.collector)
+	suite.now = time.Now()
+}
+
+func (suite *CollectorTestSuite) event(eventType events.EventType, value interface{}, offset time.Duration) events.Event {
+	return events.Event{
+		Type:  eventType,
+		Time:  suite.now.Add(offset),
+		Value: value,
+	}
+}
+
+func (suite *CollectorTestSuite) request(proc events.Processor, id, user string, statusCode int, err error) {
+	proc.Process(suite.event(events.EventTypeStartRequest, &events.RequestMeta{
+		RequestID: id,
+		Method:    "GET",
+		User:      user,
+	}, 0))
+
+	if err != nil {
+		proc.Process(suite.event(events.EventTypeFailedRequest, &events.ErrorMeta{
+			RequestID: id,
+			Err:       err,
+		}, 0))
+	}
+
+	proc.Process(suite.event(events.EventTypeFinishRequest, &events.ResponseMeta{
+		RequestID:  id,
+		StatusCode: statusCode,
+	}, 500*time.Millisecond))
+}
+
+func (suite *CollectorTestSuite) scrape(accept string) (string, string) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	resp := httptest.NewRecorder()
+
+	req.Header.Set("Accept", accept)
+	suite.collector.ServeHTTP(resp, req)
+
+	return resp.Body.String(), resp.Header().Get("Content-Type")
+}
+
+func (suite *CollectorTestSuite) TestRequests() {
+	proc1 := suite.factory()
+	proc2 := suite.factory()
+
+	suite.request(proc1, "1", "user", 200, nil)
+	suite.request(proc2, "2", "user", 204, nil)
+	suite.request(proc2, "3", "other", 502, &errors.Error{
+		Message: "cannot dial",
+		Err:     &errors.Error{Code: "dial"},
+	})
+	suite.request(proc2, "4", "other", 500, errors.New("unexpected"))
+
+	body, contentType := suite.scrape("")
+
+	suite.Contains(contentType, "text/plain")
+	suite.Contains(body, "# TYPE test_requests_total counter\n")
+	suite.Contains(body, `test_requests_total{user="user",method="GET",status_class="2xx"} 2`)
+	suite.Contains(body, `test_requests_total{user="other",method="GET",status_class="5xx"} 2`)
+	suite.Contains(body, `test_request_errors_total{user="other",method="GET",code="dial"} 1`)
+	suite.Contains(body, `test_request_errors_total{user="other",method="GET",code="internal_error"} 1`)
+	suite.Contains(body, `test_request_duration_seconds_bucket{method="GET",status_class="2xx",le="0.1"} 0`)
+	suite.Contains(body, `test_request_duration_seconds_bucket{method="GET",status_class="2xx",le="1"} 2`)
+	suite.Contains(body, `test_request_duration_seconds_bucket{method="GET",status_class="2xx",le="+Inf"} 2`)
+	suite.Contains(body, `test_request_duration_seconds_sum{method="GET",status_class="2xx"} 1`)
+	suite.Contains(body, `test_request_duration_seconds_count{method="GET",status_class="2xx"} 2`)
+	suite.Contains(body, "test_requests_in_flight 0\n")
+	suite.NotContains(body, "# EOF")
+}
+
+func (suite *CollectorTestSuite) TestInFlight() {
+	proc := suite.factory()
+
+	proc.Process(suite.event(events.EventTypeStartRequest, &events.RequestMeta{
+		RequestID: "1",
+	}, 0))
+
+	body, _ := suite.scrape("")
+
+	suite.Contains(body, "test_requests_in_flight 1\n")
+
+	proc.Shutdown()
+
+	body, _ = suite.scrape("")
+
+	suite.Contains(body, "test_requests_in_flight 0\n")
+}
+
+func (suite *CollectorTestSuite) TestLostFinish() {
+	proc := suite.factory()
+
+	proc.Process(suite.event(events.EventTypeStartRequest, &events.RequestMeta{
+		RequestID: "1",
+		Method:    "GET",
+	}, 0))
+	proc.Process(suite.event(events.EventTypeFinishRequest, &events.ResponseMeta{
+		RequestID:  "1",
+		StatusCode: 200,
+	}, metrics.RequestStateTTL+time.Minute))
+
+	body, _ := suite.scrape("")
+
+	suite.Contains(body, "test_requests_in_flight 0\n")
+	suite.NotContains(body, "test_requests_total{")
+}
+
+func (suite *CollectorTestSuite) TestUnknownMethod() {
+	proc := suite.factory()
+
+	for _, method := range []string{"PROPFIND", "get"} {
+		proc.Process(suite.event(events.EventTypeStartRequest, &events.RequestMeta{
+			RequestID: method,
+			Method:    method,
+		}, 0))
+		proc.Process(suite.event(events.EventTypeFinishRequest, &events.ResponseMeta{
+			RequestID:  method,
+			StatusCode: 200,
+		}, time.Second))
+	}
+
+	body, _ := suite.scrape("")
+
+	suite.Contains(body, `test_requests_total{user="",method="OTHER",status_class="2xx"} 2`)
+}
+
+func (suite *CollectorTestSuite) TestTraffic() {
+	proc := suite.factory()
+
+	proc.Process(suite.event(events.EventTypeStartRequest, &events.RequestMeta{
+		RequestID: "1",
+		User:      `us"er`,
+	}, 0))
+	proc.Process(suite.event(events.EventTypeTraffic, &events.TrafficMeta{
+		ID:           "1",
+		ReadBytes:    10,
+		WrittenBytes: 20,
+	}, 0))
+	proc.Process(suite.event(events.EventTypeTraffic, &events.TrafficMeta{
+		ID:        "unknown",
+		ReadBytes: 5,
+	}, 0))
+
+	body, _ := suite.scrape("")
+
+	suite.Contains(body, `test_traffic_bytes_total{user="us\"er",direction="read"} 10`)
+	suite.Contains(body, `test_traffic_bytes_total{user="us\"er",direction="written"} 20`)
+	suite.Contains(body, `test_traffic_bytes_total{user="",direction="read"} 5`)
+}
+
+func (suite *CollectorTestSuite) TestCommon() {
+	proc := suite.factory()
+
+	proc.Process(suite.event(events.EventTypeFailedAuth, nil, 0))
+	proc.Process(suite.event(events.EventTypeCommonError, &events.CommonErrorMeta{}, 0))
+	proc.Process(suite.event(events.EventTypeNewCertificate, "example.com", 0))
+	proc.Process(suite.event(events.EventTypeNewCertificate, "example.org", 0))
+	proc.Process(suite.event(events.EventTypeDropCertificate, "example.com", 0))
+
+	body, _ := suite.scrape("")
+
+	suite.Contains(body, "test_auth_failures_total 1\n")
+	suite.Contains(body, "test_common_errors_total 1\n")
+	suite.Contains(body, "test_certificates_generated_total 2\n")
+	suite.Contains(body, "test_certificates_dropped_total 1\n")
+}
+
+func (suite *CollectorTestSuite) TestOpenMetrics() {
+	body, contentType := suite.scrape("application/openmetrics-text; version=1.0.0")
+
+	suite.Contains(contentType, "application/openmetrics-text")
+	suite.Contains(body, "# TYPE test_auth_failures counter\n")
+	suite.Contains(body, "test_auth_failures_total 0\n")
+	suite.True(strings.HasSuffix(body, "# EOF\n"))
+}
+
+func (suite *CollectorTestSuite) TestWriteTo() {
+	builder := strings.Builder{}
+
+	n, err := suite.collector.WriteTo(&builder)
+
+	suite.NoError(err)
+	suite.EqualValues(builder.Len(), n)
+	suite.Contains(builder.String(), "# TYPE test_request_duration_seconds histogram\n")
+}
+
+func TestCollector(t *testing.T) {
+	suite.Run(t, &CollectorTestSuite{})
+}
@@ -0,0 +1,21 @@
+// Metrics of the proxy built on event stream.
+//
+// This package has a processor of event stream which turns events
+// into Prometheus metrics: number and duration of requests, errors,
+// traffic, failed authentications and TLS certificates.
+//
+//     collector := metrics.NewCollector(metrics.Opts{})
+//
+//     opts := httransform.ServerOpts{
+//         EventProcessorFactory: metrics.NewProcessorFactory(collector),
+//     }
+//
+//     http.Handle("/metrics", collector)
+//
+// Each processor of event stream keeps its own set of metrics so they
+// do not contend with each other. Collector merges them when metrics
+// are scraped.
+//
+// Collector is http.Handler which serves Prometheus text format or
+// OpenMetrics if a client asks for it.
+package metrics
@@ -0,0 +1,62 @@
+package metrics
+
+import "time"
+
+const (
+	// DefaultNamespace defines a prefix of metric names if user
+	// provides no value.
+	DefaultNamespace = "httransform"
+
+	// TrafficUserCacheSize defines how many finished requests are
+	// remembered to label their traffic with a user. Traffic of pooled
+	// connections is reported when connection is closed, long after
+	// request is finished.
+	TrafficUserCacheSize = 10000
+
+	// TrafficUserCacheTTL defines for how long finished requests are
+	// remembered.
+	TrafficUserCacheTTL = 5 * time.Minute
+
+	// RequestStateTTL defines for how long a request is waited to be
+	// finished. If its finish event is lost (for example, it is
+	// dropped by overloaded event stream), request is removed from
+	// in-flight ones after this time and is not counted.
+	RequestStateTTL = time.Hour
+
+	// requestStateSweepInterval defines how often processor looks for
+	// expired requests.
+	requestStateSweepInterval = time.Minute
+)
+
+// DefaultDurationBuckets defines buckets of request duration
+// histogram in seconds if user provides no value.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Opts defines a set of options for Collector.
+type Opts struct {
+	// Namespace is a prefix of all metric names.
+	Namespace string
+
+	// DurationBuckets defines upper bounds of request duration
+	// histogram buckets in seconds. They have to be sorted.
+	DurationBuckets []float64
+}
+
+// GetNamespace returns a namespace or fallbacks to default one.
+func (o *Opts) GetNamespace() string {
+	if o.Namespace == "" {
+		return DefaultNamespace
+	}
+
+	return o.Namespace
+}
+
+// GetDurationBuckets returns duration buckets or fallbacks to default
+// ones.
+func (o *Opts) GetDurationBuckets() []float64 {
+	if len(o.DurationBuckets) == 0 {
+		return DefaultDurationBuckets
+	}
+
+	return o.DurationBuckets
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+)
+
+type requestState struct {
+	user      string
+	method    string
+	errorCode string
+	startedAt int64
+}
+
+type processor struct {
+	collector *Collector
+	shard     *shard
+	requests  map[string]*requestState
+	sweptAt   int64
+}
+
+func (p *processor) Process(evt events.Event) { // nolint: cyclop
+	p.expireRequests(evt.Time.UnixNano())
+
+	switch value := evt.Value.(type) {
+	case *events.RequestMeta:
+		if evt.Type == events.EventTypeStartRequest {
+			p.requests[value.RequestID] = &requestState{
+				user:      value.User,
+				method:    normalizeMethod(value.Method),
+				startedAt: evt.Time.UnixNano(),
+			}
+
+			p.shard.mutex.Lock()
+			p.shard.inFlight++
+			p.shard.mutex.Unlock()
+		}
+	case *events.ErrorMeta:
+		if state, ok := p.requests[value.RequestID]; ok && evt.Type == events.EventTypeFailedRequest {
+			state.errorCode = errorCode(value.Err)
+		}
+	case *events.ResponseMeta:
+		if evt.Type == events.EventTypeFinishRequest {
+			p.finishRequest(value, evt.Time.UnixNano())
+		}
+	case *events.TrafficMeta:
+		if evt.Type == events.EventTypeTraffic {
+			p.addTraffic(value)
+		}
+	default:
+		p.processCommon(evt.Type)
+	}
+}
+
+func (p *processor) processCommon(eventType events.EventType) {
+	p.shard.mutex.Lock()
+	defer p.shard.mutex.Unlock()
+
+	switch eventType { // nolint: exhaustive
+	case events.EventTypeFailedAuth:
+		p.shard.authFailures++
+	case events.EventTypeCommonError:
+		p.shard.commonErrors++
+	case events.EventTypeNewCertificate:
+		p.shard.certsGenerated++
+	case events.EventTypeDropCertificate:
+		p.shard.certsDropped++
+	}
+}
+
+func (p *processor) finishRequest(meta *events.ResponseMeta, finishedAt int64) {
+	state, ok := p.requests[meta.RequestID]
+	if !ok {
+		return
+	}
+
+	delete(p.requests, meta.RequestID)
+	p.collector.finished.Add(meta.RequestID, state.user)
+
+	class := statusClass(meta.StatusCode)
+
+	p.shard.mutex.Lock()
+	defer p.shard.mutex.Unlock()
+
+	p.shard.inFlight--
+	p.shard.requests[requestKey{
+		user:        state.user,
+		method:      state.method,
+		statusClass: class,
+	}]++
+	p.shard.observeDuration(durationKey{
+		method:      state.method,
+		statusClass: class,
+	}, float64(finishedAt-state.startedAt)/1e9) // nolint: gomnd
+
+	if state.errorCode != "" {
+		p.shard.errors[errorKey{
+			user:   state.user,
+			method: state.method,
+			code:   state.errorCode,
+		}]++
+	}
+}
+
+// expireRequests drops requests which are not finished for
+// RequestStateTTL. Event time is used as a clock so states are checked
+// only if processor gets events.
+func (p *processor) expireRequests(now int64) {
+	if now-p.sweptAt < int64(requestStateSweepInterval) {
+		return
+	}
+
+	p.sweptAt = now
+	deadline := now - int64(RequestStateTTL)
+	expired := int64(0)
+
+	for requestID, state := range p.requests {
+		if state.startedAt < deadline {
+			delete(p.requests, requestID)
+
+			expired++
+		}
+	}
+
+	if expired > 0 {
+		p.shard.mutex.Lock()
+		p.shard.inFlight -= expired
+		p.shard.mutex.Unlock()
+	}
+}
+
+func (p *processor) addTraffic(meta *events.TrafficMeta) {
+	user := ""
+
+	if state, ok := p.requests[meta.ID]; ok {
+		user = state.user
+	} else if value, ok := p.collector.finished.Get(meta.ID).(string); ok {
+		user = value
+	}
+
+	p.shard.mutex.Lock()
+	defer p.shard.mutex.Unlock()
+
+	p.shard.traffic[trafficKey{user: user, direction: "read"}] += meta.ReadBytes
+	p.shard.traffic[trafficKey{user: user, direction: "written"}] += meta.WrittenBytes
+}
+
+func (p *processor) Shutdown() {
+	p.shard.mutex.Lock()
+	defer p.shard.mutex.Unlock()
+
+	// requests which are not finished are never going to be.
+	p.shard.inFlight -= int64(len(p.requests))
+}
+
+// normalizeMethod returns a method for labels. Clients can send any
+// method so unknown ones share a single label value to keep a number
+// of series bounded.
+func normalizeMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH":
+		return method
+	}
+
+	return "OTHER"
+}
+
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(statusCode/100) + "xx" // nolint: gomnd
+}
+
+func errorCode(err error) string {
+	var customErr *errors.Error
+
+	if errors.As(err, &customErr) {
+		return customErr.GetChainCode()
+	}
+
+	return errors.DefaultChainErrorCode
+}
+
+// NewProcessorFactory returns a factory of processors which collect
+// metrics into a given collector. Each processor keeps its own set of
+// metrics so they do not contend with each other. Collector merges
+// them on scrape.
+func NewProcessorFactory(collector *Collector) events.ProcessorFactory {
+	return func() events.Processor {
+		return &processor{
+			collector: collector,
+			shard:     collector.newShard(),
+			requests:  map[string]*requestState{},
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+type requestKey struct {
+	user        string
+	method      string
+	statusClass string
+}
+
+type errorKey struct {
+	user   string
+	method string
+	code   string
+}
+
+type durationKey struct {
+	method      string
+	statusClass string
+}
+
+type trafficKey struct {
+	user      string
+	direction string
+}
+
+type histogram struct {
+	// counts are not cumulative, the last one is +Inf bucket.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(bounds []float64, value float64) {
+	idx := sort.SearchFloat64s(bounds, value)
+
+	h.counts[idx]++
+	h.count++
+	h.sum += value
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i, v := range other.counts {
+		h.counts[i] += v
+	}
+
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// shard keeps metrics of a single processor. Processors update their
+// shards independently so there is a contention only with scrapes.
+type shard struct {
+	mutex          sync.Mutex
+	bounds         []float64
+	requests       map[requestKey]uint64
+	errors         map[errorKey]uint64
+	durations      map[durationKey]*histogram
+	traffic        map[trafficKey]uint64
+	inFlight       int64
+	authFailures   uint64
+	commonErrors   uint64
+	certsGenerated uint64
+	certsDropped   uint64
+}
+
+func (s *shard) observeDuration(key durationKey, value float64) {
+	hist, ok := s.durations[key]
+	if !ok {
+		hist = &histogram{
+			counts: make([]uint64, len(s.bounds)+1),
+		}
+		s.durations[key] = hist
+	}
+
+	hist.observe(s.bounds, value)
+}
+
+func (s *shard) mergeTo(target *shard) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for k, v := range s.requests {
+		target.requests[k] += v
+	}
+
+	for k, v := range s.errors {
+		target.errors[k] += v
+	}
+
+	for k, v := range s.traffic {
+		target.traffic[k] += v
+	}
+
+	for k, v := range s.durations {
+		if hist, ok := target.durations[k]; ok {
+			hist.merge(v)
+		} else {
+			hist := &histogram{
+				counts: make([]uint64, len(v.counts)),
+			}
+			hist.merge(v)
+			target.durations[k] = hist
+		}
+	}
+
+	target.inFlight += s.inFlight
+	target.authFailures += s.authFailures
+	target.commonErrors += s.commonErrors
+	target.certsGenerated += s.certsGenerated
+	target.certsDropped += s.certsDropped
+}
+
+func newShard(bounds []float64) *shard {
+	return &shard{
+		bounds:    bounds,
+		requests:  map[requestKey]uint64{},
+		errors:    map[errorKey]uint64{},
+		durations: map[durationKey]*histogram{},
+		traffic:   map[trafficKey]uint64{},
+	}
+}
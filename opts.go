@@ -4,9 +4,12 @@ import (
 	"time"
 
 	"github.com/9seconds/httransform/v2/auth"
+	"github.com/9seconds/httransform/v2/ca"
+	"github.com/9seconds/httransform/v2/dialers"
 	"github.com/9seconds/httransform/v2/events"
 	"github.com/9seconds/httransform/v2/executor"
 	"github.com/9seconds/httransform/v2/layers"
+	"github.com/9seconds/httransform/v2/tracing"
 )
 
 const (
@@ -71,6 +74,17 @@ type ServerOpts struct {
 	// event processors.
 	EventProcessorFactory events.ProcessorFactory
 
+	// EventStreamOpts defines buffering of events and what to do if
+	// processors are slower than requests. By default, each shard
+	// buffers a single event and slow processor blocks request
+	// processing.
+	EventStreamOpts events.StreamOpts
+
+	// Tracer defines a tracer which starts spans for each request,
+	// its layers and dial/TLS/execute phases. Default is a tracer
+	// which records nothing.
+	Tracer tracing.Tracer
+
 	// TLSCertCA is a bytes which contains TLS CA certificate. This
 	// certificate is required for generating fake TLS certifiates for
 	// websites on TLS connection upgrades.
@@ -81,21 +95,65 @@ type ServerOpts struct {
 	// websites on TLS connection upgrades.
 	TLSPrivateKey []byte
 
+	// TLSCAOpts defines options of certificate generation: storage of
+	// certificates, key algorithm, validity and so on. If you want
+	// certificates to survive restarts or to be shared between several
+	// proxy instances, please use a persistent store like
+	// ca.NewDirCertStore.
+	TLSCAOpts ca.Opts
+
 	// Layers defines a list of layers, middleware which should be used
 	// by proxy.
 	Layers []layers.Layer
 
 	// Executor defines an executor function which should be used
 	// to terminate HTTP request and fill HTTP response.
+	//
+	// If it is not set, executor.MakeDefaultExecutorWithPool is used
+	// with a connection pool which lives as long as the server. If it is
+	// set, it is used as is and no pool is created: please pass an
+	// executor with executor.ConnPool if you need keep-alive connections
+	// to netlocs.
 	Executor executor.Executor
 
 	// Authenticator is an interface which is used to authenticate a
 	// request.
 	Authenticator auth.Interface
 
+	// Dialer defines a dialer which is used to connect to netlocs by
+	// default executor and to fetch their certificates if
+	// TLSMimicUpstream is set. By default dialers.NewBase is used.
+	Dialer dialers.Dialer
+
 	// TLSSkipVerify defines if we need to verify TLS certifiates we have
 	// to deal with.
 	TLSSkipVerify bool
+
+	// InterceptPolicy decides if TLS connection should be
+	// intercepted. If it declines, connection is passed to the netloc
+	// as is and proxy sees encrypted bytes only. By default all TLS
+	// connections are intercepted.
+	InterceptPolicy InterceptPolicy
+
+	// TransparentOriginalDestination recovers an address client has
+	// connected to in transparent mode (see Server.ServeTransparent).
+	// By default OriginalDestinationNAT is used so it works with
+	// iptables REDIRECT target. Use OriginalDestinationTPROXY for TPROXY
+	// target.
+	TransparentOriginalDestination OriginalDestination
+
+	// ReverseProxy turns server into reverse proxy. Requests are routed
+	// to upstreams according to Host header (or SNI) and path instead
+	// of a requested URI. TLS is terminated with given certificates,
+	// not with generated ones. Please pay attention that Authenticator
+	// is not used in this mode.
+	ReverseProxy *ReverseProxyOpts
+
+	// TLSMimicUpstream defines if generated certificates should copy
+	// subject, SANs and validity of certificates real netlocs present.
+	// Each certificate is fetched using Dialer before a new one is
+	// generated.
+	TLSMimicUpstream bool
 }
 
 // GetConcurrency returns a concurrency paying attention to default
@@ -178,6 +236,24 @@ func (s *ServerOpts) GetEventProcessorFactory() events.ProcessorFactory {
 	return s.EventProcessorFactory
 }
 
+// GetEventStreamOpts returns options of event stream.
+func (s *ServerOpts) GetEventStreamOpts() events.StreamOpts {
+	if s == nil {
+		return events.StreamOpts{}
+	}
+
+	return s.EventStreamOpts
+}
+
+// GetTracer returns a tracer paying attention to default value.
+func (s *ServerOpts) GetTracer() tracing.Tracer {
+	if s == nil || s.Tracer == nil {
+		return tracing.NoopTracer{}
+	}
+
+	return s.Tracer
+}
+
 // GetTLSCertCA returns a given TLS CA certificate.
 func (s *ServerOpts) GetTLSCertCA() []byte {
 	if s == nil {
@@ -196,6 +272,63 @@ func (s *ServerOpts) GetTLSPrivateKey() []byte {
 	return s.TLSPrivateKey
 }
 
+// GetTLSCAOpts returns options of certificate generation.
+func (s *ServerOpts) GetTLSCAOpts() ca.Opts {
+	if s == nil {
+		return ca.Opts{}
+	}
+
+	return s.TLSCAOpts
+}
+
+// GetDialer returns a dialer to use paying attention to default value.
+func (s *ServerOpts) GetDialer() dialers.Dialer {
+	if s == nil || s.Dialer == nil {
+		return dialers.NewBase(dialers.Opts{
+			TLSSkipVerify: s.GetTLSSkipVerify(),
+		})
+	}
+
+	return s.Dialer
+}
+
+// GetInterceptPolicy returns a policy of TLS interception paying
+// attention to default value (intercept everything).
+func (s *ServerOpts) GetInterceptPolicy() InterceptPolicy {
+	if s == nil || s.InterceptPolicy == nil {
+		return InterceptAll
+	}
+
+	return s.InterceptPolicy
+}
+
+// GetTransparentOriginalDestination returns a function which recovers
+// original destinations of transparent connections paying attention
+// to default value.
+func (s *ServerOpts) GetTransparentOriginalDestination() OriginalDestination {
+	if s == nil || s.TransparentOriginalDestination == nil {
+		return OriginalDestinationNAT
+	}
+
+	return s.TransparentOriginalDestination
+}
+
+// GetReverseProxy returns options of reverse proxy mode. nil means
+// that server works as a forward proxy.
+func (s *ServerOpts) GetReverseProxy() *ReverseProxyOpts {
+	if s == nil {
+		return nil
+	}
+
+	return s.ReverseProxy
+}
+
+// GetTLSMimicUpstream returns a sign if generated certificates should
+// mimic certificates of real netlocs.
+func (s *ServerOpts) GetTLSMimicUpstream() bool {
+	return s != nil && s.TLSMimicUpstream
+}
+
 // GetTLSSkipVerify returns a sign if we need to skip TLS verification.
 func (s *ServerOpts) GetTLSSkipVerify() bool {
 	return s != nil && s.TLSSkipVerify
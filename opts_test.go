@@ -31,6 +31,7 @@ func (suite *OptsTestSuite) TestNil() {
 	suite.Equal(httransform.DefaultWriteTimeout, opts.GetWriteTimeout())
 	suite.Equal(httransform.DefaultTCPKeepAlivePeriod, opts.GetTCPKeepAlivePeriod())
 	suite.NotNil(opts.GetEventProcessorFactory())
+	suite.Equal(events.StreamOpts{}, opts.GetEventStreamOpts())
 	suite.Empty(opts.GetTLSCertCA())
 	suite.Empty(opts.GetTLSPrivateKey())
 	suite.False(opts.GetTLSSkipVerify())
@@ -104,6 +105,14 @@ func (suite *OptsTestSuite) TestGetEventProcessorFactory() {
 	suite.NotNil(suite.o.GetEventProcessorFactory())
 }
 
+func (suite *OptsTestSuite) TestGetEventStreamOpts() {
+	suite.Equal(events.StreamOpts{}, suite.o.GetEventStreamOpts())
+
+	suite.o.EventStreamOpts.BufferSize = 10
+
+	suite.EqualValues(10, suite.o.GetEventStreamOpts().BufferSize)
+}
+
 func (suite *OptsTestSuite) TestGetTLSCertCA() {
 	suite.Empty(suite.o.GetTLSCertCA())
 
@@ -0,0 +1,233 @@
+package httransform
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/9seconds/httransform/v2/ca"
+	"github.com/9seconds/httransform/v2/conns"
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/valyala/fasthttp"
+)
+
+// ReverseRoute defines a mapping of incoming requests to upstream.
+type ReverseRoute struct {
+	// Host is a hostname to match. It is compared with Host header
+	// or with SNI if Host header is absent. Wildcards like
+	// '*.example.com' match any subdomain. Empty value matches any
+	// host.
+	Host string
+
+	// PathPrefix is a prefix of the request path to match. Empty value
+	// matches any path.
+	PathPrefix string
+
+	// Upstream is an URL of the upstream like http://127.0.0.1:8000
+	// or https://backend:8443. Only scheme and host are used. If port is
+	// omitted, a default one for the scheme is used.
+	Upstream string
+}
+
+// ReverseProxyOpts defines options of reverse proxy mode.
+type ReverseProxyOpts struct {
+	// Routes defines a mapping of incoming requests to upstreams. If
+	// several routes match a request, the one with the most specific
+	// host wins: exact hosts go before wildcards and wildcards go
+	// before empty host. Among them, the route with the longest path
+	// prefix is chosen.
+	Routes []ReverseRoute
+
+	// TLSCertificates are used to terminate TLS connections of clients.
+	// A certificate is chosen according to SNI. If there are no
+	// certificates, only plain HTTP is served.
+	TLSCertificates []tls.Certificate
+}
+
+type reverseRoute struct {
+	host       string
+	pathPrefix string
+	address    string
+	scheme     string
+}
+
+func (r *reverseRoute) hostRank(host string) int {
+	switch {
+	case r.host == "":
+		return 1
+	case r.host == host:
+		return 3 // nolint: gomnd
+	case strings.HasPrefix(r.host, "*.") && strings.HasSuffix(host, r.host[1:]):
+		return 2 // nolint: gomnd
+	}
+
+	return 0
+}
+
+type reverseRouter struct {
+	routes    []reverseRoute
+	tlsConfig *tls.Config
+}
+
+func (r *reverseRouter) match(host, path string) *reverseRoute {
+	var (
+		bestRoute *reverseRoute
+		bestRank  int
+	)
+
+	for i := range r.routes {
+		route := &r.routes[i]
+		rank := route.hostRank(host)
+
+		switch {
+		case rank == 0, !strings.HasPrefix(path, route.pathPrefix):
+			continue
+		case bestRoute == nil,
+			rank > bestRank,
+			rank == bestRank && len(route.pathPrefix) > len(bestRoute.pathPrefix):
+			bestRoute = route
+			bestRank = rank
+		}
+	}
+
+	return bestRoute
+}
+
+func newReverseRouter(opts *ReverseProxyOpts) (*reverseRouter, error) {
+	router := &reverseRouter{
+		routes: make([]reverseRoute, 0, len(opts.Routes)),
+	}
+
+	for i := range opts.Routes {
+		parsed, err := url.Parse(opts.Routes[i].Upstream)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect upstream %s: %w", opts.Routes[i].Upstream, err)
+		}
+
+		route := reverseRoute{
+			host:       strings.ToLower(opts.Routes[i].Host),
+			pathPrefix: opts.Routes[i].PathPrefix,
+			scheme:     strings.ToLower(parsed.Scheme),
+			address:    parsed.Host,
+		}
+
+		switch {
+		case route.scheme != "http" && route.scheme != "https":
+			return nil, fmt.Errorf("unsupported scheme of upstream %s", opts.Routes[i].Upstream)
+		case parsed.Port() != "":
+		case route.scheme == "https":
+			route.address = net.JoinHostPort(parsed.Hostname(), "443")
+		default:
+			route.address = net.JoinHostPort(parsed.Hostname(), "80")
+		}
+
+		router.routes = append(router.routes, route)
+	}
+
+	if len(opts.TLSCertificates) > 0 {
+		router.tlsConfig = &tls.Config{
+			Certificates: opts.TLSCertificates,
+			NextProtos:   ca.NextProtos,
+		}
+	}
+
+	return router, nil
+}
+
+// serveReverseConn serves a client connection in reverse proxy mode.
+// Like tunnels, these connections can carry either TLS or plain HTTP.
+//
+// TLS handshake is started right away: if client speaks plain HTTP,
+// handshake fails on the first record header and read bytes are
+// replayed to HTTP server. Routes are chosen by SNI of this handshake.
+func (s *Server) serveReverseConn(conn net.Conn) {
+	uConn := conns.NewUnreadConn(conn)
+	tlsErr := tls.RecordHeaderError{}
+	tlsConfig := s.reverseRouter.tlsConfig
+
+	if tlsConfig == nil {
+		// we need a config only to sniff a record header: handshake
+		// fails if client speaks TLS.
+		tlsConfig = &tls.Config{}
+	}
+
+	tlsConn := tls.Server(uConn, tlsConfig)
+
+	conn.SetReadDeadline(time.Now().Add(s.readTimeout)) // nolint: errcheck
+
+	err := tlsConn.Handshake()
+
+	conn.SetReadDeadline(time.Time{}) // nolint: errcheck
+
+	var (
+		clientConn  net.Conn = uConn
+		state       tls.ConnectionState
+		requestType events.RequestType
+	)
+
+	switch {
+	case errors.As(err, &tlsErr) && tlsErr.Conn != nil:
+		uConn.Unread()
+	case err != nil:
+		conn.Close()
+
+		return
+	default:
+		uConn.Seal()
+
+		requestType |= events.RequestTypeTLS
+		clientConn = tlsConn
+		state = tlsConn.ConnectionState()
+	}
+
+	if s.serveClient(clientConn, state, s.runReverse, "", "", requestType) {
+		conn.Close()
+	}
+}
+
+// runReverse routes a request to upstream and runs it through the
+// layers. Address is ignored: it is taken from the matched route.
+func (s *Server) runReverse(ctx *fasthttp.RequestCtx,
+	_, user, sni string,
+	requestType events.RequestType) bool {
+	host := string(ctx.Request.Header.Host())
+	if host == "" {
+		host = sni
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	route := s.reverseRouter.match(strings.ToLower(host), string(ctx.Path()))
+	if route == nil {
+		errToReturn := &errors.Error{
+			Message:    "no route for " + host,
+			StatusCode: fasthttp.StatusNotFound,
+		}
+
+		errToReturn.WriteTo(ctx)
+
+		return false
+	}
+
+	ownCtx := layers.AcquireContext()
+	defer layers.ReleaseContext(ownCtx)
+
+	if !s.initContext(ownCtx, ctx, route.address, user, sni, requestType) {
+		return false
+	}
+
+	// a scheme of the request defines if executor talks TLS to the
+	// upstream.
+	ownCtx.Request().URI().SetScheme(route.scheme)
+
+	s.main(ownCtx, nil)
+
+	return ownCtx.Hijacked()
+}
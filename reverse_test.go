@@ -0,0 +1,210 @@
+package httransform_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReverseTestSuite struct {
+	suite.Suite
+
+	httpEndpoint *httptest.Server
+	tlsEndpoint  *httptest.Server
+	proxy        *httransform.Server
+	ln           net.Listener
+	ctx          context.Context
+	ctxCancel    context.CancelFunc
+	http         *http.Client
+}
+
+func (suite *ReverseTestSuite) SetupSuite() {
+	suite.httpEndpoint = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "http "+r.Host+r.URL.Path)
+	}))
+	suite.tlsEndpoint = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "https "+r.Host+r.URL.Path)
+	}))
+}
+
+func (suite *ReverseTestSuite) TearDownSuite() {
+	suite.httpEndpoint.Close()
+	suite.tlsEndpoint.Close()
+}
+
+func (suite *ReverseTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+
+	opts := httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		TLSSkipVerify: true,
+		ReadTimeout:   time.Second,
+		ReverseProxy: &httransform.ReverseProxyOpts{
+			Routes: []httransform.ReverseRoute{
+				{
+					Host:     "*.example.com",
+					Upstream: suite.httpEndpoint.URL,
+				},
+				{
+					Host:     "api.example.com",
+					Upstream: suite.tlsEndpoint.URL,
+				},
+				{
+					Host:       "api.example.com",
+					PathPrefix: "/v1/",
+					Upstream:   suite.httpEndpoint.URL,
+				},
+			},
+			TLSCertificates: suite.tlsEndpoint.TLS.Certificates,
+		},
+	}
+
+	proxy, err := httransform.NewServer(suite.ctx, opts)
+
+	suite.NoError(err)
+
+	suite.proxy = proxy
+	suite.ln, _ = net.Listen("tcp", "127.0.0.1:0")
+
+	go suite.proxy.Serve(suite.ln)
+
+	dialer := &net.Dialer{}
+
+	suite.http = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "tcp", suite.ln.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: time.Second,
+	}
+}
+
+func (suite *ReverseTestSuite) TearDownTest() {
+	suite.ctxCancel()
+	suite.proxy.Close()
+	suite.ln.Close()
+}
+
+func (suite *ReverseTestSuite) get(url string) (*http.Response, string) {
+	resp, err := suite.http.Get(url)
+
+	suite.NoError(err)
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	suite.NoError(err)
+
+	return resp, string(data)
+}
+
+func (suite *ReverseTestSuite) TestRoutes() {
+	testData := map[string]string{
+		"http://www.example.com/path":    "http www.example.com/path",
+		"http://api.example.com/path":    "https api.example.com/path",
+		"http://api.example.com/v1/path": "http api.example.com/v1/path",
+		"http://API.example.com:80/":     "https api.example.com:80/",
+	}
+
+	for url, expected := range testData {
+		_, body := suite.get(url)
+
+		suite.Equal(expected, body, url)
+	}
+}
+
+func (suite *ReverseTestSuite) TestNoRoute() {
+	resp, _ := suite.get("http://example.org/")
+
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *ReverseTestSuite) TestTLS() {
+	resp, body := suite.get("https://api.example.com/v1/path")
+
+	suite.Equal("http api.example.com/v1/path", body)
+	suite.Equal(suite.tlsEndpoint.Certificate().Raw, resp.TLS.PeerCertificates[0].Raw)
+}
+
+func (suite *ReverseTestSuite) TestSilentClient() {
+	conn, err := net.Dial("tcp", suite.ln.Addr().String())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	started := time.Now()
+
+	conn.SetReadDeadline(started.Add(10 * time.Second))
+
+	_, err = conn.Read(make([]byte, 1))
+
+	suite.Equal(io.EOF, err)
+	suite.Less(int64(time.Since(started)), int64(5*time.Second))
+}
+
+func (suite *ReverseTestSuite) TestCloseKeepAlive() {
+	conn, err := net.Dial("tcp", suite.ln.Addr().String())
+
+	suite.Require().NoError(err)
+
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "GET /path HTTP/1.1\r\nHost: www.example.com\r\n\r\n")
+
+	suite.Require().NoError(err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+
+	suite.Require().NoError(err)
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	started := time.Now()
+
+	suite.NoError(suite.proxy.Close())
+
+	conn.SetReadDeadline(started.Add(10 * time.Second))
+
+	_, err = conn.Read(make([]byte, 1))
+
+	suite.Equal(io.EOF, err)
+	suite.Less(int64(time.Since(started)), int64(time.Second))
+}
+
+func (suite *ReverseTestSuite) TestIncorrectUpstream() {
+	opts := httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		ReverseProxy: &httransform.ReverseProxyOpts{
+			Routes: []httransform.ReverseRoute{
+				{Upstream: "ftp://127.0.0.1"},
+			},
+		},
+	}
+
+	_, err := httransform.NewServer(suite.ctx, opts)
+
+	suite.Error(err)
+}
+
+func TestReverse(t *testing.T) {
+	suite.Run(t, &ReverseTestSuite{})
+}
@@ -6,8 +6,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/9seconds/httransform/v2/auth"
 	"github.com/9seconds/httransform/v2/ca"
@@ -18,34 +20,201 @@ import (
 	"github.com/9seconds/httransform/v2/executor"
 	"github.com/9seconds/httransform/v2/headers"
 	"github.com/9seconds/httransform/v2/layers"
+	"github.com/9seconds/httransform/v2/tracing"
+	"github.com/9seconds/httransform/v2/upgrades"
 	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
 )
 
+// closeConnsInterval defines how often server interrupts reading from
+// connections of serveConns on shutdown.
+const closeConnsInterval = 100 * time.Millisecond
+
+// requestHandler processes a single client request which is sent
+// to a given address. It returns true if connection was hijacked.
+type requestHandler func(ctx *fasthttp.RequestCtx,
+	address, user, sni string,
+	requestType events.RequestType) bool
+
 // Server defines a MITM proxy instance. Please pay attention that it
 // has its own context. If this context is cancelled, Server starts to
 // gracefully terminate.
+//
+// Event stream has a separate context: it is closed only when
+// requests in flight are finished so their events are processed.
 type Server struct {
-	ctx           context.Context
-	ctxCancel     context.CancelFunc
-	serverPool    sync.Pool
-	eventStream   events.Stream
-	layers        []layers.Layer
-	authenticator auth.Interface
-	executor      executor.Executor
-	ca            *ca.CA
-	server        *fasthttp.Server
+	ctx                 context.Context
+	ctxCancel           context.CancelFunc
+	eventsCtx           context.Context
+	eventsCancel        context.CancelFunc
+	shutdownErr         error
+	serverPool          sync.Pool
+	eventStream         events.BufferedStream
+	layers              []layers.Layer
+	authenticator       auth.Interface
+	executor            executor.Executor
+	tracer              tracing.Tracer
+	layerSpanNames      []string
+	dialer              dialers.Dialer
+	interceptPolicy     InterceptPolicy
+	originalDestination OriginalDestination
+	reverseRouter       *reverseRouter
+	ca                  *ca.CA
+	server              *fasthttp.Server
+	http2Server         http2Server
+	readTimeout         time.Duration
+
+	// connections accepted by serveConns. They are served by pooled
+	// fasthttp servers so they are not closed by server.Shutdown.
+	connsMutex  sync.Mutex
+	connsWG     sync.WaitGroup
+	conns       map[net.Conn]struct{}
+	connsClosed bool
 }
 
-// Serve starts to serve on given net.Listener instance.
+// Serve starts to serve on given net.Listener instance. If
+// ServerOpts.ReverseProxy is set, server works as a reverse proxy.
 func (s *Server) Serve(ln net.Listener) error {
+	if s.reverseRouter != nil {
+		return s.serveConns(ln, s.serveReverseConn)
+	}
+
 	return s.server.Serve(ln) // nolint: wrapcheck
 }
 
-// Close stops server.
+// serveConns accepts connections from the listener and serves each of
+// them in a separate goroutine with a given callback. Callback owns a
+// connection. Listener is closed when server is closed and server waits
+// until callbacks are finished.
+func (s *Server) serveConns(ln net.Listener, callback func(net.Conn)) error {
+	ctxDone := s.ctx.Done()
+
+	go func() {
+		<-ctxDone
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctxDone:
+				return nil
+			default:
+			}
+
+			var netErr net.Error
+
+			if errors.As(err, &netErr) && netErr.Temporary() { // nolint: staticcheck
+				continue
+			}
+
+			return err // nolint: wrapcheck
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+
+			return nil
+		}
+
+		go func() {
+			defer s.untrackConn(conn)
+
+			callback(conn)
+		}()
+	}
+}
+
+// trackConn registers a connection of serveConns. It returns false if
+// server is shutting down.
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+
+	if s.connsClosed {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	s.connsWG.Add(1)
+
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.connsMutex.Lock()
+	delete(s.conns, conn)
+	s.connsMutex.Unlock()
+
+	s.connsWG.Done()
+}
+
+// closeConns interrupts reading from connections of serveConns and
+// waits until they are finished. Responses which are in flight are
+// still written: only waiting for the next request is interrupted.
+// fasthttp sets its own read deadline before each request so it is
+// done periodically.
+func (s *Server) closeConns() {
+	done := make(chan struct{})
+
+	s.connsMutex.Lock()
+	s.connsClosed = true
+	s.connsMutex.Unlock()
+
+	go func() {
+		s.connsWG.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(closeConnsInterval)
+	defer ticker.Stop()
+
+	for {
+		s.connsMutex.Lock()
+
+		for conn := range s.conns {
+			conn.SetReadDeadline(time.Now()) // nolint: errcheck
+		}
+
+		s.connsMutex.Unlock()
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops server. It waits until event processors have processed
+// buffered events and were shut down.
 func (s *Server) Close() error {
 	s.ctxCancel()
 
-	return s.server.Shutdown() // nolint: wrapcheck
+	<-s.eventStream.Done()
+
+	return s.shutdownErr
+}
+
+// shutdown waits until server context is closed, stops serving
+// requests and closes event stream after that.
+func (s *Server) shutdown() {
+	<-s.ctx.Done()
+
+	s.shutdownErr = s.server.Shutdown()
+
+	s.http2Server.Shutdown()
+	s.closeConns()
+
+	// shutdownErr is read only after event stream is done.
+	s.eventsCancel()
+}
+
+// DroppedEvents returns a number of events which were not delivered
+// to event processors. Please see ServerOpts.EventStreamOpts.
+func (s *Server) DroppedEvents() uint64 {
+	return s.eventStream.Dropped()
 }
 
 func (s *Server) entrypoint(ctx *fasthttp.RequestCtx) {
@@ -107,49 +276,307 @@ func (s *Server) entrypointPlain(ctx *fasthttp.RequestCtx, user string) {
 		return
 	}
 
-	s.runMain(ctx, address, user, requestType)
+	s.runMain(ctx, address, user, "", requestType)
 }
 
 func (s *Server) upgradeToTLS(requestType events.RequestType, user, address string) fasthttp.HijackHandler {
-	host, _, _ := net.SplitHostPort(address)
-
 	return conns.FixHijackHandler(func(conn net.Conn) bool {
-		conf, err := s.ca.Get(host)
-		if err != nil {
+		return s.serveTunnel(conn, nil, address, user, requestType)
+	})
+}
+
+// serveTunnel serves a client connection which is established to the
+// given address. Connection may carry either TLS or plain HTTP so
+// it is sniffed before. It returns true if connection has to be closed
+// by a caller.
+//
+// netlocConn is a connection to the netloc which was established in
+// advance (SOCKS has to report dial errors to the client). If it is
+// set, tunnel can carry any protocol: everything which is neither TLS
+// nor HTTP is passed to the netloc as is. This includes protocols
+// where netloc speaks first so client is waited only for
+// tunnelSniffTimeout. If tunnel is intercepted, executor gets
+// netlocConn on the first dial to the netloc if layers have chosen the
+// same dialer which has established it.
+func (s *Server) serveTunnel(conn, netlocConn net.Conn,
+	address, user string,
+	requestType events.RequestType) bool {
+	host, port, _ := net.SplitHostPort(address)
+	uConn := conns.NewUnreadConn(conn)
+	tlsErr := tls.RecordHeaderError{}
+	netErr := net.Error(nil)
+
+	if netlocConn != nil {
+		conn.SetReadDeadline(time.Now().Add(tunnelSniffTimeout)) // nolint: errcheck
+	}
+
+	hello, err := sniffClientHello(uConn)
+
+	if netlocConn != nil {
+		conn.SetReadDeadline(time.Time{}) // nolint: errcheck
+	}
+
+	// ClientHello is replayed either to our own TLS server or to
+	// the netloc.
+	uConn.Unread()
+
+	var (
+		clientConn net.Conn = uConn
+		state      tls.ConnectionState
+	)
+
+	switch {
+	case errors.As(err, &tlsErr) && tlsErr.Conn != nil:
+		if netlocConn != nil && !looksLikeHTTP(tlsErr.RecordHeader[:]) {
+			s.passThrough(uConn, netlocConn, address, user)
+
 			return true
 		}
+	case netlocConn != nil && errors.As(err, &netErr) && netErr.Timeout():
+		// client waits for the netloc to speak first: this is
+		// neither TLS nor HTTP.
+		s.passThrough(uConn, netlocConn, address, user)
 
-		uConn := conns.NewUnreadConn(conn)
-		tlsErr := tls.RecordHeaderError{}
-		tlsConn := tls.Server(uConn, conf)
-		err = tlsConn.Handshake()
+		return true
+	case err != nil:
+		closeNetlocConn(netlocConn)
+
+		return true
+	case !s.interceptPolicy(user, host, hello):
+		s.passThrough(uConn, netlocConn, address, user)
+
+		return true
+	default:
+		tlsConn := tls.Server(uConn, &tls.Config{
+			GetCertificate: s.getCertificate(host, port),
+			NextProtos:     ca.NextProtos,
+		})
+
+		if err := tlsConn.Handshake(); err != nil {
+			closeNetlocConn(netlocConn)
 
-		switch {
-		case errors.As(err, &tlsErr) && tlsErr.Conn != nil:
-			uConn.Unread()
-		case err != nil:
 			return true
-		default:
-			requestType |= events.RequestTypeTLS
-			uConn.Seal()
 		}
 
-		srv, _ := s.serverPool.Get().(*fasthttp.Server)
-		defer s.serverPool.Put(srv)
+		requestType |= events.RequestTypeTLS
+		clientConn = tlsConn
+		state = tlsConn.ConnectionState()
+	}
 
-		needToClose := true
+	handler := s.runMain
 
-		srv.Handler = func(ctx *fasthttp.RequestCtx) {
-			needToClose = !s.runMain(ctx, address, user, requestType)
+	if netlocConn != nil {
+		predialed := &predialedDialer{
+			Dialer:  s.tunnelDialer(address, user),
+			address: address,
+			conn:    netlocConn,
 		}
 
-		srv.ServeConn(tlsConn) // nolint: errcheck
+		defer predialed.Close()
 
-		return needToClose
-	})
+		handler = func(ctx *fasthttp.RequestCtx,
+			address, user, sni string,
+			requestType events.RequestType) bool {
+			return s.runMainPredialed(ctx, address, user, sni, requestType, predialed)
+		}
+	}
+
+	return s.serveClient(clientConn, state, handler, address, user, requestType)
+}
+
+// serveClient serves HTTP requests of a client connection. If
+// connection is TLS, it has to be already terminated and its state
+// has to be passed. It returns true if connection has to be closed by
+// a caller.
+func (s *Server) serveClient(conn net.Conn,
+	state tls.ConnectionState,
+	handler requestHandler,
+	address, user string,
+	requestType events.RequestType) bool {
+	if state.NegotiatedProtocol == http2.NextProtoTLS {
+		s.http2Server.ServeConn(conn, state, handler, address, user, requestType)
+
+		return true
+	}
+
+	srv, _ := s.serverPool.Get().(*fasthttp.Server)
+	defer s.serverPool.Put(srv)
+
+	needToClose := true
+
+	srv.Handler = func(ctx *fasthttp.RequestCtx) {
+		needToClose = !handler(ctx, address, user, state.ServerName, requestType)
+	}
+
+	srv.ServeConn(conn) // nolint: errcheck
+
+	return needToClose
+}
+
+// passThrough splices a client connection to the netloc without
+// interception. Client connection has to be in 'unreading' state so
+// netloc gets a ClientHello client has sent. If netlocConn is nil,
+// netloc is dialed.
+//
+// Tunnel is opaque so it cannot be terminated gracefully: it is closed
+// when server is closed.
+func (s *Server) passThrough(clientConn, netlocConn net.Conn, address, user string) {
+	if netlocConn == nil {
+		conn, err := s.dialTunnel(address, user)
+		if err != nil {
+			return
+		}
+
+		netlocConn = conn
+	}
+
+	defer netlocConn.Close()
+
+	tunnelDone := make(chan struct{})
+	defer close(tunnelDone)
+
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			clientConn.Close()
+			netlocConn.Close()
+		case <-tunnelDone:
+		}
+	}()
+
+	upgrader := upgrades.AcquireTCP(upgrades.NoopTCPReactor{})
+	defer upgrades.ReleaseTCP(upgrader)
+
+	upgrader.Manage(clientConn, netlocConn)
+}
+
+// tunnelDialer returns a dialer for a tunnel: it is chosen by
+// layers.TunnelDialerLayer, if any, the same way as layers choose it
+// for requests.
+func (s *Server) tunnelDialer(address, user string) dialers.Dialer {
+	dialer := s.dialer
+
+	for _, layer := range s.layers {
+		if tunnelLayer, ok := layer.(layers.TunnelDialerLayer); ok {
+			if chosen := tunnelLayer.TunnelDialer(user, address); chosen != nil {
+				dialer = chosen
+			}
+		}
+	}
+
+	return dialer
+}
+
+// dialTunnel dials a netloc for a tunnel with a dialer of
+// tunnelDialer.
+func (s *Server) dialTunnel(address, user string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errors.Annotate(err, "incorrect address format", "", 0)
+	}
+
+	conn, err := s.tunnelDialer(address, user).Dial(dialers.WithUser(s.ctx, user), host, port)
+	if err != nil {
+		return nil, errors.Annotate(err, "cannot dial to the netloc", "", 0)
+	}
+
+	return conn, nil
+}
+
+// predialedDialer is a dialer of intercepted tunnel which has a
+// connection to the netloc established in advance. The first dial to
+// this netloc returns this connection so netloc is not dialed twice.
+type predialedDialer struct {
+	dialers.Dialer
+
+	address string
+	mutex   sync.Mutex
+	conn    net.Conn
+}
+
+func (p *predialedDialer) Dial(ctx context.Context, host, port string) (net.Conn, error) {
+	if net.JoinHostPort(host, port) == p.address {
+		if conn := p.take(); conn != nil {
+			return conn, nil
+		}
+	}
+
+	return p.Dialer.Dial(ctx, host, port) // nolint: wrapcheck
+}
+
+// Close closes a connection if nobody has taken it.
+func (p *predialedDialer) Close() {
+	if conn := p.take(); conn != nil {
+		conn.Close()
+	}
+}
+
+func (p *predialedDialer) take() net.Conn {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	conn := p.conn
+	p.conn = nil
+
+	return conn
 }
 
-func (s *Server) runMain(ctx *fasthttp.RequestCtx, address, user string, requestType events.RequestType) bool {
+func closeNetlocConn(conn net.Conn) {
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// getCertificate returns a callback which generates a certificate for
+// SNI hostname client has sent. If client has sent no SNI (for
+// example, it connects to IP address), a host from CONNECT method is
+// used. port is a port from CONNECT method.
+func (s *Server) getCertificate(host, port string) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		serverName := hello.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+
+		conf, err := s.ca.GetWithPort(serverName, port)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get certificate for %s: %w", serverName, err)
+		}
+
+		return &conf.Certificates[0], nil
+	}
+}
+
+func (s *Server) runMain(ctx *fasthttp.RequestCtx,
+	address, user, sni string,
+	requestType events.RequestType) bool {
+	return s.runMainPredialed(ctx, address, user, sni, requestType, nil)
+}
+
+// runMainPredialed is runMain for requests of a tunnel which has a
+// connection to the netloc established in advance.
+func (s *Server) runMainPredialed(ctx *fasthttp.RequestCtx,
+	address, user, sni string,
+	requestType events.RequestType,
+	predialed *predialedDialer) bool {
+	ownCtx := layers.AcquireContext()
+	defer layers.ReleaseContext(ownCtx)
+
+	if !s.initContext(ownCtx, ctx, address, user, sni, requestType) {
+		return false
+	}
+
+	s.main(ownCtx, predialed)
+
+	return ownCtx.Hijacked()
+}
+
+// initContext initializes a layers context for the given request. If
+// it fails, an error response is written and false is returned.
+func (s *Server) initContext(ownCtx *layers.Context,
+	ctx *fasthttp.RequestCtx,
+	address, user, sni string,
+	requestType events.RequestType) bool {
 	ctx.Request.Header.VisitAll(func(key, value []byte) {
 		if bytes.EqualFold(key, []byte("Connection")) {
 			values := headers.Values(string(value))
@@ -162,9 +589,6 @@ func (s *Server) runMain(ctx *fasthttp.RequestCtx, address, user string, request
 		}
 	})
 
-	ownCtx := layers.AcquireContext()
-	defer layers.ReleaseContext(ownCtx)
-
 	if err := ownCtx.Init(ctx, address, s.eventStream, user, requestType); err != nil {
 		errToReturn := &errors.Error{
 			Message: "cannot execute this request",
@@ -176,38 +600,71 @@ func (s *Server) runMain(ctx *fasthttp.RequestCtx, address, user string, request
 		return false
 	}
 
-	s.main(ownCtx)
+	ownCtx.SNI = sni
 
-	return ownCtx.Hijacked()
+	return true
 }
 
-func (s *Server) main(ctx *layers.Context) {
+func (s *Server) main(ctx *layers.Context, predialed *predialedDialer) {
 	requestMeta := &events.RequestMeta{
 		RequestID:   ctx.RequestID,
 		RequestType: ctx.RequestType,
 		Method:      string(bytes.ToUpper(ctx.Request().Header.Method())),
 		User:        ctx.User,
 		Addr:        ctx.RemoteAddr(),
+		Protocol:    string(ctx.Request().Header.Protocol()),
+	}
+
+	if header := ctx.RequestHeaders.GetLast("Referer"); header != nil {
+		requestMeta.Referer = header.Value()
+	}
+
+	if header := ctx.RequestHeaders.GetLast("User-Agent"); header != nil {
+		requestMeta.UserAgent = header.Value()
 	}
 
 	ctx.Request().URI().CopyTo(&requestMeta.URI)
-	s.eventStream.Send(s.ctx, events.EventTypeStartRequest, requestMeta, ctx.RequestID)
+	s.eventStream.Send(s.eventsCtx, events.EventTypeStartRequest, requestMeta, ctx.RequestID)
+
+	span := s.startRequestSpan(ctx, requestMeta)
+	startTime := time.Now()
+
+	var err error
 
 	defer func() {
+		ctx.Timings.Total = time.Since(startTime)
+
+		span.SetAttribute("http.status_code", ctx.Response().StatusCode())
+		span.RecordError(err)
+		span.End()
+
 		responseMeta := &events.ResponseMeta{
 			RequestID:  ctx.RequestID,
 			StatusCode: ctx.Response().StatusCode(),
+			Timings:    ctx.Timings,
 		}
 
-		s.eventStream.Send(s.ctx, events.EventTypeFinishRequest, responseMeta, ctx.RequestID)
+		s.eventStream.Send(s.eventsCtx, events.EventTypeFinishRequest, responseMeta, ctx.RequestID)
 	}()
 
 	currentLayer := 0
 
-	var err error
-
 	for ; err == nil && currentLayer < len(s.layers); currentLayer++ {
+		_, layerSpan := tracing.StartSpan(ctx, s.layerSpanNames[currentLayer]+".OnRequest")
 		err = s.layers[currentLayer].OnRequest(ctx)
+
+		layerSpan.RecordError(err)
+		layerSpan.End()
+	}
+
+	executorStartTime := time.Now()
+	ctx.Timings.Layers = executorStartTime.Sub(startTime)
+
+	// a connection which was established in advance is used only if
+	// layers have chosen the same dialer.
+	if predialed != nil &&
+		(ctx.Dialer == predialed.Dialer || ctx.Dialer == nil && predialed.Dialer == s.dialer) {
+		ctx.Dialer = predialed
 	}
 
 	if err == nil {
@@ -217,10 +674,19 @@ func (s *Server) main(ctx *layers.Context) {
 		}
 	}
 
+	executorFinishTime := time.Now()
+	ctx.Timings.Executor = executorFinishTime.Sub(executorStartTime)
+
 	for currentLayer--; currentLayer >= 0; currentLayer-- {
+		_, layerSpan := tracing.StartSpan(ctx, s.layerSpanNames[currentLayer]+".OnResponse")
 		err = s.layers[currentLayer].OnResponse(ctx, err)
+
+		layerSpan.RecordError(err)
+		layerSpan.End()
 	}
 
+	ctx.Timings.Layers += time.Since(executorFinishTime)
+
 	if err != nil {
 		errorMeta := &events.ErrorMeta{
 			RequestID: ctx.RequestID,
@@ -232,6 +698,37 @@ func (s *Server) main(ctx *layers.Context) {
 	}
 }
 
+func (s *Server) startRequestSpan(ctx *layers.Context, requestMeta *events.RequestMeta) tracing.Span {
+	parentCtx := context.Context(ctx)
+
+	if header := ctx.RequestHeaders.GetLast("traceparent"); header != nil {
+		if parent, err := tracing.ParseTraceparent(header.Value()); err == nil {
+			parentCtx = tracing.ContextWithRemoteParent(parentCtx, parent)
+		}
+	}
+
+	_, span := s.tracer.Start(parentCtx, "request")
+
+	span.SetAttribute("http.method", requestMeta.Method)
+	span.SetAttribute("http.url", requestMeta.URI.String())
+	span.SetAttribute("httransform.request_id", requestMeta.RequestID)
+	span.SetAttribute("httransform.request_type", requestMeta.RequestType.String())
+
+	if requestMeta.User != "" {
+		span.SetAttribute("httransform.user", requestMeta.User)
+	}
+
+	ctx.SetSpan(span)
+
+	// headers are pushed to the request by layerFinishHeaders so
+	// layers can see and modify traceparent.
+	if spanContext := span.SpanContext(); spanContext.IsValid() {
+		ctx.RequestHeaders.Set("traceparent", spanContext.Traceparent(), true)
+	}
+
+	return span
+}
+
 func (s *Server) extractAddress(hostport string, isTLS bool) (string, error) {
 	_, _, err := net.SplitHostPort(hostport)
 
@@ -255,36 +752,72 @@ func (s *Server) extractAddress(hostport string, isTLS bool) (string, error) {
 // options.
 func NewServer(ctx context.Context, opts ServerOpts) (*Server, error) { // nolint: funlen
 	ctx, cancel := context.WithCancel(ctx)
+	eventsCtx, eventsCancel := context.WithCancel(context.Background())
 	oopts := &opts
-	eventStream := events.NewStream(ctx, oopts.GetEventProcessorFactory())
+	eventStream := events.NewStreamWithOpts(eventsCtx,
+		oopts.GetEventProcessorFactory(),
+		oopts.GetEventStreamOpts())
 	authenticator := oopts.GetAuthenticator()
 
+	dialer := oopts.GetDialer()
+	caOpts := oopts.GetTLSCAOpts()
+
+	if oopts.GetTLSMimicUpstream() {
+		if caOpts.UpstreamCertificate == nil {
+			caOpts.UpstreamCertificate = ca.MakeUpstreamCertificate(ctx, dialer)
+		}
+
+		if caOpts.Template == nil {
+			caOpts.Template = ca.MimicTemplate
+		}
+	}
+
 	certAuth, err := ca.NewCA(ctx,
 		eventStream,
 		oopts.GetTLSCertCA(),
-		oopts.GetTLSPrivateKey())
+		oopts.GetTLSPrivateKey(),
+		caOpts)
 	if err != nil {
 		cancel()
+		eventsCancel()
 
 		return nil, fmt.Errorf("cannot make certificate authority: %w", err)
 	}
 
+	var router *reverseRouter
+
+	if reverseOpts := oopts.GetReverseProxy(); reverseOpts != nil {
+		if router, err = newReverseRouter(reverseOpts); err != nil {
+			cancel()
+			eventsCancel()
+
+			return nil, fmt.Errorf("cannot make reverse proxy router: %w", err)
+		}
+	}
+
 	exec := oopts.GetExecutor()
 	if exec == nil {
-		dialer := dialers.NewBase(dialers.Opts{
-			TLSSkipVerify: oopts.GetTLSSkipVerify(),
-		})
-		exec = executor.MakeDefaultExecutor(dialer)
+		exec = executor.MakeDefaultExecutorWithPool(dialer,
+			executor.NewConnPool(ctx, executor.ConnPoolOpts{}))
 	}
 
 	srv := &Server{
-		ctx:           ctx,
-		ctxCancel:     cancel,
-		eventStream:   eventStream,
-		ca:            certAuth,
-		layers:        oopts.GetLayers(),
-		authenticator: authenticator,
-		executor:      exec,
+		ctx:                 ctx,
+		ctxCancel:           cancel,
+		eventsCtx:           eventsCtx,
+		eventsCancel:        eventsCancel,
+		eventStream:         eventStream,
+		ca:                  certAuth,
+		layers:              oopts.GetLayers(),
+		authenticator:       authenticator,
+		executor:            exec,
+		tracer:              oopts.GetTracer(),
+		dialer:              dialer,
+		interceptPolicy:     oopts.GetInterceptPolicy(),
+		originalDestination: oopts.GetTransparentOriginalDestination(),
+		reverseRouter:       router,
+		readTimeout:         oopts.GetReadTimeout(),
+		conns:               map[net.Conn]struct{}{},
 		serverPool: sync.Pool{
 			New: func() interface{} {
 				return &fasthttp.Server{
@@ -312,19 +845,32 @@ func NewServer(ctx context.Context, opts ServerOpts) (*Server, error) { // nolin
 						}
 
 						cctx.URI().CopyTo(&meta.URI)
-						eventStream.Send(ctx, events.EventTypeCommonError, meta, "")
+						eventStream.Send(eventsCtx, events.EventTypeCommonError, meta, "")
 					},
 				}
 			},
 		},
 	}
+	srv.layerSpanNames = make([]string, len(srv.layers))
+
+	for i, layer := range srv.layers {
+		srv.layerSpanNames[i] = fmt.Sprintf("%T", layer)
+	}
+
+	srv.http2Server = http2Server{
+		ctx: ctx,
+		server: &http2.Server{
+			IdleTimeout: oopts.GetReadTimeout(),
+		},
+		baseConfig: &http.Server{},
+	}
+
+	// it registers h2 connections so they are closed on shutdown.
+	http2.ConfigureServer(srv.http2Server.baseConfig, srv.http2Server.server) // nolint: errcheck
 	srv.server, _ = srv.serverPool.Get().(*fasthttp.Server)
 	srv.server.Handler = srv.entrypoint
 
-	go func() {
-		<-ctx.Done()
-		srv.Close()
-	}()
+	go srv.shutdown()
 
 	return srv, nil
 }
@@ -0,0 +1,175 @@
+package httransform
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/9seconds/httransform/v2/headers"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+// http2Server serves client connections which have negotiated h2 via
+// ALPN. Each h2 stream is converted into fasthttp.RequestCtx and goes
+// through the same runMain pipeline as HTTP/1.1 requests.
+type http2Server struct {
+	ctx        context.Context
+	server     *http2.Server
+	baseConfig *http.Server
+}
+
+func (h *http2Server) ServeConn(conn net.Conn,
+	state tls.ConnectionState,
+	handler requestHandler,
+	address, user string,
+	requestType events.RequestType) {
+	// connections which are registered after Shutdown won't get
+	// GOAWAY so they are not served at all.
+	if h.ctx.Err() != nil {
+		return
+	}
+
+	h.server.ServeConn(conn, &http2.ServeConnOpts{
+		Context:    h.ctx,
+		BaseConfig: h.baseConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.serveStream(w, r, conn, handler, address, user, state.ServerName, requestType)
+		}),
+	})
+}
+
+// Shutdown gracefully closes all h2 connections: clients get GOAWAY
+// and active streams are finished.
+func (h *http2Server) Shutdown() {
+	h.baseConfig.Shutdown(context.Background()) // nolint: errcheck
+}
+
+func (h *http2Server) serveStream(w http.ResponseWriter, // nolint: interfacer
+	r *http.Request,
+	conn net.Conn,
+	handler requestHandler,
+	address, user, sni string,
+	requestType events.RequestType) {
+	ctx := &fasthttp.RequestCtx{}
+
+	ctx.Init2(conn, nil, false)
+
+	// request is cancelled if client has reset h2 stream or has gone
+	// away: h2 streams are not bound to fasthttp server.
+	ctx.SetUserValue(layers.ParentContextKey, r.Context())
+
+	if err := http2FillRequest(&ctx.Request, r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	// upgrades are not possible within h2 streams so hijacking
+	// status is ignored here.
+	handler(ctx, address, user, sni, requestType)
+
+	http2WriteResponse(w, &ctx.Response)
+}
+
+func http2FillRequest(req *fasthttp.Request, r *http.Request) error {
+	// headers are serialized and parsed as HTTP/1.1 ones so request
+	// has raw headers like it was read from the client connection.
+	// layers.Context reads headers from them.
+	buf := bytes.Buffer{}
+
+	buf.WriteString(r.Method)
+	buf.WriteByte(' ')
+	buf.WriteString(r.URL.RequestURI())
+	buf.WriteString(" HTTP/1.1\r\nHost: ")
+	buf.WriteString(r.Host)
+	buf.WriteString("\r\n")
+
+	// http.Request keeps headers in a map so their order is lost.
+	// They are sorted to be deterministic at least.
+	keys := make([]string, 0, len(r.Header))
+
+	for key := range r.Header {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.ToLower(key)
+
+		if name == "content-length" || name == "host" {
+			continue
+		}
+
+		for _, value := range r.Header[key] {
+			buf.WriteString(name)
+			buf.WriteString(": ")
+			buf.WriteString(value)
+			buf.WriteString("\r\n")
+		}
+	}
+
+	buf.WriteString("\r\n")
+
+	req.Header.DisableNormalizing()
+
+	if err := req.Header.Read(bufio.NewReader(&buf)); err != nil {
+		return fmt.Errorf("cannot parse request headers: %w", err)
+	}
+
+	// body is closed by http2.Server when handler is finished so we
+	// hide Close method: otherwise fasthttp would close it as soon as
+	// body stream is replaced.
+	// http2 server sets zero content length only if stream has no
+	// body at all: HEADERS frame has END_STREAM flag.
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		req.SetBodyStream(struct{ io.Reader }{r.Body}, int(r.ContentLength))
+	}
+
+	return nil
+}
+
+func http2WriteResponse(w http.ResponseWriter, resp *fasthttp.Response) {
+	header := w.Header()
+
+	resp.Header.VisitAll(func(key, value []byte) {
+		name := string(key)
+
+		if !headers.IsHopByHop(name) {
+			header.Add(name, string(value))
+		}
+	})
+
+	w.WriteHeader(resp.StatusCode())
+
+	if !resp.SkipBody {
+		resp.BodyWriteTo(http2FlushWriter{w}) // nolint: errcheck
+	}
+}
+
+// http2FlushWriter flushes each written chunk to the client so
+// streaming responses (server-sent events, long polling) are not
+// buffered by h2 framer.
+type http2FlushWriter struct {
+	w http.ResponseWriter
+}
+
+func (h http2FlushWriter) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+
+	if flusher, ok := h.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return n, err // nolint: wrapcheck
+}
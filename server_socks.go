@@ -0,0 +1,418 @@
+package httransform
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/events"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	socks4Version = 0x04
+	socks5Version = 0x05
+
+	socksCommandConnect = 0x01
+
+	socks4ReplyGranted  = 0x5a
+	socks4ReplyRejected = 0x5b
+
+	socks5AuthNone         = 0x00
+	socks5AuthPassword     = 0x02
+	socks5AuthNoAcceptable = 0xff
+	socks5AuthVersion      = 0x01
+
+	socks5AddressIPv4   = 0x01
+	socks5AddressDomain = 0x03
+	socks5AddressIPv6   = 0x04
+
+	socks5ReplySucceeded           = 0x00
+	socks5ReplyGeneralFailure      = 0x01
+	socks5ReplyNotAllowed          = 0x02
+	socks5ReplyNetworkUnreachable  = 0x03
+	socks5ReplyHostUnreachable     = 0x04
+	socks5ReplyConnectionRefused   = 0x05
+	socks5ReplyCommandNotSupported = 0x07
+	socks5ReplyAddressNotSupported = 0x08
+)
+
+var (
+	errSocksUnsupportedVersion = &errors.Error{
+		Message: "unsupported version of socks protocol",
+	}
+	errSocksUnsupportedCommand = &errors.Error{
+		Message: "unsupported socks command",
+	}
+	errSocksUnsupportedAddress = &errors.Error{
+		Message: "unsupported socks address type",
+	}
+)
+
+// socksBufferedConn is a connection which has some data buffered
+// after SOCKS handshake.
+type socksBufferedConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+}
+
+func (s socksBufferedConn) Read(p []byte) (int, error) {
+	return s.reader.Read(p) // nolint: wrapcheck
+}
+
+// ServeSOCKS starts to serve SOCKS5 and SOCKS4a clients on given
+// net.Listener instance. Only CONNECT command is supported.
+//
+// Clients are authenticated with ServerOpts.Authenticator. SOCKS5
+// username/password and SOCKS4 user ID are passed to it as
+// Proxy-Authorization header with basic credentials so any
+// authenticator of auth package can be used.
+//
+// netloc is dialed before a reply is sent so client gets a proper
+// error code if it is not reachable. A dialer can be chosen per user
+// with layers.TunnelDialerLayer. TLS and plain HTTP are processed in
+// the same way as tunnels of CONNECT method: they go through layers
+// and executor. Executor gets the dialed connection on its first dial
+// if layers choose the same dialer for a request. Other protocols are
+// passed to the dialed netloc as is.
+func (s *Server) ServeSOCKS(ln net.Listener) error {
+	return s.serveConns(ln, s.serveSOCKSConn)
+}
+
+func (s *Server) serveSOCKSConn(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(s.server.ReadTimeout)) // nolint: errcheck
+
+	reader := bufio.NewReaderSize(conn, 512) // nolint: gomnd
+
+	version, err := reader.ReadByte()
+	if err != nil {
+		conn.Close()
+
+		return
+	}
+
+	var address, user string
+
+	switch version {
+	case socks5Version:
+		address, user, err = s.socks5Handshake(conn, reader)
+	case socks4Version:
+		address, user, err = s.socks4Handshake(conn, reader)
+	default:
+		err = errSocksUnsupportedVersion
+	}
+
+	if err != nil {
+		conn.Close()
+
+		return
+	}
+
+	netlocConn, err := s.dialTunnel(address, user)
+
+	if replyErr := socksReply(conn, version, err); err != nil || replyErr != nil {
+		closeNetlocConn(netlocConn)
+		conn.Close()
+
+		return
+	}
+
+	conn.SetDeadline(time.Time{}) // nolint: errcheck
+
+	var tunnelConn net.Conn = conn
+
+	// some clients send data optimistically, without waiting for a
+	// reply.
+	if reader.Buffered() > 0 {
+		tunnelConn = socksBufferedConn{
+			Conn:   conn,
+			reader: reader,
+		}
+	}
+
+	if s.serveTunnel(tunnelConn, netlocConn, address, user, events.RequestTypeTunneled) {
+		conn.Close()
+	}
+}
+
+func (s *Server) socks5Handshake(conn net.Conn, reader *bufio.Reader) (string, string, error) {
+	methods, err := socksReadBytes(reader)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read auth methods: %w", err)
+	}
+
+	hasPassword := false
+
+	for _, method := range methods {
+		if method == socks5AuthPassword {
+			hasPassword = true
+		}
+	}
+
+	user, err := s.socks5Authenticate(conn, reader, hasPassword)
+	if err != nil {
+		return "", "", err
+	}
+
+	// VER CMD RSV ATYP
+	header := make([]byte, 4) // nolint: gomnd
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", "", fmt.Errorf("cannot read a request: %w", err)
+	}
+
+	var host string
+
+	switch header[3] {
+	case socks5AddressIPv4:
+		host, err = socksReadIP(reader, net.IPv4len)
+	case socks5AddressIPv6:
+		host, err = socksReadIP(reader, net.IPv6len)
+	case socks5AddressDomain:
+		var domain []byte
+
+		domain, err = socksReadBytes(reader)
+		host = string(domain)
+	default:
+		socks5Reply(conn, socks5ReplyAddressNotSupported)
+
+		return "", "", errSocksUnsupportedAddress
+	}
+
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read an address: %w", err)
+	}
+
+	port, err := socksReadPort(reader)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read a port: %w", err)
+	}
+
+	if header[1] != socksCommandConnect {
+		socks5Reply(conn, socks5ReplyCommandNotSupported)
+
+		return "", "", errSocksUnsupportedCommand
+	}
+
+	return net.JoinHostPort(host, port), user, nil
+}
+
+func (s *Server) socks5Authenticate(conn net.Conn, reader *bufio.Reader, hasPassword bool) (string, error) {
+	if !hasPassword {
+		user, err := s.socksAuthenticate(conn, nil)
+		if err != nil {
+			conn.Write([]byte{socks5Version, socks5AuthNoAcceptable}) // nolint: errcheck
+
+			return "", err
+		}
+
+		if _, err := conn.Write([]byte{socks5Version, socks5AuthNone}); err != nil {
+			return "", fmt.Errorf("cannot send auth method: %w", err)
+		}
+
+		return user, nil
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, socks5AuthPassword}); err != nil {
+		return "", fmt.Errorf("cannot send auth method: %w", err)
+	}
+
+	// RFC1929: VER ULEN UNAME PLEN PASSWD
+	if _, err := reader.ReadByte(); err != nil {
+		return "", fmt.Errorf("cannot read auth version: %w", err)
+	}
+
+	username, err := socksReadBytes(reader)
+	if err != nil {
+		return "", fmt.Errorf("cannot read username: %w", err)
+	}
+
+	password, err := socksReadBytes(reader)
+	if err != nil {
+		return "", fmt.Errorf("cannot read password: %w", err)
+	}
+
+	credentials := append(append(username, ':'), password...)
+
+	user, err := s.socksAuthenticate(conn, credentials)
+	if err != nil {
+		conn.Write([]byte{socks5AuthVersion, socks5ReplyNotAllowed}) // nolint: errcheck
+
+		return "", err
+	}
+
+	if _, err := conn.Write([]byte{socks5AuthVersion, socks5ReplySucceeded}); err != nil {
+		return "", fmt.Errorf("cannot send auth status: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *Server) socks4Handshake(conn net.Conn, reader *bufio.Reader) (string, string, error) {
+	command, err := reader.ReadByte()
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read a command: %w", err)
+	}
+
+	port, err := socksReadPort(reader)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read a port: %w", err)
+	}
+
+	host, err := socksReadIP(reader, net.IPv4len)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read an address: %w", err)
+	}
+
+	userID, err := reader.ReadBytes(0)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read user id: %w", err)
+	}
+
+	// SOCKS4a: IP 0.0.0.x means that a domain name follows.
+	if ip := net.ParseIP(host).To4(); ip[0] == 0 && ip[1] == 0 && ip[2] == 0 && ip[3] != 0 {
+		domain, err := reader.ReadBytes(0)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot read a domain: %w", err)
+		}
+
+		host = string(domain[:len(domain)-1])
+	}
+
+	if command != socksCommandConnect {
+		socks4Reply(conn, socks4ReplyRejected)
+
+		return "", "", errSocksUnsupportedCommand
+	}
+
+	var credentials []byte
+
+	if len(userID) > 1 {
+		credentials = append(userID[:len(userID)-1], ':')
+	}
+
+	user, err := s.socksAuthenticate(conn, credentials)
+	if err != nil {
+		socks4Reply(conn, socks4ReplyRejected)
+
+		return "", "", err
+	}
+
+	return net.JoinHostPort(host, port), user, nil
+}
+
+// socksAuthenticate authenticates SOCKS client with authenticator
+// of the server. credentials are 'user:password' pair, nil means that
+// client has sent no credentials.
+func (s *Server) socksAuthenticate(conn net.Conn, credentials []byte) (string, error) {
+	ctx := &fasthttp.RequestCtx{}
+
+	ctx.Init2(conn, nil, false)
+
+	if credentials != nil {
+		ctx.Request.Header.Set("Proxy-Authorization",
+			"Basic "+base64.StdEncoding.EncodeToString(credentials))
+	}
+
+	user, err := s.authenticator.Authenticate(ctx)
+	if err != nil {
+		s.eventStream.Send(s.eventsCtx, events.EventTypeFailedAuth, nil, "")
+
+		return "", fmt.Errorf("authentication is failed: %w", err)
+	}
+
+	return user, nil
+}
+
+// socksReply sends a reply to CONNECT command according to a result
+// of netloc dialing.
+func socksReply(conn net.Conn, version byte, dialErr error) error {
+	if version == socks4Version {
+		if dialErr != nil {
+			return socks4Reply(conn, socks4ReplyRejected)
+		}
+
+		return socks4Reply(conn, socks4ReplyGranted)
+	}
+
+	return socks5Reply(conn, socks5DialStatus(dialErr))
+}
+
+// socks5DialStatus maps an error of netloc dialing to SOCKS5 reply
+// code.
+func socks5DialStatus(err error) byte {
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+
+	switch {
+	case err == nil:
+		return socks5ReplySucceeded
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return socks5ReplyConnectionRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return socks5ReplyNetworkUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, dialers.ErrNoIPs),
+		errors.As(err, &dnsErr),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return socks5ReplyHostUnreachable
+	}
+
+	return socks5ReplyGeneralFailure
+}
+
+func socks5Reply(conn net.Conn, status byte) error {
+	// VER REP RSV ATYP BND.ADDR BND.PORT
+	_, err := conn.Write([]byte{socks5Version, status, 0, socks5AddressIPv4, 0, 0, 0, 0, 0, 0})
+
+	return err // nolint: wrapcheck
+}
+
+func socks4Reply(conn net.Conn, status byte) error {
+	// VN CD DSTPORT DSTIP
+	_, err := conn.Write([]byte{0, status, 0, 0, 0, 0, 0, 0})
+
+	return err // nolint: wrapcheck
+}
+
+func socksReadBytes(reader *bufio.Reader) ([]byte, error) {
+	length, err := reader.ReadByte()
+	if err != nil {
+		return nil, err // nolint: wrapcheck
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err // nolint: wrapcheck
+	}
+
+	return data, nil
+}
+
+func socksReadIP(reader *bufio.Reader, length int) (string, error) {
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", err // nolint: wrapcheck
+	}
+
+	return net.IP(data).String(), nil
+}
+
+func socksReadPort(reader *bufio.Reader) (string, error) {
+	data := make([]byte, 2) // nolint: gomnd
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", err // nolint: wrapcheck
+	}
+
+	return strconv.Itoa(int(binary.BigEndian.Uint16(data))), nil
+}
@@ -0,0 +1,291 @@
+package httransform_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2"
+	"github.com/9seconds/httransform/v2/auth"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/proxy"
+)
+
+type ServerSOCKSTestSuite struct {
+	suite.Suite
+
+	httpEndpoint *httptest.Server
+	tlsEndpoint  *httptest.Server
+	echoEndpoint net.Listener
+	proxy        *httransform.Server
+	ln           net.Listener
+	ctx          context.Context
+	ctxCancel    context.CancelFunc
+}
+
+func (suite *ServerSOCKSTestSuite) SetupSuite() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	})
+
+	suite.httpEndpoint = httptest.NewServer(handler)
+	suite.tlsEndpoint = httptest.NewTLSServer(handler)
+	suite.echoEndpoint, _ = net.Listen("tcp", "127.0.0.1:0")
+
+	go func() {
+		for {
+			conn, err := suite.echoEndpoint.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+}
+
+func (suite *ServerSOCKSTestSuite) TearDownSuite() {
+	suite.httpEndpoint.Close()
+	suite.tlsEndpoint.Close()
+	suite.echoEndpoint.Close()
+}
+
+func (suite *ServerSOCKSTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+
+	opts := httransform.ServerOpts{
+		Authenticator: auth.NewBasicAuth(map[string]string{
+			"user":   "password",
+			"socks4": "",
+		}),
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		TLSSkipVerify: true,
+		Layers: []layers.Layer{
+			sniLayer{},
+		},
+	}
+
+	suite.proxy, _ = httransform.NewServer(suite.ctx, opts)
+	suite.ln, _ = net.Listen("tcp", "127.0.0.1:0")
+
+	go suite.proxy.ServeSOCKS(suite.ln)
+}
+
+func (suite *ServerSOCKSTestSuite) TearDownTest() {
+	suite.ctxCancel()
+	suite.proxy.Close()
+	suite.ln.Close()
+}
+
+func (suite *ServerSOCKSTestSuite) makeClient(password, serverName string) *http.Client {
+	dialer, _ := proxy.SOCKS5("tcp", suite.ln.Addr().String(), &proxy.Auth{
+		User:     "user",
+		Password: password,
+	}, proxy.Direct)
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial: dialer.Dial,
+			TLSClientConfig: &tls.Config{
+				ServerName:         serverName,
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: time.Second,
+	}
+}
+
+func (suite *ServerSOCKSTestSuite) TestHTTP() {
+	resp, err := suite.makeClient("password", "").Get(suite.httpEndpoint.URL)
+
+	suite.NoError(err)
+
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	parsed, _ := url.Parse(suite.httpEndpoint.URL)
+
+	suite.Equal(parsed.Host, string(data))
+	suite.Contains(resp.Header, "X-Sni")
+}
+
+func (suite *ServerSOCKSTestSuite) TestHTTPS() {
+	resp, err := suite.makeClient("password", "example.com").Get(suite.tlsEndpoint.URL)
+
+	suite.NoError(err)
+
+	defer resp.Body.Close()
+
+	suite.Equal("example.com", resp.Header.Get("X-Sni"))
+	suite.NotEqual(suite.tlsEndpoint.Certificate().Raw, resp.TLS.PeerCertificates[0].Raw)
+}
+
+func (suite *ServerSOCKSTestSuite) TestSingleDial() {
+	newConns := int32(0)
+	endpoint := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	endpoint.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	endpoint.StartTLS()
+
+	defer endpoint.Close()
+
+	client := suite.makeClient("password", "example.com")
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(endpoint.URL)
+
+		suite.Require().NoError(err)
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	suite.EqualValues(1, atomic.LoadInt32(&newConns))
+}
+
+func (suite *ServerSOCKSTestSuite) TestIncorrectPassword() {
+	_, err := suite.makeClient("incorrect", "").Get(suite.httpEndpoint.URL)
+
+	suite.Error(err)
+}
+
+func (suite *ServerSOCKSTestSuite) TestNotHTTP() {
+	dialer, _ := proxy.SOCKS5("tcp", suite.ln.Addr().String(), &proxy.Auth{
+		User:     "user",
+		Password: "password",
+	}, proxy.Direct)
+
+	conn, err := dialer.Dial("tcp", suite.echoEndpoint.Addr().String())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	_, err = conn.Write([]byte("hello\n"))
+
+	suite.NoError(err)
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+
+	suite.NoError(err)
+	suite.Equal("hello\n", line)
+}
+
+func (suite *ServerSOCKSTestSuite) TestSOCKS4a() {
+	conn, err := net.Dial("tcp", suite.ln.Addr().String())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	_, port, _ := net.SplitHostPort(suite.echoEndpoint.Addr().String())
+	portNumber, _ := net.LookupPort("tcp", port)
+	request := []byte{4, 1, 0, 0, 0, 0, 0, 1}
+
+	binary.BigEndian.PutUint16(request[2:], uint16(portNumber))
+
+	request = append(request, "socks4\x00127.0.0.1\x00hello\n"...)
+
+	_, err = conn.Write(request)
+
+	suite.NoError(err)
+
+	reader := bufio.NewReader(conn)
+	reply := make([]byte, 8)
+
+	_, err = io.ReadFull(reader, reply)
+
+	suite.NoError(err)
+	suite.EqualValues(0x5a, reply[1])
+
+	line, err := reader.ReadString('\n')
+
+	suite.NoError(err)
+	suite.Equal("hello\n", line)
+}
+
+func (suite *ServerSOCKSTestSuite) TestSOCKS4Rejected() {
+	conn, err := net.Dial("tcp", suite.ln.Addr().String())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	_, err = conn.Write([]byte{4, 1, 0, 80, 127, 0, 0, 1, 'u', 0})
+
+	suite.NoError(err)
+
+	reply := make([]byte, 8)
+
+	_, err = io.ReadFull(conn, reply)
+
+	suite.NoError(err)
+	suite.EqualValues(0x5b, reply[1])
+}
+
+func (suite *ServerSOCKSTestSuite) TestConnectionRefused() {
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := closed.Addr().(*net.TCPAddr)
+
+	closed.Close()
+
+	conn, err := net.Dial("tcp", suite.ln.Addr().String())
+
+	suite.NoError(err)
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	request := []byte{5, 1, 2, 1, 4}
+	request = append(request, "user"...)
+	request = append(request, 8)
+	request = append(request, "password"...)
+	request = append(request, 5, 1, 0, 1, 127, 0, 0, 1, 0, 0)
+
+	binary.BigEndian.PutUint16(request[len(request)-2:], uint16(addr.Port))
+
+	_, err = conn.Write(request)
+
+	suite.NoError(err)
+
+	// method selection, auth status and a reply
+	reply := make([]byte, 2+2+10)
+
+	_, err = io.ReadFull(conn, reply)
+
+	suite.NoError(err)
+	suite.EqualValues(0, reply[3])
+	suite.EqualValues(0x05, reply[5])
+}
+
+func TestServerSOCKS(t *testing.T) {
+	suite.Run(t, &ServerSOCKSTestSuite{})
+}
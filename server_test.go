@@ -10,11 +10,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/9seconds/httransform/v2"
 	"github.com/9seconds/httransform/v2/auth"
+	"github.com/9seconds/httransform/v2/dialers"
+	"github.com/9seconds/httransform/v2/events"
 	"github.com/9seconds/httransform/v2/layers"
 	"github.com/mccutchen/go-httpbin/httpbin"
 	"github.com/stretchr/testify/suite"
@@ -53,6 +56,42 @@ npjRm++Rs1AdvoIbZb52OqIoqoaVoxJnVchLD6t5LYXnecesAcok1e8CQEKB7ycJ
 4J45NsSQjuuAAWs=
 -----END PRIVATE KEY-----`)
 
+type sniLayer struct{}
+
+func (s sniLayer) OnRequest(_ *layers.Context) error {
+	return nil
+}
+
+func (s sniLayer) OnResponse(ctx *layers.Context, err error) error {
+	ctx.ResponseHeaders.Set("X-Sni", ctx.SNI, true)
+
+	return err
+}
+
+type finishCounter struct {
+	finished *int32
+}
+
+func (f finishCounter) Process(evt events.Event) {
+	if evt.Type == events.EventTypeFinishRequest {
+		atomic.AddInt32(f.finished, 1)
+	}
+}
+
+func (f finishCounter) Shutdown() {}
+
+type userDialer struct {
+	dialers.Dialer
+
+	users chan string
+}
+
+func (u userDialer) Dial(ctx context.Context, host, port string) (net.Conn, error) {
+	u.users <- dialers.User(ctx)
+
+	return u.Dialer.Dial(ctx, host, port) // nolint: wrapcheck
+}
+
 type ServerTestSuite struct {
 	suite.Suite
 
@@ -93,6 +132,10 @@ func (suite *ServerTestSuite) SetupTest() {
 			layers.TimeoutLayer{
 				Timeout: 10 * time.Second,
 			},
+			sniLayer{},
+		},
+		InterceptPolicy: func(_, _ string, hello *tls.ClientHelloInfo) bool {
+			return hello.ServerName != "passthrough.example.com"
 		},
 	}
 
@@ -185,6 +228,102 @@ func (suite *ServerTestSuite) TestHTTPSRequest() {
 	suite.NoError(json.Unmarshal(data, &v))
 }
 
+func (suite *ServerTestSuite) TestNoSNICertificate() {
+	resp, err := suite.http.Get(suite.tlsEndpoint.URL + "/ip")
+
+	defer func() {
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	suite.NoError(err)
+	suite.Len(resp.TLS.PeerCertificates[0].IPAddresses, 1)
+	suite.Equal("127.0.0.1", resp.TLS.PeerCertificates[0].IPAddresses[0].String())
+	suite.Empty(resp.Header.Get("X-Sni"))
+}
+
+func (suite *ServerTestSuite) TestPassThrough() {
+	transport := suite.http.Transport.(*http.Transport)
+	transport.TLSClientConfig.ServerName = "passthrough.example.com"
+
+	resp, err := suite.http.Get(suite.tlsEndpoint.URL + "/ip")
+
+	defer func() {
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	suite.NoError(err)
+	suite.Equal(suite.tlsEndpoint.Certificate().Raw, resp.TLS.PeerCertificates[0].Raw)
+	suite.Empty(resp.Header.Get("X-Sni"))
+}
+
+func (suite *ServerTestSuite) TestPassThroughTunnelDialer() {
+	dialer := userDialer{
+		Dialer: dialers.NewBase(dialers.Opts{}),
+		users:  make(chan string, 1),
+	}
+	proxy, err := httransform.NewServer(suite.ctx, httransform.ServerOpts{
+		Authenticator: auth.NewBasicAuth(map[string]string{
+			"user": "password",
+		}),
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		Layers: []layers.Layer{
+			layers.UserDialerLayer{
+				Dialers: map[string]dialers.Dialer{
+					"user": dialer,
+				},
+			},
+		},
+		InterceptPolicy: func(_, _ string, _ *tls.ClientHelloInfo) bool {
+			return false
+		},
+	})
+
+	suite.Require().NoError(err)
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	defer ln.Close()
+	defer proxy.Close()
+
+	go proxy.Serve(ln)
+
+	httpProxyURL, _ := url.Parse("http://" + ln.Addr().String())
+	httpProxyURL.User = url.UserPassword("user", "password")
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(httpProxyURL),
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: time.Second,
+	}
+
+	resp, err := client.Get(suite.tlsEndpoint.URL + "/ip")
+
+	suite.Require().NoError(err)
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	suite.Equal(suite.tlsEndpoint.Certificate().Raw, resp.TLS.PeerCertificates[0].Raw)
+
+	select {
+	case user := <-dialer.users:
+		suite.Equal("user", user)
+	case <-time.After(time.Second):
+		suite.Fail("tunnel dialer is not used")
+	}
+}
+
 func (suite *ServerTestSuite) TestHTTPAuthRequired() {
 	httpProxyURL, _ := url.Parse("http://" + suite.ln.Addr().String())
 
@@ -227,6 +366,104 @@ func (suite *ServerTestSuite) TestGolangOrg() {
 	suite.Equal(http.StatusOK, resp.StatusCode)
 }
 
+func (suite *ServerTestSuite) TestCloseProcessesFinish() {
+	started := make(chan struct{}, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+
+	defer endpoint.Close()
+
+	finished := int32(0)
+	proxy, err := httransform.NewServer(context.Background(), httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		EventProcessorFactory: func() events.Processor {
+			return finishCounter{finished: &finished}
+		},
+	})
+
+	suite.Require().NoError(err)
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	defer ln.Close()
+
+	go proxy.Serve(ln)
+
+	httpProxyURL, _ := url.Parse("http://" + ln.Addr().String())
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(httpProxyURL),
+		},
+		Timeout: 3 * time.Second,
+	}
+	errChan := make(chan error, 1)
+
+	go func() {
+		resp, err := client.Get(endpoint.URL)
+		if err == nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		errChan <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		suite.FailNow("request has not reached netloc")
+	}
+
+	suite.NoError(proxy.Close())
+	suite.NoError(<-errChan)
+	suite.EqualValues(1, atomic.LoadInt32(&finished))
+}
+
+func (suite *ServerTestSuite) TestExplicitExecutor() {
+	proxy, err := httransform.NewServer(suite.ctx, httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		Executor: func(ctx *layers.Context) error {
+			ctx.Response().SetBodyString("explicit")
+
+			return nil
+		},
+	})
+
+	suite.Require().NoError(err)
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	defer ln.Close()
+	defer proxy.Close()
+
+	go proxy.Serve(ln)
+
+	httpProxyURL, _ := url.Parse("http://" + ln.Addr().String())
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(httpProxyURL),
+		},
+		Timeout: time.Second,
+	}
+
+	resp, err := client.Get(suite.httpEndpoint.URL + "/ip")
+
+	suite.Require().NoError(err)
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	suite.NoError(err)
+	suite.Equal("explicit", string(data))
+}
+
 func TestServer(t *testing.T) {
 	suite.Run(t, &ServerTestSuite{})
 }
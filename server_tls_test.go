@@ -0,0 +1,258 @@
+package httransform_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/mccutchen/go-httpbin/httpbin"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/http2"
+)
+
+// ServerTLSTestSuite runs a proxy which does not verify TLS
+// certificates of netlocs.
+type ServerTLSTestSuite struct {
+	suite.Suite
+
+	tlsEndpoint *httptest.Server
+	slowStarted chan struct{}
+	slowDone    chan struct{}
+	proxy       *httransform.Server
+	ln          net.Listener
+	ctx         context.Context
+	ctxCancel   context.CancelFunc
+	http        *http.Client
+}
+
+func (suite *ServerTLSTestSuite) SetupSuite() {
+	httpbinApp := httpbin.NewHTTPBin()
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ip", httpbinApp.IP)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started, done := suite.slowStarted, suite.slowDone
+
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			return
+		}
+
+		select {
+		case done <- struct{}{}:
+		default:
+		}
+	})
+
+	suite.tlsEndpoint = httptest.NewTLSServer(mux)
+}
+
+func (suite *ServerTLSTestSuite) TearDownSuite() {
+	suite.tlsEndpoint.Close()
+}
+
+func (suite *ServerTLSTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+	suite.slowStarted = make(chan struct{}, 1)
+	suite.slowDone = make(chan struct{}, 1)
+
+	opts := httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		TLSSkipVerify: true,
+		Layers: []layers.Layer{
+			sniLayer{},
+		},
+	}
+
+	suite.proxy, _ = httransform.NewServer(suite.ctx, opts)
+	suite.ln, _ = net.Listen("tcp", "127.0.0.1:0")
+
+	go suite.proxy.Serve(suite.ln)
+
+	httpProxyURL, _ := url.Parse("http://" + suite.ln.Addr().String())
+
+	suite.http = &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(httpProxyURL),
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: 3 * time.Second,
+	}
+}
+
+func (suite *ServerTLSTestSuite) TearDownTest() {
+	suite.ctxCancel()
+	suite.proxy.Close()
+	suite.ln.Close()
+}
+
+// dialHTTP2 establishes h2 connection to the proxy through CONNECT
+// tunnel and sends client preface.
+func (suite *ServerTLSTestSuite) dialHTTP2() (*tls.Conn, *http2.Framer) {
+	address := suite.tlsEndpoint.Listener.Addr().String()
+
+	conn, err := net.Dial("tcp", suite.ln.Addr().String())
+	suite.Require().NoError(err)
+
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", address, address)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	suite.Require().NoError(err)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		InsecureSkipVerify: true, // nolint: gosec
+		NextProtos:         []string{http2.NextProtoTLS},
+	})
+
+	suite.Require().NoError(tlsConn.Handshake())
+	suite.Require().Equal(http2.NextProtoTLS, tlsConn.ConnectionState().NegotiatedProtocol)
+
+	tlsConn.Write([]byte(http2.ClientPreface))
+
+	framer := http2.NewFramer(tlsConn, tlsConn)
+
+	suite.Require().NoError(framer.WriteSettings())
+
+	return tlsConn, framer
+}
+
+func (suite *ServerTLSTestSuite) TestHTTP2Request() {
+	transport := suite.http.Transport.(*http.Transport)
+	transport.ForceAttemptHTTP2 = true
+
+	resp, err := suite.http.Get(suite.tlsEndpoint.URL + "/ip")
+
+	defer func() {
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	suite.NoError(err)
+	suite.Equal(2, resp.ProtoMajor)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	suite.NoError(err)
+
+	v := map[string]interface{}{}
+
+	suite.NoError(json.Unmarshal(data, &v))
+}
+
+func (suite *ServerTLSTestSuite) TestHTTP2Cancel() {
+	transport := suite.http.Transport.(*http.Transport)
+	transport.ForceAttemptHTTP2 = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, suite.tlsEndpoint.URL+"/slow", nil)
+	errChan := make(chan error, 1)
+
+	go func() {
+		resp, err := suite.http.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		errChan <- err
+	}()
+
+	select {
+	case <-suite.slowStarted:
+	case <-time.After(3 * time.Second):
+		suite.FailNow("request has not reached netloc")
+	}
+
+	cancel()
+
+	select {
+	case <-suite.slowDone:
+	case <-time.After(3 * time.Second):
+		suite.FailNow("netloc request is not cancelled")
+	}
+
+	suite.Error(<-errChan)
+}
+
+func (suite *ServerTLSTestSuite) TestHTTP2Close() {
+	conn, framer := suite.dialHTTP2()
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	// server sends its settings when connection is served.
+	for {
+		frame, err := framer.ReadFrame()
+		suite.Require().NoError(err)
+
+		if _, ok := frame.(*http2.SettingsFrame); ok {
+			break
+		}
+	}
+
+	suite.NoError(suite.proxy.Close())
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			var netErr net.Error
+
+			suite.False(errors.As(err, &netErr) && netErr.Timeout(), "connection is alive")
+
+			return
+		}
+
+		if _, ok := frame.(*http2.GoAwayFrame); ok {
+			return
+		}
+	}
+}
+
+func (suite *ServerTLSTestSuite) TestSNICertificate() {
+	transport := suite.http.Transport.(*http.Transport)
+	transport.TLSClientConfig.ServerName = "sni.example.com"
+
+	resp, err := suite.http.Get(suite.tlsEndpoint.URL + "/ip")
+
+	defer func() {
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	suite.NoError(err)
+	suite.Equal("sni.example.com", resp.TLS.PeerCertificates[0].Subject.CommonName)
+	suite.Equal("sni.example.com", resp.Header.Get("X-Sni"))
+}
+
+func TestServerTLS(t *testing.T) {
+	suite.Run(t, &ServerTLSTestSuite{})
+}
@@ -0,0 +1,225 @@
+package httransform_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/9seconds/httransform/v2/tracing"
+	"github.com/9seconds/httransform/v2/tracing/opentelemetry"
+	"github.com/stretchr/testify/suite"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type ServerTracingTestSuite struct {
+	suite.Suite
+
+	httpEndpoint *httptest.Server
+	tlsEndpoint  *httptest.Server
+	exporter     *tracing.InMemoryExporter
+	proxy        *httransform.Server
+	ln           net.Listener
+	ctx          context.Context
+	ctxCancel    context.CancelFunc
+	http         *http.Client
+}
+
+func (suite *ServerTracingTestSuite) SetupSuite() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Traceparent"))
+	})
+
+	suite.httpEndpoint = httptest.NewServer(handler)
+	suite.tlsEndpoint = httptest.NewTLSServer(handler)
+}
+
+func (suite *ServerTracingTestSuite) TearDownSuite() {
+	suite.httpEndpoint.Close()
+	suite.tlsEndpoint.Close()
+}
+
+func (suite *ServerTracingTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+	suite.exporter = &tracing.InMemoryExporter{}
+
+	suite.startProxy(tracing.NewTracer(tracing.TracerOpts{
+		Exporter: suite.exporter,
+	}))
+}
+
+func (suite *ServerTracingTestSuite) startProxy(tracer tracing.Tracer) {
+	opts := httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		TLSSkipVerify: true,
+		Tracer:        tracer,
+		Layers: []layers.Layer{
+			sniLayer{},
+		},
+	}
+
+	suite.proxy, _ = httransform.NewServer(suite.ctx, opts)
+	suite.ln, _ = net.Listen("tcp", "127.0.0.1:0")
+
+	go suite.proxy.Serve(suite.ln)
+
+	httpProxyURL, _ := url.Parse("http://" + suite.ln.Addr().String())
+
+	suite.http = &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(httpProxyURL),
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: time.Second,
+	}
+}
+
+func (suite *ServerTracingTestSuite) TearDownTest() {
+	suite.ctxCancel()
+	suite.proxy.Close()
+	suite.ln.Close()
+}
+
+func (suite *ServerTracingTestSuite) get(url, traceparent string) string {
+	req, _ := http.NewRequest("GET", url, nil)
+
+	if traceparent != "" {
+		req.Header.Set("Traceparent", traceparent)
+	}
+
+	resp, err := suite.http.Do(req)
+	suite.Require().NoError(err)
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+
+	return string(data)
+}
+
+// spans are exported after response is sent so we have to wait a
+// little.
+func (suite *ServerTracingTestSuite) requestSpan() tracing.SpanData {
+	var span tracing.SpanData
+
+	suite.Eventually(func() bool {
+		for _, v := range suite.exporter.Spans() {
+			if v.Name == "request" {
+				span = v
+
+				return true
+			}
+		}
+
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	return span
+}
+
+func (suite *ServerTracingTestSuite) TestHTTP() {
+	traceparent := suite.get(suite.httpEndpoint.URL, "")
+	span := suite.requestSpan()
+
+	suite.Equal(span.SpanContext.Traceparent(), traceparent)
+	suite.False(span.Parent.IsValid())
+	suite.Equal("GET", span.Attributes["http.method"])
+	suite.Equal(http.StatusOK, span.Attributes["http.status_code"])
+	suite.NoError(span.Err)
+
+	names := map[string]tracing.SpanID{}
+
+	for _, v := range suite.exporter.Spans() {
+		suite.Equal(span.SpanContext.TraceID, v.SpanContext.TraceID)
+
+		names[v.Name] = v.Parent
+	}
+
+	suite.Equal(span.SpanContext.SpanID, names["dial"])
+	suite.Equal(span.SpanContext.SpanID, names["execute"])
+	suite.Equal(span.SpanContext.SpanID, names["httransform_test.sniLayer.OnRequest"])
+	suite.Equal(span.SpanContext.SpanID, names["httransform_test.sniLayer.OnResponse"])
+}
+
+func (suite *ServerTracingTestSuite) TestHTTPS() {
+	traceparent := suite.get(suite.tlsEndpoint.URL, "")
+	span := suite.requestSpan()
+
+	suite.Equal(span.SpanContext.Traceparent(), traceparent)
+
+	names := map[string]bool{}
+
+	for _, v := range suite.exporter.Spans() {
+		names[v.Name] = true
+	}
+
+	suite.True(names["tls_handshake"])
+}
+
+func (suite *ServerTracingTestSuite) TestRemoteParent() {
+	parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	traceparent := suite.get(suite.httpEndpoint.URL, parent)
+	span := suite.requestSpan()
+
+	suite.Equal(span.SpanContext.Traceparent(), traceparent)
+	suite.Equal("0af7651916cd43dd8448eb211c80319c", span.SpanContext.TraceID.String())
+	suite.Equal("b7ad6b7169203331", span.Parent.String())
+	suite.True(span.Remote)
+}
+
+func (suite *ServerTracingTestSuite) TestOpenTelemetry() {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	defer provider.Shutdown(context.Background())
+
+	suite.proxy.Close()
+	suite.ln.Close()
+	suite.startProxy(opentelemetry.NewTracer(provider.Tracer("test")))
+
+	traceparent := suite.get(suite.httpEndpoint.URL, "")
+
+	var requestSpan tracetest.SpanStub
+
+	suite.Eventually(func() bool {
+		for _, v := range exporter.GetSpans() {
+			if v.Name == "request" {
+				requestSpan = v
+
+				return true
+			}
+		}
+
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	suite.Equal(requestSpan.SpanContext.TraceID().String(), traceparent[3:35])
+
+	names := map[string]string{}
+
+	for _, v := range exporter.GetSpans() {
+		suite.Equal(requestSpan.SpanContext.TraceID(), v.SpanContext.TraceID())
+
+		names[v.Name] = v.Parent.SpanID().String()
+	}
+
+	suite.Equal(requestSpan.SpanContext.SpanID().String(), names["dial"])
+	suite.Equal(requestSpan.SpanContext.SpanID().String(), names["execute"])
+}
+
+func TestServerTracing(t *testing.T) {
+	suite.Run(t, &ServerTracingTestSuite{})
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import "context"
+
+// InstrumentationName is a name of tracers which are used by
+// StartSpan.
+const InstrumentationName = "github.com/9seconds/httransform/v2"
+
+type (
+	spanKey         struct{}
+	remoteParentKey struct{}
+)
+
+// ContextWithSpan returns a context which carries a given span. Spans
+// started with such context are children of this span.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns a span from a context. It returns nil if
+// context has no span.
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+
+	return nil
+}
+
+// ContextWithRemoteParent returns a context which carries a span
+// context received from another service.
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteParentKey{}, parent)
+}
+
+// RemoteParentFromContext returns a span context received from
+// another service. It returns invalid span context if context has
+// none.
+func RemoteParentFromContext(ctx context.Context) SpanContext {
+	parent, _ := ctx.Value(remoteParentKey{}).(SpanContext)
+
+	return parent
+}
+
+// StartSpan starts a child span of a span from a given context with
+// the same tracer. If context has no span, tracing is disabled and
+// a span which does nothing is returned.
+//
+// This is how dialers and executors trace their phases: they do not
+// know a tracer but get a span of the request with context.
+func StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	if parent := SpanFromContext(ctx); parent != nil {
+		return parent.TracerProvider().Tracer(InstrumentationName).Start(ctx, name)
+	}
+
+	return ctx, noopSpan{}
+}
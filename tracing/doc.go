@@ -0,0 +1,25 @@
+// Tracing of the request pipeline.
+//
+// httransform core does not depend on any tracing SDK. Instead, it
+// defines small Tracer and Span interfaces which follow OpenTelemetry
+// API. Package tracing/opentelemetry adapts OpenTelemetry tracers to
+// them. NewTracer is a simple implementation which passes finished
+// spans to an Exporter; InMemoryExporter is useful for tests.
+//
+//     exporter := &tracing.InMemoryExporter{}
+//
+//     opts := httransform.ServerOpts{
+//         Tracer: tracing.NewTracer(tracing.TracerOpts{
+//             Exporter: exporter,
+//             Sampler:  tracing.TraceIDRatioBased(0.1),
+//         }),
+//     }
+//
+// Server starts a span for each request and child spans for each layer
+// and for dial, TLS handshake and request execution. If a client has
+// sent W3C traceparent header, request span becomes its child. A span
+// context of the request is sent to the netloc in traceparent header.
+//
+// Dialers and executors do not know a tracer: they start child spans
+// with StartSpan which takes a span of the request from a context.
+package tracing
@@ -0,0 +1,9 @@
+package tracing
+
+import "github.com/9seconds/httransform/v2/errors"
+
+// ErrIncorrectTraceparent is returned if traceparent header has
+// incorrect format.
+var ErrIncorrectTraceparent = &errors.Error{
+	Message: "incorrect traceparent",
+}
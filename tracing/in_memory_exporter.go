@@ -0,0 +1,34 @@
+package tracing
+
+import "sync"
+
+// InMemoryExporter keeps finished spans in memory. It is intended to
+// be used in tests.
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []SpanData
+}
+
+// Export conforms Exporter interface.
+func (i *InMemoryExporter) Export(data SpanData) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.spans = append(i.spans, data)
+}
+
+// Spans returns a list of exported spans in the order of their end.
+func (i *InMemoryExporter) Spans() []SpanData {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return append([]SpanData{}, i.spans...)
+}
+
+// Reset drops all exported spans.
+func (i *InMemoryExporter) Reset() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.spans = nil
+}
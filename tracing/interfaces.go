@@ -0,0 +1,46 @@
+package tracing
+
+import "context"
+
+// Tracer starts spans. Its signature follows OpenTelemetry tracers so
+// it is trivial to make an adapter if you use OpenTelemetry SDK.
+type Tracer interface {
+	// Start starts a new span. A parent is taken from a context: it is
+	// either a span (see ContextWithSpan) or a remote span context
+	// (see ContextWithRemoteParent). Returned context carries a new
+	// span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// TracerProvider gives tracers. It follows OpenTelemetry tracer
+// providers.
+type TracerProvider interface {
+	// Tracer returns a tracer for a given instrumentation name.
+	Tracer(name string) Tracer
+}
+
+// Span is a single operation within a trace.
+type Span interface {
+	// SpanContext returns identifiers of the span.
+	SpanContext() SpanContext
+
+	// TracerProvider returns a provider of a tracer which has started
+	// this span. Child spans are started with its tracers.
+	TracerProvider() TracerProvider
+
+	// SetAttribute sets an attribute of the span.
+	SetAttribute(key string, value interface{})
+
+	// RecordError marks span as failed.
+	RecordError(err error)
+
+	// End finishes the span. Spans must not be updated after that.
+	End()
+}
+
+// Exporter receives finished spans of Tracer made by NewTracer.
+type Exporter interface {
+	// Export is called once span is finished. It is called in a
+	// goroutine which finishes a span so it should not block.
+	Export(SpanData)
+}
@@ -0,0 +1,24 @@
+package tracing
+
+import "context"
+
+// NoopTracer is a tracer which records nothing.
+type NoopTracer struct{}
+
+// Start conforms Tracer interface.
+func (n NoopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// Tracer conforms TracerProvider interface.
+func (n NoopTracer) Tracer(_ string) Tracer {
+	return n
+}
+
+type noopSpan struct{}
+
+func (n noopSpan) SpanContext() SpanContext             { return SpanContext{} }
+func (n noopSpan) TracerProvider() TracerProvider       { return NoopTracer{} }
+func (n noopSpan) SetAttribute(_ string, _ interface{}) {}
+func (n noopSpan) RecordError(_ error)                  {}
+func (n noopSpan) End()                                 {}
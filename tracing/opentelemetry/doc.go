@@ -0,0 +1,20 @@
+// Adapter of OpenTelemetry tracers.
+//
+// httransform does not depend on OpenTelemetry SDK in its core
+// packages. If you use it, wrap your tracer with NewTracer and pass it
+// to the server:
+//
+//     provider := sdktrace.NewTracerProvider(
+//         sdktrace.WithBatcher(exporter),
+//         sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.1))),
+//     )
+//
+//     opts := httransform.ServerOpts{
+//         Tracer: opentelemetry.NewTracer(provider.Tracer(tracing.InstrumentationName)),
+//     }
+//
+// Spans are started by OpenTelemetry so its samplers, processors and
+// exporters work as usual. Remote parents which come with W3C
+// traceparent header are passed to OpenTelemetry as remote span
+// contexts.
+package opentelemetry
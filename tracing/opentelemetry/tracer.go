@@ -0,0 +1,120 @@
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/9seconds/httransform/v2/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+	otelCtx := ctx
+
+	if parent := tracing.SpanFromContext(ctx); parent != nil {
+		if parentSpan, ok := parent.(span); ok {
+			otelCtx = trace.ContextWithSpan(otelCtx, parentSpan.span)
+		} else {
+			otelCtx = trace.ContextWithSpanContext(otelCtx, toSpanContext(parent.SpanContext()))
+		}
+	} else if parent := tracing.RemoteParentFromContext(ctx); parent.IsValid() {
+		otelCtx = trace.ContextWithRemoteSpanContext(otelCtx, toSpanContext(parent))
+	}
+
+	otelCtx, otelSpan := t.tracer.Start(otelCtx, name)
+	rv := span{
+		span: otelSpan,
+	}
+
+	return tracing.ContextWithSpan(otelCtx, rv), rv
+}
+
+type tracerProvider struct {
+	provider trace.TracerProvider
+}
+
+func (t tracerProvider) Tracer(name string) tracing.Tracer {
+	return NewTracer(t.provider.Tracer(name))
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SpanContext() tracing.SpanContext {
+	spanContext := s.span.SpanContext()
+
+	return tracing.SpanContext{
+		TraceID: tracing.TraceID(spanContext.TraceID()),
+		SpanID:  tracing.SpanID(spanContext.SpanID()),
+		Sampled: spanContext.IsSampled(),
+	}
+}
+
+func (s span) TracerProvider() tracing.TracerProvider {
+	return tracerProvider{
+		provider: s.span.TracerProvider(),
+	}
+}
+
+func (s span) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(toAttribute(key, value))
+}
+
+func (s span) RecordError(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func (s span) End() {
+	s.span.End()
+}
+
+func toSpanContext(spanContext tracing.SpanContext) trace.SpanContext {
+	var flags trace.TraceFlags
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID(spanContext.TraceID),
+		SpanID:     trace.SpanID(spanContext.SpanID),
+		TraceFlags: flags.WithSampled(spanContext.Sampled),
+	})
+}
+
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	}
+
+	return attribute.String(key, fmt.Sprint(value))
+}
+
+// NewTracer returns a tracer which starts spans with a given
+// OpenTelemetry tracer.
+//
+// Child spans which are started with tracing.StartSpan use a tracer
+// of the same OpenTelemetry provider named as
+// tracing.InstrumentationName.
+func NewTracer(otelTracer trace.Tracer) tracing.Tracer {
+	return tracer{
+		tracer: otelTracer,
+	}
+}
@@ -0,0 +1,118 @@
+package opentelemetry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/tracing"
+	"github.com/9seconds/httransform/v2/tracing/opentelemetry"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type TracerTestSuite struct {
+	suite.Suite
+
+	exporter *tracetest.InMemoryExporter
+	provider *sdktrace.TracerProvider
+	tracer   tracing.Tracer
+}
+
+func (suite *TracerTestSuite) SetupTest() {
+	suite.exporter = tracetest.NewInMemoryExporter()
+	suite.provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(suite.exporter))
+	suite.tracer = opentelemetry.NewTracer(suite.provider.Tracer("test"))
+}
+
+func (suite *TracerTestSuite) TearDownTest() {
+	suite.provider.Shutdown(context.Background())
+}
+
+func (suite *TracerTestSuite) TestRoot() {
+	ctx, span := suite.tracer.Start(context.Background(), "root")
+
+	suite.True(span.SpanContext().IsValid())
+	suite.True(span.SpanContext().Sampled)
+	suite.Equal(span, tracing.SpanFromContext(ctx))
+
+	span.SetAttribute("key", "value")
+	span.SetAttribute("status", 200)
+	span.RecordError(errors.New("err"))
+	span.End()
+
+	spans := suite.exporter.GetSpans()
+
+	suite.Require().Len(spans, 1)
+	suite.Equal("root", spans[0].Name)
+	suite.Equal(span.SpanContext().TraceID, tracing.TraceID(spans[0].SpanContext.TraceID()))
+	suite.Equal(span.SpanContext().SpanID, tracing.SpanID(spans[0].SpanContext.SpanID()))
+	suite.False(spans[0].Parent.IsValid())
+	suite.ElementsMatch([]attribute.KeyValue{
+		attribute.String("key", "value"),
+		attribute.Int("status", 200),
+	}, spans[0].Attributes)
+	suite.Equal(codes.Error, spans[0].Status.Code)
+	suite.Equal("err", spans[0].Status.Description)
+}
+
+func (suite *TracerTestSuite) TestChild() {
+	ctx, root := suite.tracer.Start(context.Background(), "root")
+	_, child := tracing.StartSpan(ctx, "child")
+
+	child.End()
+	root.End()
+
+	spans := suite.exporter.GetSpans()
+
+	suite.Require().Len(spans, 2)
+	suite.Equal("child", spans[0].Name)
+	suite.Equal(tracing.InstrumentationName, spans[0].InstrumentationLibrary.Name)
+	suite.Equal(root.SpanContext().TraceID, tracing.TraceID(spans[0].SpanContext.TraceID()))
+	suite.Equal(root.SpanContext().SpanID, tracing.SpanID(spans[0].Parent.SpanID()))
+	suite.False(spans[0].Parent.IsRemote())
+}
+
+func (suite *TracerTestSuite) TestRemoteParent() {
+	parent, _ := tracing.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx := tracing.ContextWithRemoteParent(context.Background(), parent)
+
+	_, span := suite.tracer.Start(ctx, "root")
+
+	span.End()
+
+	spans := suite.exporter.GetSpans()
+
+	suite.Require().Len(spans, 1)
+	suite.Equal(parent.TraceID, tracing.TraceID(spans[0].SpanContext.TraceID()))
+	suite.Equal(parent.SpanID, tracing.SpanID(spans[0].Parent.SpanID()))
+	suite.True(spans[0].Parent.IsRemote())
+}
+
+func (suite *TracerTestSuite) TestSampler() {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(suite.exporter),
+		sdktrace.WithSampler(sdktrace.NeverSample()))
+	tracer := opentelemetry.NewTracer(provider.Tracer("test"))
+
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := tracer.Start(context.Background(), "root")
+	_, child := tracing.StartSpan(ctx, "child")
+
+	suite.True(span.SpanContext().IsValid())
+	suite.False(span.SpanContext().Sampled)
+	suite.Equal(span.SpanContext().TraceID, child.SpanContext().TraceID)
+
+	child.End()
+	span.End()
+
+	suite.Empty(suite.exporter.GetSpans())
+}
+
+func TestTracer(t *testing.T) {
+	suite.Run(t, &TracerTestSuite{})
+}
@@ -0,0 +1,20 @@
+package tracing
+
+// TracerOpts defines a set of options for NewTracer.
+type TracerOpts struct {
+	// Exporter receives finished spans which are sampled.
+	Exporter Exporter
+
+	// Sampler decides if root spans are sampled. Default is
+	// AlwaysSample.
+	Sampler Sampler
+}
+
+// GetSampler returns a sampler or fallbacks to default one.
+func (t *TracerOpts) GetSampler() Sampler {
+	if t.Sampler == nil {
+		return AlwaysSample()
+	}
+
+	return t.Sampler
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Sampler decides if a trace with a given identifier is recorded. It
+// is asked only for root spans: other ones follow their parents.
+type Sampler func(TraceID) bool
+
+// AlwaysSample returns a sampler which records all traces.
+func AlwaysSample() Sampler {
+	return func(TraceID) bool {
+		return true
+	}
+}
+
+// NeverSample returns a sampler which records no traces. Span contexts
+// are still propagated to netlocs.
+func NeverSample() Sampler {
+	return func(TraceID) bool {
+		return false
+	}
+}
+
+// TraceIDRatioBased returns a sampler which records a given fraction
+// of traces. Decision is made by the lower half of trace identifier
+// as OpenTelemetry does so all services which use the same ratio
+// agree on it.
+func TraceIDRatioBased(fraction float64) Sampler {
+	switch {
+	case fraction >= 1:
+		return AlwaysSample()
+	case fraction <= 0:
+		return NeverSample()
+	}
+
+	upperBound := uint64(fraction * math.MaxInt64)
+
+	return func(traceID TraceID) bool {
+		return binary.BigEndian.Uint64(traceID[8:])>>1 < upperBound
+	}
+}
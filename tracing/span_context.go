@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const traceparentVersion = "00"
+
+// TraceID is an identifier of the trace.
+type TraceID [16]byte
+
+// IsValid checks if identifier is not zero.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String conforms fmt.Stringer interface.
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID is an identifier of the span.
+type SpanID [8]byte
+
+// IsValid checks if identifier is not zero.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// String conforms fmt.Stringer interface.
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext defines identifiers of the span which are propagated
+// between services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Sampled defines if span is recorded.
+	Sampled bool
+}
+
+// IsValid checks if both identifiers are not zero.
+func (s SpanContext) IsValid() bool {
+	return s.TraceID.IsValid() && s.SpanID.IsValid()
+}
+
+// Traceparent returns a value of W3C traceparent header.
+func (s SpanContext) Traceparent() string {
+	flags := "00"
+
+	if s.Sampled {
+		flags = "01"
+	}
+
+	return traceparentVersion + "-" + s.TraceID.String() + "-" + s.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a value of W3C traceparent header.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+
+	// future versions can have more fields.
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, ErrIncorrectTraceparent
+	}
+
+	rv := SpanContext{}
+
+	if err := decodeHex(rv.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, fmt.Errorf("incorrect trace id: %w", err)
+	}
+
+	if err := decodeHex(rv.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, fmt.Errorf("incorrect span id: %w", err)
+	}
+
+	flags := [1]byte{}
+
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, fmt.Errorf("incorrect flags: %w", err)
+	}
+
+	rv.Sampled = flags[0]&1 != 0
+
+	if !rv.IsValid() {
+		return SpanContext{}, ErrIncorrectTraceparent
+	}
+
+	return rv, nil
+}
+
+func decodeHex(dst []byte, value string) error {
+	if len(value) != 2*len(dst) || strings.ToLower(value) != value {
+		return ErrIncorrectTraceparent
+	}
+
+	if _, err := hex.Decode(dst, []byte(value)); err != nil {
+		return err // nolint: wrapcheck
+	}
+
+	return nil
+}
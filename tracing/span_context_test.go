@@ -0,0 +1,66 @@
+package tracing_test
+
+import (
+	"testing"
+
+	"github.com/9seconds/httransform/v2/tracing"
+	"github.com/stretchr/testify/suite"
+)
+
+type SpanContextTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SpanContextTestSuite) TestParse() {
+	spanContext, err := tracing.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	suite.NoError(err)
+	suite.True(spanContext.IsValid())
+	suite.True(spanContext.Sampled)
+	suite.Equal("0af7651916cd43dd8448eb211c80319c", spanContext.TraceID.String())
+	suite.Equal("b7ad6b7169203331", spanContext.SpanID.String())
+	suite.Equal("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", spanContext.Traceparent())
+}
+
+func (suite *SpanContextTestSuite) TestNotSampled() {
+	spanContext, err := tracing.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+
+	suite.NoError(err)
+	suite.False(spanContext.Sampled)
+	suite.Equal("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", spanContext.Traceparent())
+}
+
+func (suite *SpanContextTestSuite) TestFutureVersion() {
+	spanContext, err := tracing.ParseTraceparent("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-xxx")
+
+	suite.NoError(err)
+	suite.True(spanContext.IsValid())
+}
+
+func (suite *SpanContextTestSuite) TestIncorrect() {
+	testData := []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-xxx",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c8031-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-zz",
+	}
+
+	for _, v := range testData {
+		value := v
+
+		suite.T().Run(value, func(t *testing.T) {
+			_, err := tracing.ParseTraceparent(value)
+
+			suite.Error(err)
+		})
+	}
+}
+
+func TestSpanContext(t *testing.T) {
+	suite.Run(t, &SpanContextTestSuite{})
+}
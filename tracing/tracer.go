@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/valyala/fastrand"
+)
+
+// SpanData is a snapshot of finished span which is passed to
+// exporters.
+type SpanData struct {
+	Name        string
+	SpanContext SpanContext
+
+	// Parent is an identifier of a parent span. It is invalid for
+	// root spans.
+	Parent SpanID
+
+	// Remote is true if parent span belongs to another service.
+	Remote bool
+
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+
+	// Err is an error recorded with Span.RecordError.
+	Err error
+}
+
+type tracer struct {
+	exporter Exporter
+	sampler  Sampler
+}
+
+// Tracer conforms TracerProvider interface. All spans are exported
+// with the same exporter so instrumentation name is ignored.
+func (t *tracer) Tracer(_ string) Tracer {
+	return t
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	rv := &span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			StartTime:  time.Now(),
+			Attributes: map[string]interface{}{},
+		},
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		parentContext := parent.SpanContext()
+
+		rv.data.SpanContext.TraceID = parentContext.TraceID
+		rv.data.SpanContext.Sampled = parentContext.Sampled
+		rv.data.Parent = parentContext.SpanID
+	} else if parentContext := RemoteParentFromContext(ctx); parentContext.IsValid() {
+		rv.data.SpanContext.TraceID = parentContext.TraceID
+		rv.data.SpanContext.Sampled = parentContext.Sampled
+		rv.data.Parent = parentContext.SpanID
+		rv.data.Remote = true
+	} else {
+		for !rv.data.SpanContext.TraceID.IsValid() {
+			binary.BigEndian.PutUint64(rv.data.SpanContext.TraceID[:8], randUint64())
+			binary.BigEndian.PutUint64(rv.data.SpanContext.TraceID[8:], randUint64())
+		}
+
+		rv.data.SpanContext.Sampled = t.sampler(rv.data.SpanContext.TraceID)
+	}
+
+	for !rv.data.SpanContext.SpanID.IsValid() {
+		binary.BigEndian.PutUint64(rv.data.SpanContext.SpanID[:], randUint64())
+	}
+
+	return ContextWithSpan(ctx, rv), rv
+}
+
+type span struct {
+	tracer *tracer
+	mutex  sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) TracerProvider() TracerProvider {
+	return s.tracer
+}
+
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.ended {
+		s.data.Attributes[key] = value
+	}
+}
+
+func (s *span) RecordError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.ended && err != nil {
+		s.data.Err = err
+	}
+}
+
+func (s *span) End() {
+	s.mutex.Lock()
+
+	if s.ended {
+		s.mutex.Unlock()
+
+		return
+	}
+
+	s.ended = true
+	s.data.EndTime = time.Now()
+	s.mutex.Unlock()
+
+	// spans which are not sampled are propagated but not recorded.
+	if s.data.SpanContext.Sampled {
+		s.tracer.exporter.Export(s.data)
+	}
+}
+
+func randUint64() uint64 {
+	return uint64(fastrand.Uint32())<<32 | uint64(fastrand.Uint32()) // nolint: gomnd
+}
+
+// NewTracer returns a tracer which generates W3C compatible
+// identifiers and passes finished spans to an exporter.
+//
+// Children spans are sampled if their parent span is sampled. Root
+// spans are sampled by TracerOpts.Sampler.
+func NewTracer(opts TracerOpts) Tracer {
+	return &tracer{
+		exporter: opts.Exporter,
+		sampler:  opts.GetSampler(),
+	}
+}
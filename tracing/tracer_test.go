@@ -0,0 +1,148 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/9seconds/httransform/v2/tracing"
+	"github.com/stretchr/testify/suite"
+)
+
+type TracerTestSuite struct {
+	suite.Suite
+
+	exporter *tracing.InMemoryExporter
+	tracer   tracing.Tracer
+}
+
+func (suite *TracerTestSuite) SetupTest() {
+	suite.exporter = &tracing.InMemoryExporter{}
+	suite.tracer = tracing.NewTracer(tracing.TracerOpts{Exporter: suite.exporter})
+}
+
+func (suite *TracerTestSuite) TestRoot() {
+	ctx, span := suite.tracer.Start(context.Background(), "root")
+
+	suite.True(span.SpanContext().IsValid())
+	suite.True(span.SpanContext().Sampled)
+	suite.Equal(span, tracing.SpanFromContext(ctx))
+
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("err"))
+	span.End()
+	span.End()
+	span.SetAttribute("key", "other")
+
+	spans := suite.exporter.Spans()
+
+	suite.Require().Len(spans, 1)
+	suite.Equal("root", spans[0].Name)
+	suite.Equal("value", spans[0].Attributes["key"])
+	suite.EqualError(spans[0].Err, "err")
+	suite.False(spans[0].Parent.IsValid())
+	suite.False(spans[0].EndTime.Before(spans[0].StartTime))
+}
+
+func (suite *TracerTestSuite) TestChild() {
+	ctx, root := suite.tracer.Start(context.Background(), "root")
+	_, child := tracing.StartSpan(ctx, "child")
+
+	child.End()
+	root.End()
+
+	spans := suite.exporter.Spans()
+
+	suite.Require().Len(spans, 2)
+	suite.Equal("child", spans[0].Name)
+	suite.Equal(root.SpanContext().TraceID, spans[0].SpanContext.TraceID)
+	suite.Equal(root.SpanContext().SpanID, spans[0].Parent)
+	suite.NotEqual(root.SpanContext().SpanID, spans[0].SpanContext.SpanID)
+	suite.False(spans[0].Remote)
+}
+
+func (suite *TracerTestSuite) TestRemoteParent() {
+	parent, _ := tracing.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx := tracing.ContextWithRemoteParent(context.Background(), parent)
+
+	_, span := suite.tracer.Start(ctx, "root")
+
+	span.End()
+
+	spans := suite.exporter.Spans()
+
+	suite.Require().Len(spans, 1)
+	suite.Equal(parent.TraceID, spans[0].SpanContext.TraceID)
+	suite.Equal(parent.SpanID, spans[0].Parent)
+	suite.True(spans[0].Remote)
+}
+
+func (suite *TracerTestSuite) TestNotSampled() {
+	parent, _ := tracing.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	ctx := tracing.ContextWithRemoteParent(context.Background(), parent)
+
+	ctx, span := suite.tracer.Start(ctx, "root")
+	_, child := tracing.StartSpan(ctx, "child")
+
+	suite.True(span.SpanContext().IsValid())
+	suite.False(child.SpanContext().Sampled)
+
+	child.End()
+	span.End()
+
+	suite.Empty(suite.exporter.Spans())
+}
+
+func (suite *TracerTestSuite) TestSampler() {
+	tracer := tracing.NewTracer(tracing.TracerOpts{
+		Exporter: suite.exporter,
+		Sampler:  tracing.NeverSample(),
+	})
+
+	ctx, span := tracer.Start(context.Background(), "root")
+	_, child := tracing.StartSpan(ctx, "child")
+
+	suite.True(span.SpanContext().IsValid())
+	suite.False(span.SpanContext().Sampled)
+	suite.False(child.SpanContext().Sampled)
+
+	child.End()
+	span.End()
+
+	suite.Empty(suite.exporter.Spans())
+
+	// remote parents are followed whatever sampler says.
+	parent, _ := tracing.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	_, span = tracer.Start(tracing.ContextWithRemoteParent(context.Background(), parent), "root")
+
+	span.End()
+
+	suite.Len(suite.exporter.Spans(), 1)
+}
+
+func (suite *TracerTestSuite) TestTraceIDRatioBased() {
+	sampler := tracing.TraceIDRatioBased(0.5)
+	traceID := tracing.TraceID{}
+
+	suite.True(sampler(traceID))
+
+	traceID[8] = 0xff
+
+	suite.False(sampler(traceID))
+	suite.True(tracing.TraceIDRatioBased(1)(traceID))
+	suite.False(tracing.TraceIDRatioBased(0)(tracing.TraceID{}))
+}
+
+func (suite *TracerTestSuite) TestNoSpan() {
+	ctx := context.Background()
+	newCtx, span := tracing.StartSpan(ctx, "span")
+
+	suite.Equal(ctx, newCtx)
+	suite.False(span.SpanContext().IsValid())
+
+	span.End()
+}
+
+func TestTracer(t *testing.T) {
+	suite.Run(t, &TracerTestSuite{})
+}
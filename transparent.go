@@ -0,0 +1,114 @@
+package httransform
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/9seconds/httransform/v2/errors"
+)
+
+// ErrOriginalDestinationUnsupported is returned if original
+// destination cannot be recovered for a given connection on this
+// platform.
+var ErrOriginalDestinationUnsupported = &errors.Error{
+	Message: "original destination is not supported",
+}
+
+// ErrOriginalDestinationLoop is returned if original destination of
+// the connection is the proxy itself. It happens if client connects to
+// the proxy directly: such connection would be looped back.
+var ErrOriginalDestinationLoop = &errors.Error{
+	Message: "original destination is the proxy itself",
+}
+
+// OriginalDestination returns an address client has connected to
+// before its connection was redirected to the proxy. It is used in
+// transparent mode (see Server.ServeTransparent).
+type OriginalDestination func(conn net.Conn) (string, error)
+
+// OriginalDestinationTPROXY returns an original destination of the
+// connection which was redirected with iptables TPROXY target. Such
+// connections keep their destination addresses so it is just a local
+// address of the connection.
+//
+// Connections which were not redirected have a local address of the
+// listener. Such destinations are rejected by Server.ServeTransparent
+// with ErrOriginalDestinationLoop.
+func OriginalDestinationTPROXY(conn net.Conn) (string, error) {
+	return conn.LocalAddr().String(), nil
+}
+
+// ServeTransparent starts to serve on given net.Listener instance
+// in transparent mode. Clients do not know that they talk to a proxy:
+// their connections are redirected to this listener by firewall (for
+// example, iptables REDIRECT or TPROXY targets). An original destination
+// is recovered with ServerOpts.TransparentOriginalDestination and then
+// connection is sniffed: TLS and plain HTTP are processed in the same
+// way as tunnels of CONNECT method.
+//
+// Please pay attention that authentication is not performed in this
+// mode: there is no way to ask a client for credentials. Connections
+// which original destination is a listener address are closed:
+// otherwise proxy would connect to itself in a loop.
+func (s *Server) ServeTransparent(ln net.Listener) error {
+	listenAddr := ln.Addr()
+
+	return s.serveConns(ln, func(conn net.Conn) {
+		s.serveTransparentConn(conn, listenAddr)
+	})
+}
+
+func (s *Server) serveTransparentConn(conn net.Conn, listenAddr net.Addr) {
+	address, err := s.originalDestination(conn)
+	if err == nil && isListenAddress(address, listenAddr) {
+		err = ErrOriginalDestinationLoop
+	}
+
+	if err != nil {
+		conn.Close()
+
+		return
+	}
+
+	if s.serveTunnel(conn, nil, address, "", 0) {
+		conn.Close()
+	}
+}
+
+func isListenAddress(address string, listenAddr net.Addr) bool {
+	tcpAddr, ok := listenAddr.(*net.TCPAddr)
+	if !ok {
+		return address == listenAddr.String()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || port != strconv.Itoa(tcpAddr.Port) {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+
+	switch {
+	case ip == nil:
+		return false
+	case !tcpAddr.IP.IsUnspecified():
+		return ip.Equal(tcpAddr.IP)
+	case ip.IsLoopback() || ip.IsUnspecified():
+		return true
+	}
+
+	// listener accepts connections on all addresses so original
+	// destination is a loop if it is one of local addresses.
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
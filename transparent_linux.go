@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package httransform
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// OriginalDestinationNAT returns an original destination of the
+// connection which was redirected with iptables REDIRECT or DNAT
+// targets. It uses SO_ORIGINAL_DST socket option so connection has to
+// be tracked by conntrack.
+func OriginalDestinationNAT(conn net.Conn) (string, error) {
+	syscallConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return "", ErrOriginalDestinationUnsupported
+	}
+
+	rawConn, err := syscallConn.SyscallConn()
+	if err != nil {
+		return "", fmt.Errorf("cannot get raw connection: %w", err)
+	}
+
+	var (
+		address string
+		sockErr error
+	)
+
+	err = rawConn.Control(func(fd uintptr) {
+		address, sockErr = getOriginalDestination(int(fd), conn.LocalAddr())
+	})
+
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("cannot access a socket: %w", err)
+	case sockErr != nil:
+		return "", fmt.Errorf("cannot get original destination: %w", sockErr)
+	}
+
+	return address, nil
+}
+
+func getOriginalDestination(fd int, localAddr net.Addr) (string, error) {
+	// ip6tables uses the same value for IP6T_SO_ORIGINAL_DST.
+	level := unix.SOL_IP
+
+	if tcpAddr, ok := localAddr.(*net.TCPAddr); ok && tcpAddr.IP.To4() == nil {
+		level = unix.SOL_IPV6
+	}
+
+	// result is a raw sockaddr_in or sockaddr_in6 structure. Both have
+	// a port in network byte order right after an address family.
+	raw, err := unix.GetsockoptString(fd, level, unix.SO_ORIGINAL_DST)
+	if err != nil {
+		return "", err // nolint: wrapcheck
+	}
+
+	data := []byte(raw)
+
+	var ip net.IP
+
+	switch {
+	case level == unix.SOL_IP && len(data) >= 8: // nolint: gomnd
+		ip = net.IPv4(data[4], data[5], data[6], data[7])
+	case level == unix.SOL_IPV6 && len(data) >= 24: // nolint: gomnd
+		ip = append(net.IP{}, data[8:24]...)
+	default:
+		return "", fmt.Errorf("unexpected length of sockaddr %d", len(data))
+	}
+
+	port := binary.BigEndian.Uint16(data[2:4])
+
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port))), nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package httransform
+
+import (
+	"net"
+)
+
+// OriginalDestinationNAT returns an original destination of the
+// connection which was redirected with iptables REDIRECT or DNAT
+// targets. This is supported on Linux only.
+func OriginalDestinationNAT(_ net.Conn) (string, error) {
+	return "", ErrOriginalDestinationUnsupported
+}
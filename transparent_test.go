@@ -0,0 +1,154 @@
+package httransform_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2"
+	"github.com/9seconds/httransform/v2/errors"
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransparentTestSuite struct {
+	suite.Suite
+
+	httpEndpoint *httptest.Server
+	tlsEndpoint  *httptest.Server
+	destination  atomic.Value
+	lookups      int32
+	proxy        *httransform.Server
+	ln           net.Listener
+	ctx          context.Context
+	ctxCancel    context.CancelFunc
+}
+
+func (suite *TransparentTestSuite) SetupSuite() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	})
+
+	suite.httpEndpoint = httptest.NewServer(handler)
+	suite.tlsEndpoint = httptest.NewTLSServer(handler)
+}
+
+func (suite *TransparentTestSuite) TearDownSuite() {
+	suite.httpEndpoint.Close()
+	suite.tlsEndpoint.Close()
+}
+
+func (suite *TransparentTestSuite) SetupTest() {
+	suite.ctx, suite.ctxCancel = context.WithCancel(context.Background())
+	suite.destination.Store("")
+	atomic.StoreInt32(&suite.lookups, 0)
+
+	opts := httransform.ServerOpts{
+		TLSCertCA:     caCert,
+		TLSPrivateKey: caPrivateKey,
+		TLSSkipVerify: true,
+		Layers: []layers.Layer{
+			layers.TimeoutLayer{
+				Timeout: 10 * time.Second,
+			},
+			sniLayer{},
+		},
+		TransparentOriginalDestination: func(_ net.Conn) (string, error) {
+			atomic.AddInt32(&suite.lookups, 1)
+
+			if value, _ := suite.destination.Load().(string); value != "" {
+				return value, nil
+			}
+
+			return "", &errors.Error{Message: "no destination"}
+		},
+	}
+
+	suite.proxy, _ = httransform.NewServer(suite.ctx, opts)
+	suite.ln, _ = net.Listen("tcp", "127.0.0.1:0")
+
+	go suite.proxy.ServeTransparent(suite.ln)
+}
+
+func (suite *TransparentTestSuite) TearDownTest() {
+	suite.ctxCancel()
+	suite.proxy.Close()
+	suite.ln.Close()
+}
+
+func (suite *TransparentTestSuite) makeClient(serverName string) *http.Client {
+	dialer := &net.Dialer{}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "tcp", suite.ln.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{
+				ServerName:         serverName,
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: time.Second,
+	}
+}
+
+func (suite *TransparentTestSuite) TestHTTP() {
+	suite.destination.Store(strings.TrimPrefix(suite.httpEndpoint.URL, "http://"))
+
+	resp, err := suite.makeClient("").Get("http://example.com/")
+
+	suite.NoError(err)
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	suite.NoError(err)
+	suite.Equal("example.com", string(data))
+	suite.Empty(resp.Header.Get("X-Sni"))
+}
+
+func (suite *TransparentTestSuite) TestHTTPS() {
+	suite.destination.Store(strings.TrimPrefix(suite.tlsEndpoint.URL, "https://"))
+
+	resp, err := suite.makeClient("example.com").Get("https://example.com/")
+
+	suite.NoError(err)
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	suite.NoError(err)
+	suite.Equal("example.com", string(data))
+	suite.Equal("example.com", resp.Header.Get("X-Sni"))
+	suite.Equal([]string{"example.com"}, resp.TLS.PeerCertificates[0].DNSNames)
+}
+
+func (suite *TransparentTestSuite) TestNoDestination() {
+	_, err := suite.makeClient("").Get("http://example.com/")
+
+	suite.Error(err)
+}
+
+func (suite *TransparentTestSuite) TestLoop() {
+	suite.destination.Store(suite.ln.Addr().String())
+
+	_, err := suite.makeClient("").Get("http://example.com/")
+
+	suite.Error(err)
+	suite.EqualValues(1, atomic.LoadInt32(&suite.lookups))
+}
+
+func TestTransparent(t *testing.T) {
+	suite.Run(t, &TransparentTestSuite{})
+}